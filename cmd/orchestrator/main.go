@@ -11,6 +11,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,6 +23,7 @@ import (
 	"warren/internal/admin"
 	"warren/internal/alexandria"
 	"warren/internal/alerts"
+	"warren/internal/checkpoint"
 	"warren/internal/config"
 	"warren/internal/container"
 	"warren/internal/events"
@@ -66,7 +71,32 @@ func main() {
 		logger.Info("container discovery complete", "found", len(discovered))
 	}
 
-	serviceMgr := container.NewManagerWithConfig(docker, logger, cfg, "/usr/local/shared-bin")
+	// Agents can also be declared with warren.* labels on services or
+	// containers. fileCfg is what the YAML says; cfg adds the labelled agents.
+	fileCfg := cfg
+	labeled, err := labeledAgents(ctx, docker, cfg.Runtime, logger)
+	if err != nil {
+		logger.Warn("label discovery failed (continuing without)", "error", err)
+	}
+	cfg = mergeLabeled(fileCfg, labeled, logger)
+
+	// Agents run as Swarm services scaled between 0 and N, or as plain
+	// containers that are started and stopped.
+	var runtimeMgr container.Runtime
+	switch cfg.Runtime {
+	case "docker":
+		runtimeMgr = container.NewContainerManager(docker, logger)
+	default:
+		runtimeMgr = container.NewManagerWithConfig(docker, logger, cfg, "/usr/local/shared-bin")
+	}
+	logger.Info("container runtime selected", "runtime", cfg.Runtime)
+	processMgr := container.NewProcessManager(logger)
+	rts := &runtimes{
+		containers: runtimeMgr,
+		processes:  processMgr,
+		units:      container.NewSystemdManager(&container.SystemdDBus{}, logger),
+		assigned:   make(map[string]assignment),
+	}
 	emitter := events.NewEmitter(logger)
 
 	// Connect to Hermes (NATS) if enabled.
@@ -120,6 +150,12 @@ func main() {
 				subject = hermes.AgentSubject(hermes.SubjectAgentDegraded, ev.Agent)
 				eventType = "agent.degraded"
 				data = hermes.AgentLifecycleData{Agent: ev.Agent, Reason: ev.Fields["reason"]}
+			case events.AgentScaled:
+				subject = hermes.AgentSubject(hermes.SubjectAgentScaled, ev.Agent)
+				eventType = "agent.scaled"
+				from, _ := strconv.Atoi(ev.Fields["from"])
+				to, _ := strconv.Atoi(ev.Fields["to"])
+				data = hermes.AgentScaleData{Agent: ev.Agent, From: from, To: to, Reason: ev.Fields["reason"]}
 			default:
 				return // don't bridge unknown events
 			}
@@ -194,6 +230,7 @@ func main() {
 	p := proxy.New(registry, cfg.ProxyToken, logger)
 	policyByName := make(map[string]policy.Policy)
 	policyCancels := make(map[string]context.CancelFunc)
+	policyCtxs := make(map[string]context.Context)
 
 	// Build a map of discovered container states for startup reconciliation.
 	discoveredState := make(map[string]string) // container name → state
@@ -208,12 +245,17 @@ func main() {
 			os.Exit(1)
 		}
 
-		pol, polCancel := createPolicy(name, agent, serviceMgr, p, emitter, discoveredState, logger)
+		pol, polCtx, polCancel := createPolicy(ctx, name, agent, rts, p, emitter, discoveredState, logger)
 
 		// Register primary hostname and any additional hostnames.
-		p.Register(agent.Hostname, name, target, pol)
+		opts, err := backendOptions(agent)
+		if err != nil {
+			logger.Error("invalid backend options", "agent", name, "error", err)
+			os.Exit(1)
+		}
+		p.RegisterWithOptions(agent.Hostname, name, target, pol, opts)
 		for _, h := range agent.Hostnames {
-			p.Register(h, name, target, pol)
+			p.RegisterWithOptions(h, name, target, pol, opts)
 		}
 		// Wire Alexandria briefing hook for on-demand agents.
 		if od, ok := pol.(*policy.OnDemand); ok && alexClient != nil {
@@ -259,6 +301,7 @@ func main() {
 
 		policyByName[name] = pol
 		policyCancels[name] = polCancel
+		policyCtxs[name] = polCtx
 		logger.Info("agent configured", "name", name, "hostname", agent.Hostname, "extra_hostnames", len(agent.Hostnames), "policy", agent.Policy)
 	}
 
@@ -274,38 +317,106 @@ func main() {
 	}
 
 	// Wire LRU eviction.
-	lruMgr := policy.NewLRUManager(p.Activity(), logger)
+	lruMgr := policy.NewLRUManager(p.Activity(), emitter, logger)
 	for name, pol := range policyByName {
 		if od, ok := pol.(*policy.OnDemand); ok {
-			agent := cfg.Agents[name]
-			lruMgr.Register(name, od, agent.Hostname)
+			lruMgr.Register(name, od, evictionConfig(cfg.Agents[name]))
 		}
 	}
 	if cfg.MaxReadyAgents > 0 {
 		emitter.OnEvent(func(ev events.Event) {
 			if ev.Type == events.AgentReady {
-				lruMgr.EvictIfNeeded(ctx, cfg.MaxReadyAgents)
+				lruMgr.EvictIfNeeded(ctx, cfg.MaxReadyAgents, ev.Agent)
 			}
 		})
 		logger.Info("LRU eviction enabled", "max_ready_agents", cfg.MaxReadyAgents)
 	}
 
-	// Start Docker event watcher.
-	watcher := container.NewWatcher(docker, func(serviceID, serviceName, action string) {
+	// Wire depends_on: dependencies wake first and stay up while dependents are awake.
+	deps := policy.NewDependencies(logger)
+	for name, pol := range policyByName {
+		deps.Register(name, pol, cfg.Agents[name].DependsOn)
+	}
+
+	// Wire resource sampling: Docker stats feed metrics, memory-based eviction and wake refusal.
+	var resources *policy.ResourceMonitor
+	if cfg.Resources.Enabled {
+		resources = policy.NewResourceMonitor(container.NewStatsSampler(docker), lruMgr, policy.ResourceConfig{
+			Interval:        cfg.Resources.SampleInterval,
+			MemoryThreshold: cfg.Resources.MemoryThreshold,
+		}, logger)
+		resources.OnSample = recordResourceMetrics
+		for name, pol := range policyByName {
+			registerResources(resources, name, pol, cfg.Agents[name])
+		}
+		go resources.Start(ctx)
+		logger.Info("resource sampling enabled", "interval", cfg.Resources.SampleInterval, "memory_threshold", cfg.Resources.MemoryThreshold)
+	}
+
+	// Wire reconciliation: policies are checked against their runtime for
+	// changes made outside Warren, such as a service scaled by hand.
+	reconciler := policy.NewReconciler(cfg.Reconcile.Interval, emitter, logger)
+	for name, pol := range policyByName {
+		registerReconciler(reconciler, rts, name, pol, cfg.Agents[name])
+	}
+	go reconciler.Start(ctx)
+
+	// Start Docker event watcher. Services and containers coming and going
+	// trigger a rescan of agent labels, and container starts, exits and
+	// health changes are passed to the agent's policy, both by the main loop
+	// below.
+	labelsChanged := make(chan struct{}, 1)
+	containerEvents := make(chan container.Event, 64)
+	watcher := container.NewWatcher(docker, func(ev container.Event) {
+		fields := map[string]string{
+			"service_id": ev.ID,
+			"action":     ev.Action,
+		}
+		if ev.Container != "" {
+			fields["container"] = ev.Container
+		}
+		if ev.Health != "" {
+			fields["health"] = ev.Health
+		}
 		emitter.Emit(events.Event{
-			Type:  "docker." + action,
-			Agent: serviceName,
-			Fields: map[string]string{
-				"service_id": serviceID,
-				"action":     action,
-			},
+			Type:   "docker." + ev.Action,
+			Agent:  ev.Service,
+			Fields: fields,
 		})
+		if labelActions[ev.Action] {
+			select {
+			case labelsChanged <- struct{}{}:
+			default: // a rescan is already pending
+			}
+		}
+		if runtimeEvent(ev) != "" {
+			select {
+			case containerEvents <- ev:
+			case <-ctx.Done():
+			}
+		}
 	}, logger)
 	go watcher.Watch(ctx)
 
-	// Start policy goroutines.
-	for _, pol := range policyByName {
-		go pol.Start(ctx)
+	// Start backend pool maintenance (DNS re-resolution, ejected target probes).
+	go p.Start(ctx)
+
+	// Restore runtime state from the last checkpoint before policies start,
+	// then keep checkpointing it.
+	var stateStore *checkpoint.Store
+	if cfg.State.Path != "" {
+		stateStore = checkpoint.NewStore(cfg.State.Path, logger)
+		restoreState(stateStore, cfg, policyByName, p, registry, discoveredState, logger)
+		go stateStore.Run(ctx, cfg.State.CheckpointInterval, func() checkpoint.Snapshot {
+			return collectState(p, registry)
+		})
+		logger.Info("state checkpointing enabled", "path", cfg.State.Path, "interval", cfg.State.CheckpointInterval)
+	}
+
+	// Start policy goroutines, each stopped by its own cancel when the agent
+	// is removed.
+	for name, pol := range policyByName {
+		go pol.Start(policyCtxs[name])
 	}
 
 	// Admin server (separate port).
@@ -313,17 +424,10 @@ func main() {
 	if cfg.AdminListen != "" {
 		agentInfos := make(map[string]admin.AgentInfo)
 		for name, agent := range cfg.Agents {
-			agentInfos[name] = admin.AgentInfo{
-				Name:          name,
-				Hostname:      agent.Hostname,
-				Policy:        agent.Policy,
-				Backend:       agent.Backend,
-				ContainerName: agent.Container.Name,
-				HealthURL:     agent.Health.URL,
-				IdleTimeout:   agent.Idle.Timeout.String(),
-			}
+			agentInfos[name] = agentInfo(name, agent)
 		}
-		adminSrv = admin.NewServer(agentInfos, policyByName, policyCancels, registry, emitter, serviceMgr, p, cfg, *configPath, p.WSCounter().Total, hermesClient, procTracker, logger)
+		adminSrv = admin.NewServer(agentInfos, policyByName, policyCancels, registry, emitter, runtimeMgr, p, fileCfg, *configPath, p.WSCounter().Total, hermesClient, procTracker, deps, resources, logger)
+		adminSrv.SetRuntimeResolver(rts.resolve)
 
 		// Mount metrics on admin handler.
 		adminMux := http.NewServeMux()
@@ -382,18 +486,36 @@ func main() {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	var sig os.Signal
-	for {
-		sig = <-sigCh
-		if sig != syscall.SIGHUP {
-			break
-		}
-		logger.Info("SIGHUP received, reloading config")
-		newCfg, err := config.Load(*configPath)
-		if err != nil {
-			logger.Error("failed to reload config", "error", err)
+	for sig == nil {
+		select {
+		case s := <-sigCh:
+			if s != syscall.SIGHUP {
+				sig = s
+				continue
+			}
+			logger.Info("SIGHUP received, reloading config")
+			newCfg, err := config.Load(*configPath)
+			if err != nil {
+				logger.Error("failed to reload config", "error", err)
+				continue
+			}
+			fileCfg = newCfg
+		case ev := <-containerEvents:
+			observeRuntime(cfg, policyByName, ev)
 			continue
+		case <-labelsChanged:
+			found, err := labeledAgents(ctx, docker, cfg.Runtime, logger)
+			if err != nil {
+				logger.Warn("label discovery failed, keeping current agents", "error", err)
+				continue
+			}
+			if reflect.DeepEqual(found, labeled) {
+				continue
+			}
+			labeled = found
 		}
-		reloadConfig(ctx, logger, cfg, newCfg, policyByName, policyCancels, p, serviceMgr, emitter, adminSrv, discoveredState)
+		newCfg := mergeLabeled(fileCfg, labeled, logger)
+		reloadConfig(ctx, logger, cfg, newCfg, policyByName, policyCancels, p, rts, emitter, adminSrv, lruMgr, deps, resources, reconciler, discoveredState)
 		cfg = newCfg
 	}
 
@@ -426,58 +548,494 @@ func main() {
 		logger.Error("shutdown error", "error", err)
 	}
 
+	// Native processes can't be picked up by the next run, so stop them.
+	processMgr.StopAll(shutdownCtx, 10*time.Second)
+
+	if stateStore != nil {
+		if err := stateStore.Save(collectState(p, registry)); err != nil {
+			logger.Error("failed to save state snapshot", "error", err)
+		} else {
+			logger.Info("state snapshot saved", "path", cfg.State.Path)
+		}
+	}
+
 	fmt.Println("orchestrator stopped")
 }
 
-func createPolicy(name string, agent *config.Agent, serviceMgr *container.Manager, p *proxy.Proxy, emitter *events.Emitter, discoveredState map[string]string, logger *slog.Logger) (policy.Policy, context.CancelFunc) {
-	policyCtx, policyCancel := context.WithCancel(context.Background())
+// collectState gathers the runtime state to checkpoint: each routed agent's
+// policy state, proxy activity and dynamic services.
+func collectState(p *proxy.Proxy, registry *services.Registry) checkpoint.Snapshot {
+	snap := checkpoint.Snapshot{
+		Agents:   make(map[string]policy.RuntimeState),
+		Activity: p.Activity().Snapshot(),
+		Services: registry.List(),
+	}
+	for _, b := range p.Backends() {
+		if pp, ok := b.Policy.(policy.Persister); ok {
+			snap.Agents[b.AgentName] = pp.RuntimeState()
+		}
+	}
+	return snap
+}
+
+// restoreState loads the last checkpoint into the policies, which must not
+// have started yet, the proxy's activity tracker and the service registry.
+// Dynamic services of on-demand agents whose containers were not found
+// running are dropped, as they would have been when the agent slept.
+func restoreState(store *checkpoint.Store, cfg *config.Config, policyByName map[string]policy.Policy, p *proxy.Proxy, registry *services.Registry, discoveredState map[string]string, logger *slog.Logger) {
+	snap, err := store.Load()
+	if err != nil {
+		logger.Warn("failed to load state snapshot (starting fresh)", "error", err)
+		return
+	}
+	if snap.SavedAt.IsZero() {
+		logger.Info("no state snapshot found, starting fresh", "path", cfg.State.Path)
+		return
+	}
+
+	for name, st := range snap.Agents {
+		if pp, ok := policyByName[name].(policy.Persister); ok {
+			pp.RestoreRuntimeState(st)
+		}
+	}
+	p.Activity().Restore(snap.Activity)
+
+	restored := 0
+	for _, svc := range snap.Services {
+		if agent, ok := cfg.Agents[svc.Agent]; ok && agent.Policy == "on-demand" && discoveredState[agent.Container.Name] != "running" {
+			continue
+		}
+		if err := registry.Restore(svc); err != nil {
+			logger.Warn("failed to restore service", "hostname", svc.Hostname, "agent", svc.Agent, "error", err)
+			continue
+		}
+		restored++
+	}
+	logger.Info("state restored", "saved_at", snap.SavedAt, "agents", len(snap.Agents), "hostnames", len(snap.Activity), "services", restored)
+}
+
+// agentProbes builds an agent's liveness, startup and readiness probes for
+// what rt manages under name. readiness is nil unless the agent configures one.
+func agentProbes(rt container.Runtime, name string, agent *config.Agent) (liveness, startup, readiness container.Prober) {
+	liveness = rt.Prober(name, agent.Health.LivenessProbe())
+	startup = rt.Prober(name, agent.Health.StartupProbe())
+	if agent.Health.Readiness != nil {
+		readiness = rt.Prober(name, *agent.Health.Readiness)
+	}
+	return liveness, startup, readiness
+}
+
+// runtimeEvent translates a container event into the policy.RuntimeObserver
+// event it stands for, or "" if policies don't care about it.
+func runtimeEvent(ev container.Event) string {
+	switch {
+	case ev.Action == "container.start":
+		return policy.RuntimeStarted
+	case ev.Action == "container.die":
+		return policy.RuntimeDied
+	case ev.Action == "container.health_status" && ev.Health == "unhealthy":
+		return policy.RuntimeUnhealthy
+	}
+	return ""
+}
+
+// observeRuntime passes a container event to the policy of the agent whose
+// service (or, with the docker runtime, container) it belongs to.
+func observeRuntime(cfg *config.Config, policyByName map[string]policy.Policy, ev container.Event) {
+	managed := ev.Service
+	if managed == "" {
+		managed = ev.Container
+	}
+	if managed == "" {
+		return
+	}
+	for name, agent := range cfg.Agents {
+		if agent.Process != nil || agent.Systemd != nil || agent.Container.Name != managed {
+			continue
+		}
+		if obs, ok := policyByName[name].(policy.RuntimeObserver); ok {
+			go obs.ObserveRuntime(runtimeEvent(ev))
+		}
+	}
+}
+
+// labelActions are the Docker events that can add, change or remove a
+// labelled agent.
+var labelActions = map[string]bool{
+	"service.create":    true,
+	"service.update":    true,
+	"service.remove":    true,
+	"container.create":  true,
+	"container.destroy": true,
+}
+
+// labeledAgents builds agents from the warren.* labels on Swarm services, or
+// containers with the docker runtime. Agents with bad labels are logged and
+// skipped.
+func labeledAgents(ctx context.Context, docker container.LabelClient, runtime string, logger *slog.Logger) (map[string]*config.Agent, error) {
+	found, err := container.DiscoverLabeled(ctx, docker, runtime)
+	if err != nil {
+		return nil, err
+	}
+	agents := make(map[string]*config.Agent, len(found))
+	for _, la := range found {
+		agent, err := config.AgentFromLabels(la.Managed, la.Labels)
+		if err != nil {
+			logger.Warn("ignoring labelled agent", "agent", la.Agent, "service", la.Managed, "error", err)
+			continue
+		}
+		agents[la.Agent] = agent
+	}
+	return agents, nil
+}
+
+// mergeLabeled adds labelled agents to the YAML config, logging any that
+// conflict with it.
+func mergeLabeled(fileCfg *config.Config, labeled map[string]*config.Agent, logger *slog.Logger) *config.Config {
+	merged, errs := config.MergeLabeled(fileCfg, labeled)
+	for _, err := range errs {
+		logger.Warn("ignoring labelled agent", "error", err)
+	}
+	return merged
+}
+
+// runtimes holds the managers an agent's lifecycle can be handed to.
+type runtimes struct {
+	containers container.Runtime // Swarm services or plain containers
+	processes  *container.ProcessManager
+	units      *container.SystemdManager
+
+	mu       sync.Mutex
+	assigned map[string]assignment // agent → what forAgent handed it to
+}
+
+type assignment struct {
+	runtime container.Runtime
+	name    string
+}
+
+// forAgent returns what manages an agent's lifecycle and the name it is
+// managed under. Agents with a process block run as native processes under
+// their agent name, agents with a systemd block as their unit, and the rest
+// use the container runtime.
+func (r *runtimes) forAgent(name string, agent *config.Agent) (container.Runtime, string) {
+	var a assignment
+	switch {
+	case agent.Process != nil:
+		r.processes.Register(name, processSpec(agent.Process))
+		a = assignment{r.processes, name}
+	case agent.Systemd != nil:
+		a = assignment{r.units, agent.Systemd.Unit}
+	default:
+		a = assignment{r.containers, agent.Container.Name}
+	}
+	r.mu.Lock()
+	r.assigned[name] = a
+	r.mu.Unlock()
+	return a.runtime, a.name
+}
+
+// forget drops a removed agent's assignment.
+func (r *runtimes) forget(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.assigned, name)
+}
+
+// resolve returns what manages an agent and its managed name, for the admin
+// API. It is called from admin requests, so it reads the assignments rather
+// than the config the main loop replaces on reload.
+func (r *runtimes) resolve(agent string) (container.Lifecycle, string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.assigned[agent]
+	if !ok || a.name == "" {
+		return nil, "", false
+	}
+	return a.runtime, a.name, true
+}
+
+// processSpec translates an agent's process config for the process manager.
+func processSpec(pc *config.ProcessConfig) container.ProcessSpec {
+	env := make([]string, 0, len(pc.Env))
+	for k, v := range pc.Env {
+		env = append(env, k+"="+v)
+	}
+	slices.Sort(env)
+	return container.ProcessSpec{
+		Command:     pc.Command,
+		Dir:         pc.Dir,
+		Env:         env,
+		User:        pc.User,
+		LogDir:      pc.LogDir,
+		LogMaxSize:  pc.LogMaxSize,
+		LogMaxFiles: pc.LogMaxFiles,
+	}
+}
+
+// createPolicy builds an agent's policy. The caller starts it with the
+// returned context, which the returned cancel ends when the agent is removed.
+func createPolicy(ctx context.Context, name string, agent *config.Agent, rts *runtimes, p *proxy.Proxy, emitter *events.Emitter, discoveredState map[string]string, logger *slog.Logger) (policy.Policy, context.Context, context.CancelFunc) {
+	policyCtx, policyCancel := context.WithCancel(ctx)
+
+	lifecycle, managedName := rts.forAgent(name, agent)
+	liveness, startup, readiness := agentProbes(lifecycle, managedName, agent)
 
 	var pol policy.Policy
 	switch agent.Policy {
 	case "always-on":
-		pol = policy.NewAlwaysOn(policy.AlwaysOnConfig{
-			Agent:         name,
-			HealthURL:     agent.Health.URL,
-			CheckInterval: agent.Health.CheckInterval,
-			MaxFailures:   agent.Health.MaxFailures,
+		pol = policy.NewAlwaysOn(lifecycle, policy.AlwaysOnConfig{
+			Agent:              name,
+			ContainerName:      managedName,
+			HealthURL:          agent.Health.URL,
+			Probe:              liveness,
+			StartupProbe:       startup,
+			ReadinessProbe:     readiness,
+			CheckInterval:      agent.Health.CheckInterval,
+			StartupInterval:    agent.Health.StartupInterval,
+			StartupTimeout:     agent.Health.StartupTimeout,
+			MaxFailures:        agent.Health.MaxFailures,
+			MaxRestartAttempts: agent.Health.MaxRestartAttempts,
+			RecoveryBackoff:    agent.Health.RecoveryBackoff,
+			RecoveryMaxBackoff: agent.Health.RecoveryMaxBackoff,
 		}, emitter, logger)
 	case "on-demand":
-		pol = policy.NewOnDemand(serviceMgr, policy.OnDemandConfig{
+		schedule, err := agentSchedule(agent)
+		if err != nil {
+			logger.Error("invalid idle schedule, ignoring", "agent", name, "error", err)
+		}
+		pol = policy.NewOnDemand(lifecycle, policy.OnDemandConfig{
 			Agent:              name,
-			ContainerName:      agent.Container.Name,
+			ContainerName:      managedName,
 			HealthURL:          agent.Health.URL,
-			Hostname:           agent.Hostname,
+			Probe:              liveness,
+			StartupProbe:       startup,
+			ReadinessProbe:     readiness,
+			Hostname:           routeKey(agent),
 			CheckInterval:      agent.Health.CheckInterval,
 			StartupTimeout:     agent.Health.StartupTimeout,
+			StartupInterval:    agent.Health.StartupInterval,
 			IdleTimeout:        agent.Idle.Timeout,
 			WakeCooldown:       agent.Idle.WakeCooldown,
+			DrainTimeout:       agent.Idle.DrainTimeout,
+			RecoveryBackoff:    agent.Health.RecoveryBackoff,
+			RecoveryMaxBackoff: agent.Health.RecoveryMaxBackoff,
 			MaxFailures:        agent.Health.MaxFailures,
 			MaxRestartAttempts: agent.Health.MaxRestartAttempts,
+			Schedule:           schedule,
 		}, p.Activity(), p.WSCounter(), emitter, logger)
 
 		// Startup reconciliation: inform policy if container is already running.
-		if state, ok := discoveredState[agent.Container.Name]; ok {
+		if state, ok := discoveredState[agent.Container.Name]; ok && agent.Process == nil {
 			pol.(*policy.OnDemand).SetInitialState(state == "running")
 		}
+	case "autoscale":
+		// Config validation only allows autoscale on the Swarm runtime.
+		scaler, _ := rts.containers.(policy.Scaler)
+		cfg := autoscaleConfig(agent)
+		cfg.Agent = name
+		cfg.ContainerName = agent.Container.Name
+		cfg.HealthURL = agent.Health.URL
+		cfg.Probe = liveness
+		cfg.ReadinessProbe = readiness
+		cfg.Hostname = routeKey(agent)
+		cfg.Hostnames = extraRouteKeys(agent)
+		cfg.CheckInterval = agent.Health.CheckInterval
+		cfg.MaxFailures = agent.Health.MaxFailures
+		pol = policy.NewAutoscale(scaler, cfg, p.Requests(), p.WSCounter(), emitter, logger)
 	case "unmanaged":
 		pol = policy.NewUnmanaged()
 	}
 
-	// The caller is responsible for starting the goroutine with policyCtx.
-	// We wrap Start to use the policy-specific context.
-	wrapper := &policyWrapper{inner: pol, ctx: policyCtx}
-	_ = wrapper // not used directly; we return the raw policy and cancel
+	// Swarm keeps always-on services running, but an always-on process has
+	// to be launched, and a unit may not be enabled to start at boot.
+	if (agent.Process != nil || agent.Systemd != nil) && agent.Policy == "always-on" {
+		if err := lifecycle.Start(policyCtx, managedName); err != nil {
+			logger.Error("failed to start agent", "agent", name, "error", err)
+		}
+	}
+
+	return pol, policyCtx, policyCancel
+}
+
+// backendOptions translates an agent's config into proxy backend options.
+func backendOptions(agent *config.Agent) (proxy.BackendOptions, error) {
+	lb := agent.LoadBalancing
+	opts := proxy.BackendOptions{
+		PathPrefix:  agent.PathPrefix,
+		StripPrefix: agent.StripPrefix,
+		Balancer: proxy.BalancerConfig{
+			Strategy:        lb.Strategy,
+			HashHeader:      lb.HashHeader,
+			HashCookie:      lb.HashCookie,
+			ResolveDNS:      lb.ResolveDNS,
+			ResolveInterval: lb.ResolveInterval,
+			EjectAfter:      lb.EjectAfter,
+			ProbeInterval:   lb.ProbeInterval,
+		},
+	}
+	for _, b := range agent.Backends {
+		u, err := url.Parse(b)
+		if err != nil {
+			return opts, fmt.Errorf("invalid backends entry %q: %w", b, err)
+		}
+		opts.Targets = append(opts.Targets, u)
+	}
+	// Probe ejected replicas on the same path as the agent's health check.
+	if agent.Health.URL != "" {
+		if u, err := url.Parse(agent.Health.URL); err == nil {
+			opts.Balancer.ProbePath = u.Path
+		}
+	}
+	if agent.ColdStart.Mode == "hold" {
+		opts.Hold = &proxy.HoldConfig{
+			MaxQueue: agent.ColdStart.MaxQueue,
+			Timeout:  agent.ColdStart.HoldTimeout,
+		}
+	}
+	if agent.ColdStart.Interstitial {
+		opts.Interstitial = proxy.DefaultInterstitial
+		if agent.ColdStart.InterstitialTemplate != "" {
+			tmpl, err := proxy.LoadInterstitial(agent.ColdStart.InterstitialTemplate)
+			if err != nil {
+				return opts, err
+			}
+			opts.Interstitial = tmpl
+		}
+	}
+	return opts, nil
+}
+
+// routeKey is the key the proxy tracks an agent's activity and connections
+// under: its primary hostname plus path prefix.
+func routeKey(agent *config.Agent) string {
+	return services.RouteKey(agent.Hostname, services.NormalizePathPrefix(agent.PathPrefix))
+}
+
+// extraRouteKeys returns the route keys of an agent's additional hostnames.
+func extraRouteKeys(agent *config.Agent) []string {
+	keys := make([]string, 0, len(agent.Hostnames))
+	for _, h := range agent.Hostnames {
+		keys = append(keys, services.RouteKey(h, services.NormalizePathPrefix(agent.PathPrefix)))
+	}
+	return keys
+}
+
+// routing returns the parts of an agent's config its proxy routes are built
+// from, for spotting changes on reload.
+func routing(agent *config.Agent) []any {
+	return []any{agent.Hostname, agent.Hostnames, agent.PathPrefix, agent.StripPrefix, agent.Backend,
+		agent.Backends, agent.LoadBalancing, agent.Health.URL, agent.ColdStart}
+}
+
+// reroute replaces an agent's proxy routes with ones built from its new
+// config. The old routes are kept if the new backend settings are invalid.
+func reroute(p *proxy.Proxy, name string, agent *config.Agent, pol policy.Policy) error {
+	target, err := url.Parse(agent.Backend)
+	if err != nil {
+		return fmt.Errorf("invalid backend URL: %w", err)
+	}
+	opts, err := backendOptions(agent)
+	if err != nil {
+		return err
+	}
+	p.DeregisterAgent(name)
+	p.RegisterWithOptions(agent.Hostname, name, target, pol, opts)
+	for _, h := range agent.Hostnames {
+		p.RegisterWithOptions(h, name, target, pol, opts)
+	}
+	return nil
+}
+
+// agentInfo describes an agent for the admin API.
+func agentInfo(name string, agent *config.Agent) admin.AgentInfo {
+	return admin.AgentInfo{
+		Name:          name,
+		Hostname:      agent.Hostname,
+		PathPrefix:    services.NormalizePathPrefix(agent.PathPrefix),
+		Policy:        agent.Policy,
+		Backend:       agent.Backend,
+		ContainerName: agent.Container.Name,
+		HealthURL:     agent.Health.URL,
+		IdleTimeout:   agent.Idle.Timeout.String(),
+	}
+}
+
+// autoscaleConfig translates an agent's autoscale settings for the policy.
+func autoscaleConfig(agent *config.Agent) policy.AutoscaleConfig {
+	as := agent.Autoscale
+	return policy.AutoscaleConfig{
+		Interval:          as.Interval,
+		MinReplicas:       as.Min,
+		MaxReplicas:       as.Max,
+		TargetRPS:         as.TargetRPS,
+		TargetConnections: as.TargetConnections,
+		Tolerance:         as.Tolerance,
+		ScaleUpCooldown:   as.ScaleUpCooldown,
+		ScaleDownCooldown: as.ScaleDownCooldown,
+	}
+}
+
+// evictionConfig translates an agent's eviction settings for the LRU manager.
+func evictionConfig(agent *config.Agent) policy.EvictionConfig {
+	return policy.EvictionConfig{
+		Priority: agent.Eviction.Priority,
+		Pinned:   agent.Eviction.Pinned,
+		MinAwake: agent.Eviction.MinAwake,
+	}
+}
+
+// registerReconciler checks an agent's policy against the runtime that
+// manages it.
+func registerReconciler(reconciler *policy.Reconciler, rts *runtimes, name string, pol policy.Policy, agent *config.Agent) {
+	lifecycle, managedName := rts.forAgent(name, agent)
+	reconciler.Register(name, pol, lifecycle, managedName)
+}
+
+// registerResources reports an agent's container stats and gates its wakes on
+// host memory. It does nothing if resource sampling is disabled.
+func registerResources(resources *policy.ResourceMonitor, name string, pol policy.Policy, agent *config.Agent) {
+	if resources == nil || agent.Container.Name == "" {
+		return
+	}
+	resources.Register(name, agent.Container.Name)
+	if od, ok := pol.(*policy.OnDemand); ok {
+		od.SetWakeGate(resources)
+	}
+}
 
-	return pol, policyCancel
+// recordResourceMetrics publishes a resource sample as Prometheus gauges.
+// Agents missing from the sample have no running tasks and are dropped.
+func recordResourceMetrics(host container.HostStats, agents map[string]container.ServiceStats) {
+	metrics.HostMemoryBytes.WithLabelValues("total").Set(float64(host.MemoryTotal))
+	metrics.HostMemoryBytes.WithLabelValues("used").Set(float64(host.MemoryUsed))
+	metrics.HostMemoryBytes.WithLabelValues("containers").Set(float64(host.ContainerMemory))
+	metrics.AgentCPUPercent.Reset()
+	metrics.AgentMemoryBytes.Reset()
+	for agent, st := range agents {
+		metrics.AgentCPUPercent.WithLabelValues(agent).Set(st.CPUPercent)
+		metrics.AgentMemoryBytes.WithLabelValues(agent).Set(float64(st.MemoryBytes))
+	}
 }
 
-// policyWrapper is unused but reserved for future use.
-type policyWrapper struct {
-	inner policy.Policy
-	ctx   context.Context
+// agentSchedule parses an agent's idle schedule. It returns nil if none is set.
+func agentSchedule(agent *config.Agent) (*policy.Schedule, error) {
+	sched := agent.Idle.Schedule
+	cfg := policy.ScheduleConfig{Timezone: sched.Timezone, Wake: sched.Wake}
+	for _, w := range sched.KeepAwake {
+		cfg.KeepAwake = append(cfg.KeepAwake, policy.ScheduleWindow{Start: w.Start, Duration: w.Duration})
+	}
+	for _, w := range sched.QuietHours {
+		cfg.QuietHours = append(cfg.QuietHours, policy.ScheduleWindow{Start: w.Start, Duration: w.Duration})
+	}
+	return policy.NewSchedule(cfg)
 }
 
-func reloadConfig(ctx context.Context, logger *slog.Logger, old, new_ *config.Config, policyByName map[string]policy.Policy, policyCancels map[string]context.CancelFunc, p *proxy.Proxy, serviceMgr *container.Manager, emitter *events.Emitter, adminSrv *admin.Server, discoveredState map[string]string) {
+func reloadConfig(ctx context.Context, logger *slog.Logger, old, new_ *config.Config, policyByName map[string]policy.Policy, policyCancels map[string]context.CancelFunc, p *proxy.Proxy, rts *runtimes, emitter *events.Emitter, adminSrv *admin.Server, lruMgr *policy.LRUManager, deps *policy.Dependencies, resources *policy.ResourceMonitor, reconciler *policy.Reconciler, discoveredState map[string]string) {
+	if new_.Runtime != old.Runtime {
+		logger.Warn("config reload: runtime change requires restart, keeping current runtime", "current", old.Runtime, "configured", new_.Runtime)
+	}
+
 	// Add new agents.
 	for name, agent := range new_.Agents {
 		if _, ok := old.Agents[name]; ok {
@@ -491,29 +1049,27 @@ func reloadConfig(ctx context.Context, logger *slog.Logger, old, new_ *config.Co
 			continue
 		}
 
-		pol, polCancel := createPolicy(name, agent, serviceMgr, p, emitter, discoveredState, logger)
+		opts, err := backendOptions(agent)
+		if err != nil {
+			logger.Error("config reload: invalid backend options for new agent", "agent", name, "error", err)
+			continue
+		}
+
+		pol, polCtx, polCancel := createPolicy(ctx, name, agent, rts, p, emitter, discoveredState, logger)
 
-		p.Register(agent.Hostname, name, target, pol)
+		p.RegisterWithOptions(agent.Hostname, name, target, pol, opts)
 		for _, h := range agent.Hostnames {
-			p.Register(h, name, target, pol)
+			p.RegisterWithOptions(h, name, target, pol, opts)
 		}
 
 		policyByName[name] = pol
 		policyCancels[name] = polCancel
 
 		// Start policy goroutine.
-		go pol.Start(ctx)
+		go pol.Start(polCtx)
 
 		if adminSrv != nil {
-			adminSrv.AddAgent(name, admin.AgentInfo{
-				Name:          name,
-				Hostname:      agent.Hostname,
-				Policy:        agent.Policy,
-				Backend:       agent.Backend,
-				ContainerName: agent.Container.Name,
-				HealthURL:     agent.Health.URL,
-				IdleTimeout:   agent.Idle.Timeout.String(),
-			}, pol, polCancel)
+			adminSrv.AddAgent(name, agentInfo(name, agent), pol, polCancel)
 		}
 
 		emitter.Emit(events.Event{Type: events.AgentAdded, Agent: name})
@@ -521,7 +1077,7 @@ func reloadConfig(ctx context.Context, logger *slog.Logger, old, new_ *config.Co
 	}
 
 	// Remove deleted agents.
-	for name, agent := range old.Agents {
+	for name := range old.Agents {
 		if _, ok := new_.Agents[name]; ok {
 			continue // still exists
 		}
@@ -534,13 +1090,23 @@ func reloadConfig(ctx context.Context, logger *slog.Logger, old, new_ *config.Co
 			delete(policyCancels, name)
 		}
 
-		// Deregister from proxy.
-		p.Deregister(agent.Hostname)
-		for _, h := range agent.Hostnames {
-			p.Deregister(h)
-		}
+		// Deregister from proxy. Other agents may share the hostnames under
+		// different path prefixes, so only this agent's routes are removed.
+		p.DeregisterAgent(name)
 
 		delete(policyByName, name)
+		if old.Agents[name].Process != nil {
+			if err := rts.processes.Unregister(ctx, name, 10*time.Second); err != nil {
+				logger.Error("config reload: failed to stop process", "agent", name, "error", err)
+			}
+		}
+		rts.forget(name)
+		lruMgr.Unregister(name)
+		deps.Unregister(name)
+		reconciler.Unregister(name)
+		if resources != nil {
+			resources.Unregister(name)
+		}
 
 		if adminSrv != nil {
 			adminSrv.RemoveAgentInternal(name)
@@ -556,11 +1122,41 @@ func reloadConfig(ctx context.Context, logger *slog.Logger, old, new_ *config.Co
 		if !ok {
 			continue
 		}
+		// Hostnames, path prefix, backends and proxy options changed on a
+		// running agent (for example by relabelling its service) take effect
+		// by replacing its routes.
+		if oldAgent, ok := old.Agents[name]; ok && !reflect.DeepEqual(routing(oldAgent), routing(newAgent)) {
+			if err := reroute(p, name, newAgent, pol); err != nil {
+				logger.Error("config reload: keeping old routes", "agent", name, "error", err)
+			} else {
+				if adminSrv != nil {
+					adminSrv.AddAgent(name, agentInfo(name, newAgent), pol, policyCancels[name])
+				}
+				logger.Info("config reload: routes updated", "agent", name, "hostname", newAgent.Hostname, "extra_hostnames", len(newAgent.Hostnames))
+			}
+		}
+		deps.Register(name, pol, newAgent.DependsOn)
+		registerResources(resources, name, pol, newAgent)
+		registerReconciler(reconciler, rts, name, pol, newAgent)
+		if newAgent.Process != nil {
+			rts.processes.Register(name, processSpec(newAgent.Process)) // takes effect at the next start
+		}
 		switch p := pol.(type) {
 		case *policy.OnDemand:
-			p.Reconfigure(newAgent.Idle.Timeout, newAgent.Health.CheckInterval, newAgent.Health.MaxFailures, newAgent.Health.MaxRestartAttempts)
+			schedule, err := agentSchedule(newAgent)
+			if err != nil {
+				logger.Error("config reload: invalid idle schedule, ignoring", "agent", name, "error", err)
+			}
+			p.Reconfigure(newAgent.Idle.Timeout, newAgent.Health.CheckInterval, newAgent.Health.MaxFailures, newAgent.Health.MaxRestartAttempts, schedule)
+			p.SetHostname(routeKey(newAgent))
+			lruMgr.Register(name, p, evictionConfig(newAgent))
 		case *policy.AlwaysOn:
-			p.Reconfigure(newAgent.Health.CheckInterval, newAgent.Health.MaxFailures)
+			p.Reconfigure(newAgent.Health.CheckInterval, newAgent.Health.MaxFailures, newAgent.Health.MaxRestartAttempts)
+		case *policy.Autoscale:
+			cfg := autoscaleConfig(newAgent)
+			cfg.Hostname = routeKey(newAgent)
+			cfg.Hostnames = extraRouteKeys(newAgent)
+			p.Reconfigure(cfg)
 		}
 	}
 	logger.Info("config reload complete")
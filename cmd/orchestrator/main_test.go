@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/config"
+	"warren/internal/container"
+	"warren/internal/events"
+	"warren/internal/policy"
+	"warren/internal/proxy"
+	"warren/internal/services"
+)
+
+// fakeRuntime reports everything running and counts health probes.
+type fakeRuntime struct {
+	probes atomic.Int64
+}
+
+func (f *fakeRuntime) Start(context.Context, string) error                  { return nil }
+func (f *fakeRuntime) Stop(context.Context, string, time.Duration) error    { return nil }
+func (f *fakeRuntime) Restart(context.Context, string, time.Duration) error { return nil }
+func (f *fakeRuntime) Status(context.Context, string) (string, error)       { return "running", nil }
+func (f *fakeRuntime) Prober(string, config.Probe) container.Prober         { return f }
+func (f *fakeRuntime) Probe(context.Context) error                          { f.probes.Add(1); return nil }
+
+// reloader holds what reloadConfig works on.
+type reloader struct {
+	logger    *slog.Logger
+	policies  map[string]policy.Policy
+	cancels   map[string]context.CancelFunc
+	proxy     *proxy.Proxy
+	rts       *runtimes
+	emitter   *events.Emitter
+	lru       *policy.LRUManager
+	deps      *policy.Dependencies
+	reconcile *policy.Reconciler
+}
+
+func newReloader(rt container.Runtime) *reloader {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	emitter := events.NewEmitter(logger)
+	p := proxy.New(services.NewRegistry(logger), "", logger)
+	return &reloader{
+		logger:    logger,
+		policies:  make(map[string]policy.Policy),
+		cancels:   make(map[string]context.CancelFunc),
+		proxy:     p,
+		rts:       &runtimes{containers: rt, assigned: make(map[string]assignment)},
+		emitter:   emitter,
+		lru:       policy.NewLRUManager(p.Activity(), emitter, logger),
+		deps:      policy.NewDependencies(logger),
+		reconcile: policy.NewReconciler(time.Hour, emitter, logger),
+	}
+}
+
+func (r *reloader) reload(ctx context.Context, old, new_ *config.Config) {
+	reloadConfig(ctx, r.logger, old, new_, r.policies, r.cancels, r.proxy, r.rts, r.emitter, nil, r.lru, r.deps, nil, r.reconcile, map[string]string{})
+}
+
+// labelled merges agents built from labels into an empty YAML config.
+func labelled(t *testing.T, labels map[string]map[string]string) *config.Config {
+	t.Helper()
+	agents := make(map[string]*config.Agent)
+	for name, l := range labels {
+		agent, err := config.AgentFromLabels(name, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		agents[name] = agent
+	}
+	cfg, errs := config.MergeLabeled(&config.Config{}, agents)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	for _, agent := range cfg.Agents {
+		agent.Health.StartupInterval = agent.Health.CheckInterval
+	}
+	return cfg
+}
+
+func TestRemovingLabelledAgentStopsPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt := &fakeRuntime{}
+	r := newReloader(rt)
+
+	empty := labelled(t, nil)
+	withAgent := labelled(t, map[string]map[string]string{"svc": {
+		"warren.hostname":              "svc.example.com",
+		"warren.port":                  "8080",
+		"warren.policy":                "always-on",
+		"warren.health.url":            "http://svc:8080/health",
+		"warren.health.check_interval": "5ms",
+	}})
+	r.reload(ctx, empty, withAgent)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rt.probes.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("policy never probed the agent")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	r.reload(ctx, withAgent, empty)
+	time.Sleep(20 * time.Millisecond) // let a probe in flight finish
+	before := rt.probes.Load()
+	time.Sleep(50 * time.Millisecond)
+	if after := rt.probes.Load(); after != before {
+		t.Errorf("policy still probing after the agent was removed: %d probes, then %d", before, after)
+	}
+	if _, ok := r.policies["svc"]; ok {
+		t.Error("removed agent still has a policy")
+	}
+}
+
+func TestRelabellingAgentMovesRoutes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := newReloader(&fakeRuntime{})
+
+	agent := func(labels map[string]string) *config.Config {
+		labels["warren.port"] = "8080"
+		labels["warren.policy"] = "unmanaged"
+		return labelled(t, map[string]map[string]string{"svc": labels})
+	}
+	before := agent(map[string]string{"warren.hostname": "old.example.com"})
+	after := agent(map[string]string{"warren.hostname": "new.example.com", "warren.path_prefix": "/api"})
+	r.reload(ctx, labelled(t, nil), before)
+	r.reload(ctx, before, after)
+
+	routes := r.proxy.Backends()
+	if _, ok := routes["old.example.com"]; ok {
+		t.Error("old hostname still routed")
+	}
+	if b, ok := routes["new.example.com/api"]; !ok || b.AgentName != "svc" {
+		t.Errorf("new route missing: %v", routes)
+	}
+}
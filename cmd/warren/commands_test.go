@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
 )
 
@@ -42,6 +44,7 @@ func executeCommand(t *testing.T, serverURL string, args ...string) (string, err
 
 	// Reset globals.
 	adminURL = serverURL
+	adminToken = ""
 	format = "table"
 
 	root := &cobra.Command{
@@ -49,6 +52,7 @@ func executeCommand(t *testing.T, serverURL string, args ...string) (string, err
 		Short: "Warren CLI",
 	}
 	root.PersistentFlags().StringVar(&adminURL, "admin", serverURL, "admin API URL")
+	root.PersistentFlags().StringVar(&adminToken, "admin-token", "", "admin API bearer token")
 	root.PersistentFlags().StringVar(&format, "format", "table", "output format")
 
 	agentCmd := &cobra.Command{Use: "agent", Short: "Manage agents"}
@@ -59,6 +63,9 @@ func executeCommand(t *testing.T, serverURL string, args ...string) (string, err
 		agentInspectCmd(),
 		agentWakeCmd(),
 		agentSleepCmd(),
+		agentResetCmd(),
+		agentLogsCmd(),
+		agentExecCmd(),
 	)
 
 	serviceCmd := &cobra.Command{Use: "service", Short: "Manage dynamic services"}
@@ -383,6 +390,24 @@ func TestAgentWake_Success(t *testing.T) {
 	}
 }
 
+func TestAgentReset_NotDegraded(t *testing.T) {
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{
+		"POST /admin/agents/myagent/reset": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(409)
+			w.Write([]byte(`{"error":"agent is not degraded"}`))
+		},
+	})
+	defer srv.Close()
+
+	_, err := executeCommand(t, srv.URL, "agent", "reset", "myagent")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "not degraded") {
+		t.Errorf("expected 'not degraded' in error, got: %v", err)
+	}
+}
+
 func TestAgentWake_NotFound(t *testing.T) {
 	srv := mockAdminServer(t, map[string]http.HandlerFunc{
 		"POST /admin/agents/ghost/wake": func(w http.ResponseWriter, r *http.Request) {
@@ -432,6 +457,129 @@ func TestAgentSleep_NotFound(t *testing.T) {
 	}
 }
 
+// --- Agent Logs Tests ---
+
+func TestAgentLogs_Streams(t *testing.T) {
+	var query string
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{
+		"GET /admin/agents/myagent/logs": func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.RawQuery
+			if r.Header.Get("Accept") != "text/event-stream" {
+				t.Errorf("Accept = %q", r.Header.Get("Accept"))
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "event: stdout\ndata: listening on :18789\n\n")
+			fmt.Fprint(w, "event: stderr\ndata: warning: no token\n\n")
+		},
+	})
+	defer srv.Close()
+
+	out, err := executeCommand(t, srv.URL, "agent", "logs", "myagent", "--follow=false", "--tail", "20", "--since", "10m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "listening on :18789") || !strings.Contains(out, "warning: no token") {
+		t.Errorf("missing log lines in output:\n%s", out)
+	}
+	if query != "follow=false&since=10m&tail=20" {
+		t.Errorf("query = %q", query)
+	}
+}
+
+func TestAgentLogs_StreamError(t *testing.T) {
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{
+		"GET /admin/agents/myagent/logs": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "event: stdout\ndata: hello\n\nevent: error\ndata: unexpected EOF\n\n")
+		},
+	})
+	defer srv.Close()
+
+	_, err := executeCommand(t, srv.URL, "agent", "logs", "myagent")
+	if err == nil || !strings.Contains(err.Error(), "unexpected EOF") {
+		t.Errorf("err = %v, want the stream error", err)
+	}
+}
+
+func TestAgentLogs_NotFound(t *testing.T) {
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{})
+	defer srv.Close()
+
+	_, err := executeCommand(t, srv.URL, "agent", "logs", "ghost")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("err = %v, want HTTP 404", err)
+	}
+}
+
+// execHandler serves an exec WebSocket that checks the request, waits for
+// stdin to be closed, writes output and exits with code.
+func execHandler(t *testing.T, code int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer s3cret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.URL.Query()["cmd"]; strings.Join(got, " ") != "ls -la /data" {
+			t.Errorf("cmd = %q", got)
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != `{"type":"stdin_close"}` {
+			t.Errorf("first message = %q, %v", msg, err)
+		}
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{1}, "total 0\n"...))
+		conn.WriteMessage(websocket.BinaryMessage, append([]byte{2}, "ls: warning\n"...))
+		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"type":"exit","code":%d}`, code)))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		conn.ReadMessage()
+	}
+}
+
+func TestAgentExec_Runs(t *testing.T) {
+	t.Setenv("WARREN_ADMIN_TOKEN", "s3cret")
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{
+		"GET /admin/agents/myagent/exec": execHandler(t, 0),
+	})
+	defer srv.Close()
+
+	out, err := executeCommand(t, srv.URL, "agent", "exec", "myagent", "--", "ls", "-la", "/data")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "total 0") || !strings.Contains(out, "ls: warning") {
+		t.Errorf("missing output:\n%s", out)
+	}
+}
+
+func TestAgentExec_ExitCode(t *testing.T) {
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{
+		"GET /admin/agents/myagent/exec": execHandler(t, 3),
+	})
+	defer srv.Close()
+
+	_, err := executeCommand(t, srv.URL, "--admin-token", "s3cret", "agent", "exec", "myagent", "--", "ls", "-la", "/data")
+	var exitErr *exitCodeError
+	if !errors.As(err, &exitErr) || exitErr.code != 3 {
+		t.Errorf("err = %v, want exit status 3", err)
+	}
+}
+
+func TestAgentExec_Refused(t *testing.T) {
+	srv := mockAdminServer(t, map[string]http.HandlerFunc{
+		"GET /admin/agents/myagent/exec": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"exec requires admin_token to be set"}`, http.StatusForbidden)
+		},
+	})
+	defer srv.Close()
+
+	_, err := executeCommand(t, srv.URL, "agent", "exec", "myagent", "--", "sh")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "admin_token") {
+		t.Errorf("err = %v, want HTTP 403 with the reason", err)
+	}
+}
+
 // --- Service List Tests ---
 
 func TestServiceList_Table(t *testing.T) {
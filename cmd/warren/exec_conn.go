@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// execConn is the WebSocket of an exec session. Input, resizes and
+// stdin_close are sent from different goroutines, and gorilla/websocket
+// allows only one writer at a time, so writes are serialised here.
+type execConn struct {
+	*websocket.Conn
+	readTimeout time.Duration // set by keepAlive
+
+	wmu       sync.Mutex
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+// dialExec opens an exec WebSocket to rawURL, an http or https admin URL. If
+// the server refuses the upgrade, its response is returned with the error.
+func dialExec(ctx context.Context, rawURL string, header http.Header) (*execConn, *http.Response, error) {
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(rawURL, "http"), header)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &execConn{Conn: conn, done: make(chan struct{})}, resp, nil
+}
+
+func (c *execConn) WriteMessage(typ int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.Conn.WriteMessage(typ, data)
+}
+
+// ReadMessage returns the next text or binary message, and pushes the read
+// deadline back if keepAlive is on.
+func (c *execConn) ReadMessage() (int, []byte, error) {
+	typ, msg, err := c.Conn.ReadMessage()
+	if err == nil {
+		c.extendDeadline()
+	}
+	return typ, msg, err
+}
+
+// keepAlive pings the server every interval until the connection is closed,
+// and fails ReadMessage once nothing, not even a pong, has arrived for twice
+// that. Call it before the first ReadMessage.
+func (c *execConn) keepAlive(interval time.Duration) {
+	c.readTimeout = 2 * interval
+	c.extendDeadline()
+	c.SetPongHandler(func(string) error {
+		c.extendDeadline()
+		return nil
+	})
+	c.SetPingHandler(func(data string) error {
+		c.extendDeadline()
+		err := c.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-t.C:
+				if c.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)) != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (c *execConn) extendDeadline() {
+	if c.readTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// Close closes the connection without a close handshake.
+func (c *execConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
@@ -0,0 +1,7 @@
+package main
+
+// watchResize does nothing on Windows, which has no resize signal; the
+// terminal keeps the size it had when the session started.
+func watchResize(fn func()) (stop func()) {
+	return func() {}
+}
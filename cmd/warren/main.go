@@ -2,16 +2,22 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/moby/term"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
@@ -19,8 +25,9 @@ import (
 )
 
 var (
-	adminURL string
-	format   string
+	adminURL   string
+	adminToken string
+	format     string
 )
 
 func main() {
@@ -30,6 +37,7 @@ func main() {
 	}
 
 	root.PersistentFlags().StringVar(&adminURL, "admin", "", "admin API URL (default http://localhost:9090)")
+	root.PersistentFlags().StringVar(&adminToken, "admin-token", "", "admin API bearer token")
 	root.PersistentFlags().StringVar(&format, "format", "table", "output format: table or json")
 
 	// Agent commands
@@ -42,7 +50,9 @@ func main() {
 		agentInspectCmd(),
 		agentWakeCmd(),
 		agentSleepCmd(),
+		agentResetCmd(),
 		agentLogsCmd(),
+		agentExecCmd(),
 	)
 
 	// Service commands
@@ -68,6 +78,10 @@ func main() {
 	)
 
 	if err := root.Execute(); err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
@@ -93,39 +107,46 @@ func getAdminURL() string {
 	return "http://localhost:9090"
 }
 
-func apiGet(path string) ([]byte, error) {
-	resp, err := http.Get(getAdminURL() + path)
-	if err != nil {
-		return nil, err
+// getAdminToken returns the admin API bearer token from --admin-token,
+// WARREN_ADMIN_TOKEN or admin_token in ~/.warren/config.yaml, if any.
+func getAdminToken() string {
+	if adminToken != "" {
+		return adminToken
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	if v := os.Getenv("WARREN_ADMIN_TOKEN"); v != "" {
+		return v
 	}
-	return body, nil
+	home, _ := os.UserHomeDir()
+	data, err := os.ReadFile(home + "/.warren/config.yaml")
+	if err == nil {
+		var cfg struct {
+			AdminToken string `yaml:"admin_token"`
+		}
+		if yaml.Unmarshal(data, &cfg) == nil {
+			return cfg.AdminToken
+		}
+	}
+	return ""
 }
 
-func apiPost(path string, payload any) ([]byte, error) {
-	var body io.Reader
-	if payload != nil {
-		data, _ := json.Marshal(payload)
-		body = strings.NewReader(string(data))
+// authorize adds the admin token, if one is set, to a request's headers.
+func authorize(h http.Header) {
+	if token := getAdminToken(); token != "" {
+		h.Set("Authorization", "Bearer "+token)
 	}
-	resp, err := http.Post(getAdminURL()+path, "application/json", body)
+}
+
+// apiDo sends a request to the admin API and returns the response body,
+// turning error statuses into errors.
+func apiDo(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, getAdminURL()+path, body)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	b, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(b))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	return b, nil
-}
-
-func apiDelete(path string) ([]byte, error) {
-	req, _ := http.NewRequest(http.MethodDelete, getAdminURL()+path, nil)
+	authorize(req.Header)
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -138,6 +159,23 @@ func apiDelete(path string) ([]byte, error) {
 	return b, nil
 }
 
+func apiGet(path string) ([]byte, error) {
+	return apiDo(http.MethodGet, path, nil)
+}
+
+func apiPost(path string, payload any) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		data, _ := json.Marshal(payload)
+		body = strings.NewReader(string(data))
+	}
+	return apiDo(http.MethodPost, path, body)
+}
+
+func apiDelete(path string) ([]byte, error) {
+	return apiDo(http.MethodDelete, path, nil)
+}
+
 func agentListCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "list",
@@ -340,32 +378,250 @@ func agentSleepCmd() *cobra.Command {
 	}
 }
 
-func agentLogsCmd() *cobra.Command {
+func agentResetCmd() *cobra.Command {
 	return &cobra.Command{
+		Use:   "reset <name>",
+		Short: "Clear a degraded agent and start it again",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resp, err := apiPost("/admin/agents/"+args[0]+"/reset", nil)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(resp))
+			return nil
+		},
+	}
+}
+
+func agentLogsCmd() *cobra.Command {
+	var follow bool
+	var since, tail string
+
+	cmd := &cobra.Command{
 		Use:   "logs <name>",
-		Short: "Tail Docker service logs",
+		Short: "Stream an agent's logs through the admin API",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// First get agent info to find container name.
-			data, err := apiGet("/admin/agents/" + args[0])
+			q := url.Values{}
+			q.Set("follow", strconv.FormatBool(follow))
+			if since != "" {
+				q.Set("since", since)
+			}
+			if tail != "" {
+				q.Set("tail", tail)
+			}
+			req, err := http.NewRequest(http.MethodGet, getAdminURL()+"/admin/agents/"+url.PathEscape(args[0])+"/logs?"+q.Encode(), nil)
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Accept", "text/event-stream")
+			authorize(req.Header)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				body, _ := io.ReadAll(resp.Body)
+				return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+			}
+			return printLogEvents(resp.Body, os.Stdout, cmd.ErrOrStderr())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", true, "keep streaming new output")
+	cmd.Flags().StringVar(&since, "since", "", "only output since a time (RFC 3339, Unix seconds or a duration such as 10m)")
+	cmd.Flags().StringVar(&tail, "tail", "all", "number of lines to show from the end of the logs")
+
+	return cmd
+}
+
+// printLogEvents writes the lines of a log event stream to stdout or stderr
+// by their event type, until the stream ends or reports an error.
+func printLogEvents(r io.Reader, stdout, stderr io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = line[7:]
+		case strings.HasPrefix(line, "data: "):
+			data := line[6:]
+			switch event {
+			case "stderr":
+				fmt.Fprintln(stderr, data)
+			case "error":
+				return fmt.Errorf("log stream failed: %s", data)
+			default:
+				fmt.Fprintln(stdout, data)
+			}
+		case line == "":
+			event = ""
+		}
+	}
+	return scanner.Err()
+}
+
+// exitCodeError makes the CLI exit with a command's exit code.
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func agentExecCmd() *cobra.Command {
+	var interactive, tty bool
+
+	cmd := &cobra.Command{
+		Use:   "exec <name> -- <command> [args...]",
+		Short: "Run a command in an agent's container through the admin API",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stdinFd, stdinIsTerm := term.GetFdInfo(os.Stdin)
+			stdoutFd, _ := term.GetFdInfo(os.Stdout)
+			q := url.Values{"cmd": args[1:]}
+			if tty {
+				q.Set("tty", "true")
+				if ws, err := term.GetWinsize(stdoutFd); err == nil {
+					q.Set("width", strconv.Itoa(int(ws.Width)))
+					q.Set("height", strconv.Itoa(int(ws.Height)))
+				}
+			}
+
+			header := http.Header{}
+			authorize(header)
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			conn, resp, err := dialExec(ctx, getAdminURL()+"/admin/agents/"+url.PathEscape(args[0])+"/exec?"+q.Encode(), header)
+			cancel()
 			if err != nil {
+				if resp != nil {
+					body, _ := io.ReadAll(resp.Body)
+					return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+				}
 				return err
 			}
-			var info struct {
-				ContainerName string `json:"container_name"`
+			defer conn.Close()
+			conn.keepAlive(30 * time.Second)
+
+			if tty && interactive && stdinIsTerm {
+				state, err := term.SetRawTerminal(stdinFd)
+				if err != nil {
+					return err
+				}
+				defer term.RestoreTerminal(stdinFd, state)
+			}
+			if tty {
+				stop := watchResize(func() {
+					if ws, err := term.GetWinsize(stdoutFd); err == nil {
+						sendExecMessage(conn, execMessage{Type: "resize", Width: uint(ws.Width), Height: uint(ws.Height)})
+					}
+				})
+				defer stop()
 			}
-			_ = json.Unmarshal(data, &info)
-			svcName := info.ContainerName
-			if svcName == "" {
-				svcName = args[0]
+			if interactive {
+				go sendExecInput(conn, cmd.InOrStdin())
+			} else {
+				sendExecMessage(conn, execMessage{Type: "stdin_close"})
 			}
 
-			c := exec.Command("docker", "service", "logs", "--follow", svcName)
-			c.Stdout = os.Stdout
-			c.Stderr = os.Stderr
-			return c.Run()
+			code, err := readExecOutput(conn, os.Stdout, cmd.ErrOrStderr())
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return &exitCodeError{code: code}
+			}
+			return nil
 		},
 	}
+
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "send stdin to the command")
+	cmd.Flags().BoolVarP(&tty, "tty", "t", false, "allocate a terminal for the command")
+
+	return cmd
+}
+
+// execMessage is a control message on an exec session's WebSocket. Output
+// arrives as binary messages prefixed with a stream byte, 1 for stdout and
+// 2 for stderr.
+type execMessage struct {
+	Type   string `json:"type"`
+	Width  uint   `json:"width,omitempty"`
+	Height uint   `json:"height,omitempty"`
+	Code   *int   `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+const execStderr = 2
+
+// sendExecInput sends r to an exec session as the command's input, and tells
+// the session when it ends.
+func sendExecInput(conn *execConn, r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if conn.WriteMessage(websocket.BinaryMessage, buf[:n]) != nil {
+				return
+			}
+		}
+		if err != nil {
+			sendExecMessage(conn, execMessage{Type: "stdin_close"})
+			return
+		}
+	}
+}
+
+func sendExecMessage(conn *execConn, m execMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// readExecOutput copies an exec session's output to stdout and stderr until
+// the session ends, and returns the command's exit code.
+func readExecOutput(conn *execConn, stdout, stderr io.Writer) (int, error) {
+	var exit *int
+	for {
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			if exit != nil {
+				return *exit, nil
+			}
+			return 0, fmt.Errorf("exec session ended without an exit code: %w", err)
+		}
+		if typ == websocket.BinaryMessage {
+			if len(msg) == 0 {
+				continue
+			}
+			switch msg[0] {
+			case execStderr:
+				stderr.Write(msg[1:])
+			default:
+				stdout.Write(msg[1:])
+			}
+			continue
+		}
+		var m execMessage
+		if err := json.Unmarshal(msg, &m); err != nil {
+			continue
+		}
+		switch m.Type {
+		case "exit":
+			exit = m.Code
+		case "error":
+			return 0, fmt.Errorf("exec failed: %s", m.Error)
+		}
+	}
 }
 
 func serviceListCmd() *cobra.Command {
@@ -508,7 +764,12 @@ func eventsCmd() *cobra.Command {
 		Use:   "events",
 		Short: "Stream events from the orchestrator (SSE)",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			resp, err := http.Get(getAdminURL() + "/admin/events")
+			req, err := http.NewRequest(http.MethodGet, getAdminURL()+"/admin/events", nil)
+			if err != nil {
+				return err
+			}
+			authorize(req.Header)
+			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				return err
 			}
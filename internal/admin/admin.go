@@ -8,6 +8,7 @@ import (
 	"os"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -23,6 +24,7 @@ import (
 	"warren/internal/policy"
 	"warren/internal/process"
 	"warren/internal/proxy"
+	"warren/internal/security"
 	"warren/internal/services"
 )
 
@@ -30,6 +32,7 @@ import (
 type AgentInfo struct {
 	Name          string `json:"name"`
 	Hostname      string `json:"hostname"`
+	PathPrefix    string `json:"path_prefix,omitempty"`
 	Policy        string `json:"policy"`
 	Backend       string `json:"backend"`
 	ContainerName string `json:"container_name,omitempty"`
@@ -41,6 +44,8 @@ type AgentInfo struct {
 type AddAgentRequest struct {
 	Name          string `json:"name"`
 	Hostname      string `json:"hostname"`
+	PathPrefix    string `json:"path_prefix"`
+	StripPrefix   bool   `json:"strip_prefix"`
 	Backend       string `json:"backend"`
 	Policy        string `json:"policy"`
 	ContainerName string `json:"container_name"`
@@ -54,6 +59,10 @@ type AgentManager interface {
 	RemoveAgent(name string) error
 }
 
+// RuntimeResolver returns what manages an agent and the name it is managed
+// under, or false if it doesn't know the agent.
+type RuntimeResolver func(agent string) (container.Lifecycle, string, bool)
+
 // Server is the admin API server.
 type Server struct {
 	mu        sync.RWMutex
@@ -62,7 +71,7 @@ type Server struct {
 	cancels   map[string]context.CancelFunc
 	registry  *services.Registry
 	events    *events.Emitter
-	manager   *container.Manager
+	manager   container.Lifecycle
 	prxy      *proxy.Proxy
 	cfg       *config.Config
 	cfgPath   string
@@ -72,6 +81,9 @@ type Server struct {
 	wsTotal   func() int64
 	hermes    *hermes.Client
 	procTracker *process.Tracker
+	deps      *policy.Dependencies
+	resources *policy.ResourceMonitor
+	runtimes  RuntimeResolver
 }
 
 // NewServer creates a new admin server.
@@ -81,13 +93,15 @@ func NewServer(
 	cancels map[string]context.CancelFunc,
 	registry *services.Registry,
 	emitter *events.Emitter,
-	manager *container.Manager,
+	manager container.Lifecycle,
 	prxy *proxy.Proxy,
 	cfg *config.Config,
 	cfgPath string,
 	wsTotal func() int64,
 	hermes *hermes.Client,
 	procTracker *process.Tracker,
+	deps *policy.Dependencies,
+	resources *policy.ResourceMonitor,
 	logger *slog.Logger,
 ) *Server {
 	l := logger.With("component", "admin")
@@ -108,6 +122,8 @@ func NewServer(
 		wsTotal:     wsTotal,
 		hermes:      hermes,
 		procTracker: procTracker,
+		deps:        deps,
+		resources:   resources,
 		logger:      l,
 		startAt:     time.Now(),
 	}
@@ -177,7 +193,7 @@ func (s *Server) listAgents(w http.ResponseWriter, _ *http.Request) {
 		}
 		var conns int64
 		if s.prxy != nil {
-			conns = s.prxy.WSCounter().Count(info.Hostname)
+			conns = s.prxy.WSCounter().Count(services.RouteKey(info.Hostname, info.PathPrefix))
 		}
 		result = append(result, agentResp{AgentInfo: info, Type: "container", State: state, Connections: conns})
 	}
@@ -208,6 +224,7 @@ func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req.Hostname = strings.ToLower(req.Hostname)
 	if req.Name == "" || req.Hostname == "" || req.Backend == "" || req.Policy == "" {
 		http.Error(w, `{"error":"name, hostname, backend, and policy are required"}`, http.StatusBadRequest)
 		return
@@ -236,6 +253,12 @@ func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := security.ValidatePathPrefix(req.PathPrefix); err != nil {
+		http.Error(w, `{"error":"invalid path_prefix"}`, http.StatusBadRequest)
+		return
+	}
+	prefix := services.NormalizePathPrefix(req.PathPrefix)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -260,22 +283,29 @@ func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
 
 	switch req.Policy {
 	case "always-on":
-		pol = policy.NewAlwaysOn(policy.AlwaysOnConfig{
-			Agent:         req.Name,
-			HealthURL:     req.HealthURL,
-			CheckInterval: 30 * time.Second,
-			MaxFailures:   3,
+		pol = policy.NewAlwaysOn(s.manager, policy.AlwaysOnConfig{
+			Agent:              req.Name,
+			ContainerName:      req.ContainerName,
+			HealthURL:          req.HealthURL,
+			CheckInterval:      30 * time.Second,
+			MaxFailures:        3,
+			MaxRestartAttempts: 10,
+			RecoveryBackoff:    10 * time.Second,
+			RecoveryMaxBackoff: 5 * time.Minute,
 		}, s.events, s.logger)
 	case "on-demand":
 		pol = policy.NewOnDemand(s.manager, policy.OnDemandConfig{
 			Agent:              req.Name,
 			ContainerName:      req.ContainerName,
 			HealthURL:          req.HealthURL,
-			Hostname:           req.Hostname,
+			Hostname:           services.RouteKey(req.Hostname, prefix),
 			CheckInterval:      30 * time.Second,
 			StartupTimeout:     60 * time.Second,
 			IdleTimeout:        idleTimeout,
 			WakeCooldown:       30 * time.Second,
+			DrainTimeout:       30 * time.Second,
+			RecoveryBackoff:    10 * time.Second,
+			RecoveryMaxBackoff: 5 * time.Minute,
 			MaxFailures:        3,
 			MaxRestartAttempts: 10,
 		}, s.prxy.Activity(), s.prxy.WSCounter(), s.events, s.logger)
@@ -284,7 +314,10 @@ func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Register in proxy.
-	s.prxy.Register(req.Hostname, req.Name, target, pol)
+	s.prxy.RegisterWithOptions(req.Hostname, req.Name, target, pol, proxy.BackendOptions{
+		PathPrefix:  prefix,
+		StripPrefix: req.StripPrefix,
+	})
 
 	// Start policy goroutine.
 	go pol.Start(ctx)
@@ -293,6 +326,7 @@ func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
 	s.agents[req.Name] = AgentInfo{
 		Name:          req.Name,
 		Hostname:      req.Hostname,
+		PathPrefix:    prefix,
 		Policy:        req.Policy,
 		Backend:       req.Backend,
 		ContainerName: req.ContainerName,
@@ -301,15 +335,26 @@ func (s *Server) addAgent(w http.ResponseWriter, r *http.Request) {
 	}
 	s.policies[req.Name] = pol
 	s.cancels[req.Name] = cancel
+	if s.deps != nil {
+		s.deps.Register(req.Name, pol, nil)
+	}
+	if s.resources != nil && req.ContainerName != "" {
+		s.resources.Register(req.Name, req.ContainerName)
+		if od, ok := pol.(*policy.OnDemand); ok {
+			od.SetWakeGate(s.resources)
+		}
+	}
 
 	// Persist to config.
 	agent := &config.Agent{
-		Hostname: req.Hostname,
-		Backend:  req.Backend,
-		Policy:   req.Policy,
+		Hostname:    req.Hostname,
+		PathPrefix:  prefix,
+		StripPrefix: req.StripPrefix,
+		Backend:     req.Backend,
+		Policy:      req.Policy,
 		Container: config.Container{Name: req.ContainerName},
 		Health: config.Health{
-			URL:                req.HealthURL,
+			Probe:              config.Probe{URL: req.HealthURL},
 			CheckInterval:      30 * time.Second,
 			StartupTimeout:     60 * time.Second,
 			MaxFailures:        3,
@@ -376,12 +421,18 @@ func (s *Server) handleAgent(w http.ResponseWriter, r *http.Request) {
 			state = pol.State()
 		}
 		var conns int64
+		var targets []proxy.TargetStatus
 		if s.prxy != nil {
-			conns = s.prxy.WSCounter().Count(info.Hostname)
+			key := services.RouteKey(info.Hostname, info.PathPrefix)
+			conns = s.prxy.WSCounter().Count(key)
+			if b, ok := s.prxy.Backends()[key]; ok {
+				targets = b.Pool.Targets()
+			}
 		}
-		_ = json.NewEncoder(w).Encode(map[string]any{
+		detail := map[string]any{
 			"name":           info.Name,
 			"hostname":       info.Hostname,
+			"path_prefix":    info.PathPrefix,
 			"policy":         info.Policy,
 			"backend":        info.Backend,
 			"container_name": info.ContainerName,
@@ -389,7 +440,37 @@ func (s *Server) handleAgent(w http.ResponseWriter, r *http.Request) {
 			"idle_timeout":   info.IdleTimeout,
 			"state":          state,
 			"connections":    conns,
-		})
+			"targets":        targets,
+		}
+		if as, ok := pol.(*policy.Autoscale); ok {
+			detail["replicas"] = as.Replicas()
+		}
+		if ao, ok := pol.(*policy.AlwaysOn); ok {
+			detail["restarts"] = ao.Restarts()
+		}
+		if od, ok := pol.(*policy.OnDemand); ok && od.Schedule() != nil {
+			detail["schedule"] = od.Schedule().Status(time.Now())
+		}
+		if s.resources != nil {
+			if st, ok := s.resources.Stats(name); ok {
+				detail["resources"] = st
+			}
+		}
+		if s.deps != nil {
+			if tree := s.deps.Tree(name); len(tree.DependsOn) > 0 {
+				detail["depends_on"] = tree.DependsOn
+			}
+			if dependents := s.deps.Dependents(name); len(dependents) > 0 {
+				detail["dependents"] = dependents
+			}
+		}
+		_ = json.NewEncoder(w).Encode(detail)
+
+	case r.Method == http.MethodGet && action == "logs":
+		s.streamLogs(w, r, info)
+
+	case r.Method == http.MethodGet && action == "exec":
+		s.handleExec(w, r, info)
 
 	case r.Method == http.MethodPost && action == "wake":
 		od, ok := pol.(*policy.OnDemand)
@@ -397,9 +478,31 @@ func (s *Server) handleAgent(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, `{"error":"agent is not on-demand"}`, http.StatusBadRequest)
 			return
 		}
+		if reason, until := od.WakeRefused(); reason != "" {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "wake refused: " + reason, "until": until})
+			return
+		}
 		od.Wake()
 		_ = json.NewEncoder(w).Encode(map[string]string{"status": "waking"})
 
+	case r.Method == http.MethodPost && action == "reset":
+		resetter, ok := pol.(policy.Resetter)
+		if !ok {
+			http.Error(w, `{"error":"agent policy does not support reset"}`, http.StatusBadRequest)
+			return
+		}
+		if err := resetter.Reset(r.Context()); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, policy.ErrNotDegraded) {
+				status = http.StatusConflict
+			}
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": pol.State()})
+
 	case r.Method == http.MethodPost && action == "sleep":
 		od, ok := pol.(*policy.OnDemand)
 		if !ok {
@@ -407,7 +510,7 @@ func (s *Server) handleAgent(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		od.Sleep(r.Context())
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "sleeping"})
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": od.State()})
 
 	default:
 		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
@@ -418,12 +521,25 @@ func (s *Server) removeAgent(w http.ResponseWriter, name string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	info, ok := s.agents[name]
-	if !ok {
+	if _, ok := s.agents[name]; !ok {
 		http.Error(w, `{"error":"agent not found"}`, http.StatusNotFound)
 		return
 	}
 
+	// Removing a dependency would leave depends_on pointing at nothing.
+	if s.deps != nil {
+		if dependents := s.deps.Dependents(name); len(dependents) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{"error": "agent has dependents", "dependents": dependents})
+			return
+		}
+		s.deps.Unregister(name)
+	}
+	if s.resources != nil {
+		s.resources.Unregister(name)
+	}
+
 	// Cancel policy goroutine.
 	if cancel, ok := s.cancels[name]; ok {
 		cancel()
@@ -431,7 +547,7 @@ func (s *Server) removeAgent(w http.ResponseWriter, name string) {
 	}
 
 	// Deregister from proxy.
-	s.prxy.Deregister(info.Hostname)
+	s.prxy.DeregisterAgent(name)
 
 	// Remove from admin state.
 	delete(s.agents, name)
@@ -530,6 +646,31 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// SetRuntimeResolver sets how the logs and exec endpoints find what manages
+// an agent. Without one, or for agents it doesn't know, such as those added
+// through the API, the agent's container is used through the server's
+// manager.
+func (s *Server) SetRuntimeResolver(resolve RuntimeResolver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runtimes = resolve
+}
+
+func (s *Server) runtimeFor(info AgentInfo) (container.Lifecycle, string, bool) {
+	s.mu.RLock()
+	resolve := s.runtimes
+	s.mu.RUnlock()
+	if resolve != nil {
+		if runtime, managed, ok := resolve(info.Name); ok {
+			return runtime, managed, true
+		}
+	}
+	if s.manager == nil || info.ContainerName == "" {
+		return nil, "", false
+	}
+	return s.manager, info.ContainerName, true
+}
+
 // AddAgent adds an agent dynamically (used by SIGHUP reload).
 func (s *Server) AddAgent(name string, info AgentInfo, pol policy.Policy, cancel context.CancelFunc) {
 	s.mu.Lock()
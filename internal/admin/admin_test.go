@@ -50,6 +50,8 @@ func testServer(t *testing.T) (*Server, string) {
 		func() int64 { return 0 },
 		nil, // no hermes client in tests
 		nil, // no process tracker in tests
+		policy.NewDependencies(logger),
+		nil, // no resource sampling in tests
 		logger,
 	)
 	return srv, tmpFile.Name()
@@ -164,6 +166,39 @@ func TestAddAgentDuplicate(t *testing.T) {
 	}
 }
 
+func TestAgentDependencies(t *testing.T) {
+	srv, _ := testServer(t)
+	handler := srv.Handler()
+
+	for name, deps := range map[string][]string{"app": {"db"}, "db": nil} {
+		pol := policy.NewUnmanaged()
+		srv.AddAgent(name, AgentInfo{Name: name, Hostname: name + ".example.com", Policy: "unmanaged"}, pol, func() {})
+		srv.deps.Register(name, pol, deps)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/agents/app", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var detail struct {
+		DependsOn []policy.DependencyNode `json:"depends_on"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &detail)
+	if len(detail.DependsOn) != 1 || detail.DependsOn[0].Name != "db" || detail.DependsOn[0].State != "ready" {
+		t.Fatalf("depends_on = %+v, want [db ready]", detail.DependsOn)
+	}
+
+	// A dependency can't be removed while something depends on it.
+	req = httptest.NewRequest("DELETE", "/admin/agents/db", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != 409 {
+		t.Fatalf("remove dependency: expected 409, got %d", w.Code)
+	}
+}
+
 func TestAddAgentValidation(t *testing.T) {
 	srv, _ := testServer(t)
 	handler := srv.Handler()
@@ -215,3 +250,25 @@ func TestSSEEndpoint(t *testing.T) {
 	// with httptest.NewRecorder, but we verify it doesn't panic.
 	// In a real test we'd use a pipe-based approach.
 }
+
+func TestServicesShowPathPrefix(t *testing.T) {
+	srv, _ := testServer(t)
+	handler := srv.Handler()
+
+	if err := srv.registry.RegisterWithOptions("api.example.com", "http://10.0.0.5:3000", "dev", services.ServiceOptions{PathPrefix: "/preview/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/services", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var list []map[string]any
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(list))
+	}
+	if list[0]["path_prefix"] != "/preview" {
+		t.Errorf("path_prefix = %v, want /preview", list[0]["path_prefix"])
+	}
+}
@@ -42,6 +42,8 @@ func testServerWithToken(t *testing.T, token string) *Server {
 		func() int64 { return 0 },
 		nil, // no hermes client in tests
 		nil, // no process tracker in tests
+		nil, // no dependency graph
+		nil, // no resource sampling
 		logger,
 	)
 }
@@ -0,0 +1,329 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"warren/internal/container"
+	"warren/internal/events"
+)
+
+// Exec output is sent as binary WebSocket messages whose first byte says
+// which stream the rest came from, as in Docker's multiplexed streams.
+const (
+	ExecStdout byte = 1
+	ExecStderr byte = 2
+)
+
+// ExecMessage is a control message on an exec WebSocket, sent as JSON text.
+// Clients send "resize" with the terminal size and "stdin_close" when their
+// input ends; input itself is sent as binary messages. The server ends the
+// session with "exit" and the command's exit code, or "error".
+type ExecMessage struct {
+	Type   string `json:"type"`
+	Width  uint   `json:"width,omitempty"`
+	Height uint   `json:"height,omitempty"`
+	Code   *int   `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// execCloseWait is how long the server waits for the client to acknowledge
+// the close after sending the exit code.
+const execCloseWait = time.Second
+
+// execPingInterval is how often the server pings an exec client. A client
+// that sends nothing, not even a pong, for twice this is disconnected.
+const execPingInterval = 30 * time.Second
+
+// execMaxMessage is the largest message an exec client may send.
+const execMaxMessage = 1 << 20
+
+// execUpgrader accepts exec WebSockets. Its default origin check refuses
+// browsers on other sites; the CLI sends no Origin and is let through.
+var execUpgrader = websocket.Upgrader{}
+
+// handleExec serves GET /admin/agents/{name}/exec?cmd=…&cmd=…&tty=&width=&height=,
+// running the command in the agent's container and attaching it to a
+// WebSocket. It is refused unless the admin API requires a token, and every
+// session is recorded with agent.exec events when it starts and ends.
+func (s *Server) handleExec(w http.ResponseWriter, r *http.Request, info AgentInfo) {
+	if s.authToken == "" {
+		http.Error(w, `{"error":"exec requires admin_token to be set"}`, http.StatusForbidden)
+		return
+	}
+	opts, err := parseExecOptions(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	runtime, managed, ok := s.runtimeFor(info)
+	execer, isExecer := runtime.(container.Execer)
+	if !ok || !isExecer {
+		http.Error(w, `{"error":"agent does not run in a container"}`, http.StatusBadRequest)
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		http.Error(w, `{"error":"websocket upgrade required"}`, http.StatusBadRequest)
+		return
+	}
+
+	session, err := execer.Exec(r.Context(), managed, opts)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, container.ErrNoRunningTask) {
+			status = http.StatusConflict
+		}
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), status)
+		return
+	}
+	conn, err := execUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		session.Close()
+		return
+	}
+	conn.SetReadLimit(execMaxMessage)
+	ws := newExecConn(conn)
+	ws.keepAlive(execPingInterval)
+
+	// The request's context ends when the handler returns, not with the
+	// hijacked connection, so the session gets its own.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+	started := time.Now()
+	command := strings.Join(opts.Cmd, " ")
+	sessionFields := func(phase string) map[string]string {
+		return map[string]string{
+			"phase":       phase,
+			"command":     command,
+			"container":   session.Container(),
+			"tty":         strconv.FormatBool(opts.TTY),
+			"remote_addr": r.RemoteAddr,
+		}
+	}
+	s.logger.Info("exec session started", "agent", info.Name, "container", session.Container(), "command", command, "tty", opts.TTY, "remote_addr", r.RemoteAddr)
+	s.events.Emit(events.Event{Type: events.AgentExec, Agent: info.Name, Fields: sessionFields("started")})
+
+	code, err := runExec(ctx, ws, session)
+
+	fields := sessionFields("ended")
+	fields["duration"] = time.Since(started).Round(time.Millisecond).String()
+	if err != nil {
+		fields["error"] = err.Error()
+	} else {
+		fields["exit_code"] = strconv.Itoa(code)
+	}
+	s.logger.Info("exec session ended", "agent", info.Name, "exit_code", fields["exit_code"], "error", fields["error"])
+	s.events.Emit(events.Event{Type: events.AgentExec, Agent: info.Name, Fields: fields})
+}
+
+// parseExecOptions reads the cmd, tty, width and height query parameters.
+func parseExecOptions(r *http.Request) (container.ExecOptions, error) {
+	q := r.URL.Query()
+	opts := container.ExecOptions{Cmd: q["cmd"]}
+	if len(opts.Cmd) == 0 {
+		return opts, errors.New("cmd is required")
+	}
+	if v := q.Get("tty"); v != "" {
+		tty, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid tty %q", v)
+		}
+		opts.TTY = tty
+	}
+	for name, dst := range map[string]*uint{"width": &opts.Width, "height": &opts.Height} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 16)
+			if err != nil {
+				return opts, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = uint(n)
+		}
+	}
+	return opts, nil
+}
+
+// runExec relays between ws and session until the command exits or the
+// client goes away, and returns the command's exit code. A client that
+// disconnects first detaches from the command, leaving its exit code
+// unknown.
+func runExec(ctx context.Context, ws *execConn, session container.ExecSession) (int, error) {
+	defer ws.Close()
+	defer session.Close()
+
+	output := make(chan error, 1)
+	go func() {
+		output <- session.Output(execWriter{ws, ExecStdout}, execWriter{ws, ExecStderr})
+	}()
+	input := make(chan error, 1)
+	go func() {
+		input <- relayExecInput(ctx, ws, session)
+	}()
+
+	select {
+	case <-output:
+	case err := <-input:
+		session.Close()
+		<-output
+		var ce *websocket.CloseError
+		if errors.As(err, &ce) {
+			return 0, errors.New("client closed the session")
+		}
+		return 0, fmt.Errorf("client connection: %w", err)
+	}
+
+	code, err := session.ExitCode(ctx)
+	if err != nil {
+		writeExecMessage(ws, ExecMessage{Type: "error", Error: err.Error()})
+		ws.writeClose(websocket.CloseInternalServerErr)
+		return 0, err
+	}
+	writeExecMessage(ws, ExecMessage{Type: "exit", Code: &code})
+	ws.writeClose(websocket.CloseNormalClosure)
+	select {
+	case <-input:
+	case <-time.After(execCloseWait):
+	}
+	return code, nil
+}
+
+// relayExecInput passes the client's messages to the session until the
+// connection fails or closes.
+func relayExecInput(ctx context.Context, ws *execConn, session container.ExecSession) error {
+	for {
+		typ, msg, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if typ == websocket.BinaryMessage {
+			session.Write(msg) // fails once the command has exited, which Output reports
+			continue
+		}
+		var m ExecMessage
+		if err := json.Unmarshal(msg, &m); err != nil {
+			continue
+		}
+		switch m.Type {
+		case "resize":
+			session.Resize(ctx, m.Width, m.Height)
+		case "stdin_close":
+			session.CloseStdin()
+		}
+	}
+}
+
+// execWriter sends one of the command's output streams over the WebSocket.
+type execWriter struct {
+	ws     *execConn
+	stream byte
+}
+
+func (e execWriter) Write(p []byte) (int, error) {
+	msg := make([]byte, 0, len(p)+1)
+	msg = append(msg, e.stream)
+	msg = append(msg, p...)
+	if err := e.ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func writeExecMessage(ws *execConn, m ExecMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// execConn is an exec session's WebSocket. The command's output, the exit
+// message and the close are written from different goroutines, and
+// gorilla/websocket allows only one writer at a time, so writes are
+// serialised here.
+type execConn struct {
+	*websocket.Conn
+	readTimeout time.Duration // set by keepAlive
+
+	wmu       sync.Mutex
+	done      chan struct{} // closed by Close
+	closeOnce sync.Once
+}
+
+func newExecConn(conn *websocket.Conn) *execConn {
+	return &execConn{Conn: conn, done: make(chan struct{})}
+}
+
+func (c *execConn) WriteMessage(typ int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.Conn.WriteMessage(typ, data)
+}
+
+// ReadMessage returns the next text or binary message, and pushes the read
+// deadline back if keepAlive is on.
+func (c *execConn) ReadMessage() (int, []byte, error) {
+	typ, msg, err := c.Conn.ReadMessage()
+	if err == nil {
+		c.extendDeadline()
+	}
+	return typ, msg, err
+}
+
+// keepAlive pings the client every interval until the connection is closed,
+// and fails ReadMessage once nothing, not even a pong, has arrived for twice
+// that. Call it before the first ReadMessage.
+func (c *execConn) keepAlive(interval time.Duration) {
+	c.readTimeout = 2 * interval
+	c.extendDeadline()
+	c.SetPongHandler(func(string) error {
+		c.extendDeadline()
+		return nil
+	})
+	c.SetPingHandler(func(data string) error {
+		c.extendDeadline()
+		err := c.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-t.C:
+				if c.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)) != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (c *execConn) extendDeadline() {
+	if c.readTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// writeClose starts the close handshake with code.
+func (c *execConn) writeClose(code int) error {
+	return c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(execCloseWait))
+}
+
+// Close closes the connection without a close handshake.
+func (c *execConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
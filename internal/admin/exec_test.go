@@ -0,0 +1,229 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"warren/internal/container"
+	"warren/internal/events"
+)
+
+// fakeExecer runs a fake command that echoes its input to stdout and says
+// "warn" on stderr.
+type fakeExecer struct {
+	container.Lifecycle // unused methods panic
+
+	name    string
+	opts    container.ExecOptions
+	resized chan [2]uint
+}
+
+func (f *fakeExecer) Exec(_ context.Context, name string, opts container.ExecOptions) (container.ExecSession, error) {
+	f.name, f.opts = name, opts
+	pr, pw := io.Pipe()
+	return &fakeExecSession{stdin: pr, stdinW: pw, resized: f.resized}, nil
+}
+
+type fakeExecSession struct {
+	stdin   *io.PipeReader
+	stdinW  *io.PipeWriter
+	resized chan [2]uint
+}
+
+func (f *fakeExecSession) Container() string                     { return "c0ffee" }
+func (f *fakeExecSession) Write(p []byte) (int, error)           { return f.stdinW.Write(p) }
+func (f *fakeExecSession) CloseStdin() error                     { return f.stdinW.Close() }
+func (f *fakeExecSession) Close() error                          { return f.stdin.Close() }
+func (f *fakeExecSession) ExitCode(context.Context) (int, error) { return 3, nil }
+
+func (f *fakeExecSession) Output(stdout, stderr io.Writer) error {
+	io.WriteString(stderr, "warn")
+	_, err := io.Copy(stdout, f.stdin)
+	return err
+}
+
+func (f *fakeExecSession) Resize(_ context.Context, width, height uint) error {
+	f.resized <- [2]uint{width, height}
+	return nil
+}
+
+func execServer(t *testing.T, token string) (*Server, *fakeExecer, string) {
+	t.Helper()
+	srv := testServerWithToken(t, token)
+	execer := &fakeExecer{resized: make(chan [2]uint, 1)}
+	srv.AddAgent("research", AgentInfo{Name: "research"}, nil, nil)
+	srv.SetRuntimeResolver(func(agent string) (container.Lifecycle, string, bool) {
+		return execer, "openclaw-research", agent == "research"
+	})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return srv, execer, ts.URL
+}
+
+func TestAgentExec(t *testing.T) {
+	srv, execer, url := execServer(t, "secret-token")
+	audit := make(chan events.Event, 2)
+	srv.events.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentExec {
+			audit <- ev
+		}
+	})
+
+	header := http.Header{"Authorization": {"Bearer secret-token"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ws, _, err := dialExec(ctx, url+"/admin/agents/research/exec?cmd=sh&cmd=-c&cmd=cat&tty=true&width=80&height=24", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if execer.name != "openclaw-research" || strings.Join(execer.opts.Cmd, " ") != "sh -c cat" ||
+		!execer.opts.TTY || execer.opts.Width != 80 || execer.opts.Height != 24 {
+		t.Errorf("exec %q with %+v", execer.name, execer.opts)
+	}
+
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type":"resize","width":100,"height":30}`))
+	if got := <-execer.resized; got != [2]uint{100, 30} {
+		t.Errorf("resized to %v", got)
+	}
+	ws.WriteMessage(websocket.BinaryMessage, []byte("hello"))
+	ws.WriteMessage(websocket.TextMessage, []byte(`{"type":"stdin_close"}`))
+
+	var stdout, stderr string
+	var exit ExecMessage
+	for {
+		typ, msg, err := ws.ReadMessage()
+		if err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				t.Fatal(err)
+			}
+			break
+		}
+		switch {
+		case typ == websocket.TextMessage:
+			json.Unmarshal(msg, &exit)
+		case msg[0] == ExecStdout:
+			stdout += string(msg[1:])
+		case msg[0] == ExecStderr:
+			stderr += string(msg[1:])
+		}
+	}
+	if stdout != "hello" || stderr != "warn" {
+		t.Errorf("stdout = %q, stderr = %q", stdout, stderr)
+	}
+	if exit.Type != "exit" || exit.Code == nil || *exit.Code != 3 {
+		t.Errorf("exit message = %+v", exit)
+	}
+
+	for _, phase := range []string{"started", "ended"} {
+		select {
+		case ev := <-audit:
+			if ev.Agent != "research" || ev.Fields["phase"] != phase || ev.Fields["command"] != "sh -c cat" ||
+				ev.Fields["container"] != "c0ffee" || ev.Fields["tty"] != "true" {
+				t.Errorf("%s audit event = %+v", phase, ev)
+			}
+			if phase == "ended" && ev.Fields["exit_code"] != "3" {
+				t.Errorf("exit_code = %q, want 3", ev.Fields["exit_code"])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no agent.exec %s event", phase)
+		}
+	}
+}
+
+func TestAgentExecErrors(t *testing.T) {
+	_, _, url := execServer(t, "secret-token")
+	_, _, openURL := execServer(t, "")
+	auth := http.Header{"Authorization": {"Bearer secret-token"}}
+
+	tests := []struct {
+		name   string
+		url    string
+		header http.Header
+		code   int
+	}{
+		{"no token", url + "/admin/agents/research/exec?cmd=sh", nil, 401},
+		{"no admin_token", openURL + "/admin/agents/research/exec?cmd=sh", nil, 403},
+		{"no cmd", url + "/admin/agents/research/exec", auth, 400},
+		{"unknown agent", url + "/admin/agents/missing/exec?cmd=sh", auth, 404},
+	}
+	for _, tt := range tests {
+		ws, resp, err := dialExec(context.Background(), tt.url, tt.header)
+		if err == nil {
+			ws.Close()
+			t.Errorf("%s: upgrade succeeded", tt.name)
+			continue
+		}
+		if resp == nil || resp.StatusCode != tt.code {
+			t.Errorf("%s: resp = %v, err = %v, want %d", tt.name, resp, err, tt.code)
+		}
+	}
+}
+
+// dialExec opens a WebSocket to an http:// URL on the test server.
+func dialExec(ctx context.Context, url string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	return websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(url, "http"), header)
+}
+
+func TestExecConnKeepAlive(t *testing.T) {
+	dropped := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := execUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ws := newExecConn(conn)
+		defer ws.Close()
+		ws.keepAlive(20 * time.Millisecond)
+		_, _, err = ws.ReadMessage()
+		dropped <- err
+	}))
+	defer srv.Close()
+
+	// A client that reads answers the pings and stays connected.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	live, _, err := dialExec(ctx, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			if _, _, err := live.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case err := <-dropped:
+		t.Fatalf("responsive client dropped: %v", err)
+	case <-time.After(150 * time.Millisecond):
+	}
+	live.Close()
+	<-dropped
+
+	// One that never reads never answers a ping, and is cut off.
+	silent, _, err := dialExec(ctx, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	select {
+	case err := <-dropped:
+		if err == nil {
+			t.Error("ReadMessage succeeded on a silent client")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("silent client was never dropped")
+	}
+}
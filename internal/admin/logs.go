@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"warren/internal/container"
+)
+
+// streamLogs serves GET /admin/agents/{name}/logs?follow=&since=&tail=. The
+// output is streamed as plain text, or as Server-Sent Events with the stream
+// ("stdout" or "stderr") as the event type when the client accepts them.
+func (s *Server) streamLogs(w http.ResponseWriter, r *http.Request, info AgentInfo) {
+	opts, err := parseLogOptions(r, time.Now())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	runtime, managed, ok := s.runtimeFor(info)
+	source, isSource := runtime.(container.LogSource)
+	if !ok || !isSource {
+		http.Error(w, `{"error":"agent has no logs"}`, http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	out := &logStream{w: w, flusher: flusher, sse: strings.Contains(r.Header.Get("Accept"), "text/event-stream")}
+	stdout, stderr := out.stream("stdout"), out.stream("stderr")
+	err = source.Logs(r.Context(), managed, opts, stdout, stderr)
+	stdout.finish()
+	stderr.finish()
+	switch {
+	case err == nil || errors.Is(err, context.Canceled):
+		out.start()
+	case !out.started:
+		status := http.StatusBadGateway
+		if errors.Is(err, container.ErrSinceUnsupported) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), status)
+	default:
+		s.logger.Warn("log stream failed", "agent", info.Name, "error", err)
+		out.fail(err)
+	}
+}
+
+// parseLogOptions reads the follow, since and tail query parameters. since is
+// an RFC 3339 time, Unix seconds, or a duration before now such as "10m";
+// tail is a line count or "all".
+func parseLogOptions(r *http.Request, now time.Time) (container.LogOptions, error) {
+	q := r.URL.Query()
+	opts := container.LogOptions{Tail: -1}
+	if v := q.Get("follow"); v != "" {
+		follow, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid follow %q", v)
+		}
+		opts.Follow = follow
+	}
+	if v := q.Get("tail"); v != "" && v != "all" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid tail %q: want a line count or \"all\"", v)
+		}
+		opts.Tail = n
+	}
+	if v := q.Get("since"); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			opts.Since = t
+		} else if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			opts.Since = time.Unix(secs, 0)
+		} else if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			opts.Since = now.Add(-d)
+		} else {
+			return opts, fmt.Errorf("invalid since %q: want an RFC 3339 time, Unix seconds or a duration", v)
+		}
+	}
+	return opts, nil
+}
+
+// logStream writes log output to a response. Headers are sent with the first
+// output, so an error before any can still be reported with a status code.
+type logStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+
+	mu      sync.Mutex
+	started bool
+}
+
+func (l *logStream) start() {
+	if l.started {
+		return
+	}
+	l.started = true
+	if l.sse {
+		l.w.Header().Set("Content-Type", "text/event-stream")
+		l.w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		l.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	l.w.WriteHeader(http.StatusOK)
+}
+
+// fail reports an error once output has started. Plain text has no way to,
+// so the stream just ends.
+func (l *logStream) fail(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sse {
+		fmt.Fprintf(l.w, "event: error\ndata: %s\n\n", err)
+		l.flusher.Flush()
+	}
+}
+
+func (l *logStream) stream(name string) *logStreamWriter {
+	return &logStreamWriter{out: l, name: name}
+}
+
+// logStreamWriter writes one of stdout or stderr to a logStream. For SSE,
+// output is sent a line per event, holding back a partial last line until
+// it is completed or the stream ends.
+type logStreamWriter struct {
+	out     *logStream
+	name    string
+	partial []byte
+}
+
+func (lw *logStreamWriter) Write(p []byte) (int, error) {
+	l := lw.out
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start()
+	if !l.sse {
+		n, err := l.w.Write(p)
+		l.flusher.Flush()
+		return n, err
+	}
+
+	lw.partial = append(lw.partial, p...)
+	for {
+		i := bytes.IndexByte(lw.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if err := lw.event(lw.partial[:i]); err != nil {
+			return 0, err
+		}
+		lw.partial = lw.partial[i+1:]
+	}
+	l.flusher.Flush()
+	return len(p), nil
+}
+
+// finish sends a held-back partial line.
+func (lw *logStreamWriter) finish() {
+	l := lw.out
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(lw.partial) > 0 {
+		lw.event(lw.partial)
+		lw.partial = nil
+		l.flusher.Flush()
+	}
+}
+
+func (lw *logStreamWriter) event(line []byte) error {
+	_, err := fmt.Fprintf(lw.out.w, "event: %s\ndata: %s\n\n", lw.name, bytes.TrimSuffix(line, []byte("\r")))
+	return err
+}
@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"warren/internal/container"
+)
+
+// fakeLogSource writes canned output and records what was asked for.
+type fakeLogSource struct {
+	container.Lifecycle // unused methods panic
+
+	name string
+	opts container.LogOptions
+	err  error
+}
+
+func (f *fakeLogSource) Logs(_ context.Context, name string, opts container.LogOptions, stdout, stderr io.Writer) error {
+	f.name, f.opts = name, opts
+	if f.err != nil {
+		return f.err
+	}
+	io.WriteString(stdout, "hello\nwor")
+	io.WriteString(stderr, "oops\n")
+	io.WriteString(stdout, "ld\n")
+	return nil
+}
+
+func logsServer(t *testing.T, source *fakeLogSource) *Server {
+	t.Helper()
+	srv, _ := testServer(t)
+	srv.AddAgent("research", AgentInfo{Name: "research"}, nil, nil)
+	srv.SetRuntimeResolver(func(agent string) (container.Lifecycle, string, bool) {
+		return source, "openclaw-research", agent == "research"
+	})
+	return srv
+}
+
+func TestAgentLogsText(t *testing.T) {
+	source := &fakeLogSource{}
+	srv := logsServer(t, source)
+
+	req := httptest.NewRequest("GET", "/admin/agents/research/logs?follow=true&tail=50&since=1700000000", nil)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content type = %q", ct)
+	}
+	if got := w.Body.String(); got != "hello\nworoops\nld\n" {
+		t.Errorf("body = %q", got)
+	}
+	want := container.LogOptions{Follow: true, Tail: 50, Since: time.Unix(1700000000, 0)}
+	if source.name != "openclaw-research" || source.opts != want {
+		t.Errorf("asked for %q with %+v", source.name, source.opts)
+	}
+}
+
+func TestAgentLogsSSE(t *testing.T) {
+	srv := logsServer(t, &fakeLogSource{})
+
+	req := httptest.NewRequest("GET", "/admin/agents/research/logs", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+
+	want := "event: stdout\ndata: hello\n\n" +
+		"event: stderr\ndata: oops\n\n" +
+		"event: stdout\ndata: world\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestAgentLogsErrors(t *testing.T) {
+	source := &fakeLogSource{err: errors.New("no such service")}
+	srv := logsServer(t, source)
+	srv.AddAgent("other", AgentInfo{Name: "other"}, nil, nil)
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/admin/agents/research/logs?tail=-1", 400},
+		{"/admin/agents/research/logs?since=yesterday", 400},
+		{"/admin/agents/missing/logs", 404},
+		{"/admin/agents/other/logs", 404}, // no resolver entry and no container
+		{"/admin/agents/research/logs", 502},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.code {
+			t.Errorf("GET %s = %d, want %d: %s", tt.path, w.Code, tt.code, w.Body.String())
+		}
+	}
+}
+
+func TestParseLogOptionsSinceDuration(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := httptest.NewRequest("GET", "/?since=10m&tail=all", nil)
+	opts, err := parseLogOptions(req, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Since.Equal(now.Add(-10*time.Minute)) || opts.Tail != -1 || opts.Follow {
+		t.Errorf("opts = %+v", opts)
+	}
+}
@@ -49,6 +49,8 @@ func testServerWithTracker(t *testing.T, tracker *process.Tracker) *Server {
 		func() int64 { return 0 },
 		nil,
 		tracker,
+		nil,
+		nil,
 		logger,
 	)
 }
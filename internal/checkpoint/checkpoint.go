@@ -0,0 +1,116 @@
+// Package checkpoint persists orchestrator runtime state to a JSON snapshot
+// file so it survives restarts.
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"warren/internal/policy"
+	"warren/internal/services"
+)
+
+// Snapshot is the runtime state saved between orchestrator runs.
+type Snapshot struct {
+	SavedAt  time.Time                      `json:"saved_at"`
+	Agents   map[string]policy.RuntimeState `json:"agents,omitempty"`   // by agent name
+	Activity map[string]time.Time           `json:"activity,omitempty"` // by hostname
+	Services []services.Service             `json:"services,omitempty"` // dynamic routes
+}
+
+// Store reads and writes a snapshot file. Writes go to a temporary file in
+// the same directory that is renamed over the snapshot, so a crash mid-write
+// leaves the previous snapshot intact.
+type Store struct {
+	path   string
+	mu     sync.Mutex
+	logger *slog.Logger
+}
+
+// NewStore creates a store for the snapshot at path.
+func NewStore(path string, logger *slog.Logger) *Store {
+	return &Store{path: path, logger: logger.With("component", "checkpoint")}
+}
+
+// Load reads the snapshot. A missing file is not an error and returns an
+// empty snapshot.
+func (s *Store) Load() (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, nil
+	}
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("read snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("parse snapshot %s: %w", s.path, err)
+	}
+	return snap, nil
+}
+
+// Save writes snap, stamping SavedAt.
+func (s *Store) Save(snap Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap.SavedAt = time.Now()
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create snapshot dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("rename snapshot: %w", err)
+	}
+	return nil
+}
+
+// Run saves collect() every interval until ctx is cancelled. Failed saves are
+// logged and retried at the next interval. The final save on shutdown is left
+// to the caller, once everything that feeds the snapshot has stopped.
+func (s *Store) Run(ctx context.Context, interval time.Duration, collect func() Snapshot) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Save(collect()); err != nil {
+				s.logger.Error("checkpoint failed", "path", s.path, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package checkpoint
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"warren/internal/policy"
+	"warren/internal/services"
+)
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state", "warren.json")
+	return NewStore(path, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	snap, err := testStore(t).Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !snap.SavedAt.IsZero() || len(snap.Agents) != 0 {
+		t.Errorf("snapshot = %+v, want empty", snap)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	s := testStore(t)
+	slept := time.Now().Add(-time.Hour).Truncate(time.Second)
+	want := Snapshot{
+		Agents: map[string]policy.RuntimeState{
+			"a": {LastSleep: slept},
+			"b": {RestartAttempts: 2, Restarts: 5, LastRestart: slept},
+		},
+		Activity: map[string]time.Time{"a.example.com": slept},
+		Services: []services.Service{{Hostname: "x.example.com", Target: "http://10.0.0.1:80", Agent: "a", CreatedAt: slept}},
+	}
+	if err := s.Save(want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if time.Since(got.SavedAt) > time.Minute {
+		t.Errorf("saved_at = %v, want now", got.SavedAt)
+	}
+	if !got.Agents["a"].LastSleep.Equal(slept) || got.Agents["b"].RestartAttempts != 2 || got.Agents["b"].Restarts != 5 {
+		t.Errorf("agents = %+v", got.Agents)
+	}
+	if !got.Activity["a.example.com"].Equal(slept) {
+		t.Errorf("activity = %+v", got.Activity)
+	}
+	if len(got.Services) != 1 || got.Services[0].Hostname != "x.example.com" || !got.Services[0].CreatedAt.Equal(slept) {
+		t.Errorf("services = %+v", got.Services)
+	}
+
+	// Only the snapshot itself is left behind, no temp files.
+	entries, _ := os.ReadDir(filepath.Dir(s.path))
+	if len(entries) != 1 {
+		t.Errorf("snapshot dir has %d entries, want 1", len(entries))
+	}
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	s := testStore(t)
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); err == nil {
+		t.Error("expected error for corrupt snapshot")
+	}
+}
+
+func TestRunCheckpointsPeriodically(t *testing.T) {
+	s := testStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, 10*time.Millisecond, func() Snapshot {
+			return Snapshot{Activity: map[string]time.Time{"a.example.com": time.Now()}}
+		})
+		close(done)
+	}()
+	// Stop writing before the temp dir is removed.
+	defer func() { cancel(); <-done }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if snap, err := s.Load(); err == nil && len(snap.Activity) == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no checkpoint written")
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -9,6 +10,7 @@ import (
 
 type Config struct {
 	Listen         string            `yaml:"listen"`
+	Runtime        string            `yaml:"runtime"`      // "swarm" (default) or "docker"
 	AdminListen    string            `yaml:"admin_listen"` // e.g. ":9090", empty = disabled
 	AdminToken     string            `yaml:"admin_token"`  // bearer token for admin API auth
 	ProxyToken     string            `yaml:"proxy_token"`  // bearer token for proxy port auth
@@ -22,6 +24,30 @@ type Config struct {
 	SSH            SSHConfig         `yaml:"ssh"`
 Usage          UsageConfig       `yaml:"usage"`
 	PicoClaw       PicoClawConfig    `yaml:"picoclaw"`
+	Resources      ResourcesConfig   `yaml:"resources"`
+	State          StateConfig       `yaml:"state"`
+	Reconcile      ReconcileConfig   `yaml:"reconcile"`
+}
+
+// ReconcileConfig controls the periodic check of each agent's policy state
+// against its runtime.
+type ReconcileConfig struct {
+	Interval time.Duration `yaml:"interval"` // default: 1m
+}
+
+// StateConfig enables checkpointing runtime state (activity, last sleep
+// times, restart counters and dynamic services) so it survives restarts.
+type StateConfig struct {
+	Path               string        `yaml:"path"`                // snapshot file; empty = disabled
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"` // default: 30s
+}
+
+// ResourcesConfig enables sampling per-service CPU and memory through the
+// Docker stats API, and memory-based eviction.
+type ResourcesConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	SampleInterval  time.Duration `yaml:"sample_interval"`  // default: 15s
+	MemoryThreshold float64       `yaml:"memory_threshold"` // fraction of host memory in use, host-wide; 0 = sample only
 }
 
 type UsageConfig struct {
@@ -77,17 +103,114 @@ type Agent struct {
 	Hermes    AgentHermes `yaml:"hermes"`
 	Hostname  string   `yaml:"hostname"`
 	Hostnames []string `yaml:"hostnames"` // additional hostnames
+	PathPrefix  string `yaml:"path_prefix"`  // only route this path under the hostname(s)
+	StripPrefix bool   `yaml:"strip_prefix"` // remove path_prefix before forwarding
 	Backend   string   `yaml:"backend"`
+	Backends  []string `yaml:"backends"` // additional backend replicas
+	Replicas  int      `yaml:"replicas"` // swarm replicas to run while awake (default 1)
 	Policy    string    `yaml:"policy"`
 	Container Container `yaml:"container"`
+	Process   *ProcessConfig `yaml:"process"` // run as a native process instead of a container
+	Systemd   *SystemdConfig `yaml:"systemd"` // run as a systemd unit instead of a container
 	Health    Health    `yaml:"health"`
 	Idle      IdleConfig `yaml:"idle"`
+	ColdStart ColdStartConfig `yaml:"cold_start"`
+	LoadBalancing LoadBalancingConfig `yaml:"load_balancing"`
+	Autoscale AutoscaleConfig `yaml:"autoscale"`
+	DependsOn []string `yaml:"depends_on"` // agents woken first and kept awake while this one is up
+	Eviction  EvictionConfig `yaml:"eviction"`
+}
+
+// ProcessConfig runs an agent as a native process supervised by Warren
+// instead of a container.
+type ProcessConfig struct {
+	Command     []string          `yaml:"command"`
+	Dir         string            `yaml:"dir"`
+	Env         map[string]string `yaml:"env"`           // added to the orchestrator's environment
+	User        string            `yaml:"user"`          // run as this user; requires running Warren as root
+	LogDir      string            `yaml:"log_dir"`       // default: /var/log/warren
+	LogMaxSize  int64             `yaml:"log_max_size"`  // bytes before rotating, default: 10 MiB
+	LogMaxFiles int               `yaml:"log_max_files"` // rotated logs kept, default: 5
+}
+
+// SystemdConfig runs an agent as an installed systemd unit, started and
+// stopped through the systemd D-Bus API instead of scaling a container.
+type SystemdConfig struct {
+	Unit string `yaml:"unit"` // e.g. "openclaw-research.service"
+}
+
+// EvictionConfig controls how an on-demand agent is picked when
+// max_ready_agents forces one to sleep.
+type EvictionConfig struct {
+	Priority int           `yaml:"priority"`  // lower priorities are evicted first (default 0)
+	Pinned   bool          `yaml:"pinned"`    // never evict
+	MinAwake time.Duration `yaml:"min_awake"` // grace period after a wake (default 1m)
+}
+
+// AutoscaleConfig bounds and drives the autoscale policy. Replicas are sized so
+// each one handles about TargetRPS requests/sec and TargetConnections
+// WebSockets; whichever needs more replicas wins.
+type AutoscaleConfig struct {
+	Min               int           `yaml:"min"`
+	Max               int           `yaml:"max"`
+	TargetRPS         float64       `yaml:"target_rps"`
+	TargetConnections int           `yaml:"target_connections"`
+	Tolerance         float64       `yaml:"tolerance"`           // default 0.1 (±10% of target)
+	Interval          time.Duration `yaml:"interval"`            // default 15s
+	ScaleUpCooldown   time.Duration `yaml:"scale_up_cooldown"`   // default 30s
+	ScaleDownCooldown time.Duration `yaml:"scale_down_cooldown"` // default 5m
+}
+
+// LoadBalancingConfig controls how requests are spread across an agent's
+// backend replicas.
+type LoadBalancingConfig struct {
+	Strategy   string `yaml:"strategy"`    // "round-robin" (default), "least-connections" or "consistent-hash"
+	HashHeader string `yaml:"hash_header"` // consistent-hash key: request header...
+	HashCookie string `yaml:"hash_cookie"` // ...or cookie (header wins if both are set)
+
+	// ResolveDNS expands backend hostnames (e.g. tasks.<service>) into one
+	// target per resolved address, refreshed every ResolveInterval.
+	ResolveDNS      bool          `yaml:"resolve_dns"`
+	ResolveInterval time.Duration `yaml:"resolve_interval"` // default: 30s
+
+	EjectAfter    int           `yaml:"eject_after"`    // consecutive proxy errors before a target is ejected (default 3)
+	ProbeInterval time.Duration `yaml:"probe_interval"` // how often ejected targets are probed (default 10s)
+}
+
+// ColdStartConfig controls how the proxy answers requests while an agent is
+// sleeping or starting.
+type ColdStartConfig struct {
+	Mode        string        `yaml:"mode"`         // "reject" (503, default) or "hold"
+	MaxQueue    int           `yaml:"max_queue"`    // max requests held at once (hold mode, default 100)
+	HoldTimeout time.Duration `yaml:"hold_timeout"` // default: health.startup_timeout
+
+	// Interstitial serves an HTML "waking up" page to browsers (Accept: text/html)
+	// instead of the JSON 503. InterstitialTemplate optionally points at an
+	// html/template file that replaces the built-in page.
+	Interstitial         bool   `yaml:"interstitial"`
+	InterstitialTemplate string `yaml:"interstitial_template"`
 }
 
 type IdleConfig struct {
-	Timeout      time.Duration `yaml:"timeout"`
-	DrainTimeout time.Duration `yaml:"drain_timeout"`
-	WakeCooldown time.Duration `yaml:"wake_cooldown"`
+	Timeout      time.Duration  `yaml:"timeout"`
+	DrainTimeout time.Duration  `yaml:"drain_timeout"`
+	WakeCooldown time.Duration  `yaml:"wake_cooldown"`
+	Schedule     ScheduleConfig `yaml:"schedule"`
+}
+
+// ScheduleConfig sets time-based wake/sleep rules for an on-demand agent.
+// Times are five-field cron expressions evaluated in Timezone.
+type ScheduleConfig struct {
+	Timezone   string           `yaml:"timezone"`    // IANA name, default UTC
+	Wake       []string         `yaml:"wake"`        // pre-warm at these times
+	KeepAwake  []ScheduleWindow `yaml:"keep_awake"`  // never idle-sleep inside these windows
+	QuietHours []ScheduleWindow `yaml:"quiet_hours"` // refuse wakes and force sleep inside these windows
+}
+
+// ScheduleWindow opens at each activation of Start and lasts for Duration.
+type ScheduleWindow struct {
+	Start    string        `yaml:"start"`
+	Duration time.Duration `yaml:"duration"`
 }
 
 type Container struct {
@@ -96,11 +219,64 @@ type Container struct {
 }
 
 type Health struct {
-	URL                string        `yaml:"url"`
-	CheckInterval      time.Duration `yaml:"check_interval"`
-	StartupTimeout     time.Duration `yaml:"startup_timeout"`
-	MaxFailures        int           `yaml:"max_failures"`
-	MaxRestartAttempts int           `yaml:"max_restart_attempts"`
+	Probe              `yaml:",inline"` // default check for startup and liveness
+	CheckInterval      time.Duration    `yaml:"check_interval"`
+	StartupTimeout     time.Duration    `yaml:"startup_timeout"`
+	StartupInterval    time.Duration    `yaml:"startup_interval"` // how often the startup probe polls (default 2s)
+	MaxFailures        int              `yaml:"max_failures"`
+	MaxRestartAttempts int              `yaml:"max_restart_attempts"`
+	RecoveryBackoff    time.Duration    `yaml:"recovery_backoff"`     // first wait before retrying a degraded agent (default 10s)
+	RecoveryMaxBackoff time.Duration    `yaml:"recovery_max_backoff"` // cap for the doubling wait (default 5m)
+
+	Startup   *Probe `yaml:"startup,omitempty"`   // gates ready after a start; defaults to the main probe
+	Readiness *Probe `yaml:"readiness,omitempty"` // failing takes the agent out of routing; unset means always ready
+	Liveness  *Probe `yaml:"liveness,omitempty"`  // failing counts toward restarts; defaults to the main probe
+}
+
+// Probe describes one health check. Type is http (default), tcp, websocket or
+// exec.
+type Probe struct {
+	URL          string            `yaml:"url"`
+	Type         string            `yaml:"type,omitempty"`
+	Timeout      time.Duration     `yaml:"timeout,omitempty"`       // per-check timeout; default depends on type
+	Headers      map[string]string `yaml:"headers,omitempty"`       // http, websocket
+	ExpectStatus []int             `yaml:"expect_status,omitempty"` // http; default any 2xx or 3xx
+	ExpectBody   string            `yaml:"expect_body,omitempty"`   // http; substring the body must contain
+	ExpectJSON   map[string]string `yaml:"expect_json,omitempty"`   // http; dotted path → expected value
+	Address      string            `yaml:"address,omitempty"`       // tcp; host:port, default taken from url
+	Command      []string          `yaml:"command,omitempty"`       // exec; run inside the agent's container
+}
+
+// NeedsURL reports whether the probe type reads url.
+func (p Probe) NeedsURL() bool {
+	switch p.Type {
+	case "tcp":
+		return p.Address == ""
+	case "exec":
+		return false
+	}
+	return true
+}
+
+// StartupProbe returns the probe that decides when a started agent is up.
+func (h Health) StartupProbe() Probe {
+	if h.Startup != nil {
+		return *h.Startup
+	}
+	return h.Probe
+}
+
+// LivenessProbe returns the probe whose failures count toward restarts.
+func (h Health) LivenessProbe() Probe {
+	if h.Liveness != nil {
+		return *h.Liveness
+	}
+	return h.Probe
+}
+
+// usesMainProbe reports whether any stage falls back to the main probe.
+func (h Health) usesMainProbe() bool {
+	return h.Startup == nil || h.Liveness == nil
 }
 
 // Save writes the config back to the given file path.
@@ -145,6 +321,19 @@ func applyDefaults(cfg *Config) {
 		cfg.DatabaseURL = envDB
 	}
 
+	if cfg.Resources.SampleInterval == 0 {
+		cfg.Resources.SampleInterval = 15 * time.Second
+	}
+	if cfg.Runtime == "" {
+		cfg.Runtime = "swarm"
+	}
+	if cfg.State.CheckpointInterval == 0 {
+		cfg.State.CheckpointInterval = 30 * time.Second
+	}
+	if cfg.Reconcile.Interval == 0 {
+		cfg.Reconcile.Interval = time.Minute
+	}
+
 	// Usage tracking defaults.
 	if cfg.Usage.JSONLPath == "" {
 		home, _ := os.UserHomeDir()
@@ -205,6 +394,13 @@ func applyDefaults(cfg *Config) {
 	}
 
 	for _, agent := range cfg.Agents {
+		// Hostnames are case-insensitive; the proxy routes and tracks
+		// activity under the lowercase form.
+		agent.Hostname = strings.ToLower(agent.Hostname)
+		for i, h := range agent.Hostnames {
+			agent.Hostnames[i] = strings.ToLower(h)
+		}
+
 		// Default Hermes enabled=true for all agents
 		if !agent.Hermes.Enabled {
 			agent.Hermes.Enabled = true
@@ -215,12 +411,21 @@ func applyDefaults(cfg *Config) {
 		if agent.Health.StartupTimeout == 0 {
 			agent.Health.StartupTimeout = 60 * time.Second
 		}
+		if agent.Health.StartupInterval == 0 {
+			agent.Health.StartupInterval = 2 * time.Second
+		}
 		if agent.Health.MaxFailures == 0 {
 			agent.Health.MaxFailures = 3
 		}
 		if agent.Health.MaxRestartAttempts == 0 {
 			agent.Health.MaxRestartAttempts = 10
 		}
+		if agent.Health.RecoveryBackoff == 0 {
+			agent.Health.RecoveryBackoff = 10 * time.Second
+		}
+		if agent.Health.RecoveryMaxBackoff == 0 {
+			agent.Health.RecoveryMaxBackoff = 5 * time.Minute
+		}
 		if agent.Policy == "on-demand" && agent.Idle.Timeout == 0 {
 			agent.Idle.Timeout = 30 * time.Minute
 		}
@@ -230,5 +435,63 @@ func applyDefaults(cfg *Config) {
 		if agent.Policy == "on-demand" && agent.Idle.WakeCooldown == 0 {
 			agent.Idle.WakeCooldown = 30 * time.Second
 		}
+		if agent.Policy == "on-demand" && agent.Eviction.MinAwake == 0 {
+			agent.Eviction.MinAwake = time.Minute
+		}
+		if agent.ColdStart.Mode == "" {
+			agent.ColdStart.Mode = "reject"
+		}
+		if agent.Replicas == 0 {
+			agent.Replicas = 1
+		}
+		if pc := agent.Process; pc != nil {
+			if pc.LogDir == "" {
+				pc.LogDir = "/var/log/warren"
+			}
+			if pc.LogMaxSize == 0 {
+				pc.LogMaxSize = 10 << 20
+			}
+			if pc.LogMaxFiles == 0 {
+				pc.LogMaxFiles = 5
+			}
+		}
+		if agent.Policy == "autoscale" {
+			as := &agent.Autoscale
+			if as.Min == 0 {
+				as.Min = 1
+			}
+			if as.Tolerance == 0 {
+				as.Tolerance = 0.1
+			}
+			if as.Interval == 0 {
+				as.Interval = 15 * time.Second
+			}
+			if as.ScaleUpCooldown == 0 {
+				as.ScaleUpCooldown = 30 * time.Second
+			}
+			if as.ScaleDownCooldown == 0 {
+				as.ScaleDownCooldown = 5 * time.Minute
+			}
+		}
+		if agent.LoadBalancing.Strategy == "" {
+			agent.LoadBalancing.Strategy = "round-robin"
+		}
+		if agent.LoadBalancing.ResolveInterval == 0 {
+			agent.LoadBalancing.ResolveInterval = 30 * time.Second
+		}
+		if agent.LoadBalancing.EjectAfter == 0 {
+			agent.LoadBalancing.EjectAfter = 3
+		}
+		if agent.LoadBalancing.ProbeInterval == 0 {
+			agent.LoadBalancing.ProbeInterval = 10 * time.Second
+		}
+		if agent.ColdStart.Mode == "hold" {
+			if agent.ColdStart.MaxQueue == 0 {
+				agent.ColdStart.MaxQueue = 100
+			}
+			if agent.ColdStart.HoldTimeout == 0 {
+				agent.ColdStart.HoldTimeout = agent.Health.StartupTimeout
+			}
+		}
 	}
 }
@@ -59,6 +59,9 @@ agents:
 	if a.Health.StartupTimeout != 60*time.Second {
 		t.Errorf("agent startup_timeout = %v, want 60s", a.Health.StartupTimeout)
 	}
+	if a.Health.StartupInterval != 2*time.Second {
+		t.Errorf("agent startup_interval = %v, want 2s", a.Health.StartupInterval)
+	}
 	if a.Health.MaxFailures != 3 {
 		t.Errorf("agent max_failures = %d, want 3", a.Health.MaxFailures)
 	}
@@ -112,6 +115,329 @@ agents:
 	}
 }
 
+func TestColdStartHoldDefaults(t *testing.T) {
+	yaml := `
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://localhost:3000
+    policy: on-demand
+    container:
+      name: my-svc
+    health:
+      url: http://localhost:3000/health
+      startup_timeout: 90s
+    cold_start:
+      mode: hold
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cs := cfg.Agents["a"].ColdStart
+	if cs.MaxQueue != 100 {
+		t.Errorf("max_queue = %d, want 100", cs.MaxQueue)
+	}
+	if cs.HoldTimeout != 90*time.Second {
+		t.Errorf("hold_timeout = %v, want startup_timeout (90s)", cs.HoldTimeout)
+	}
+}
+
+func TestLoadBalancingDefaults(t *testing.T) {
+	yaml := `
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://tasks.app:3000
+    backends:
+      - http://10.0.0.9:3000
+    policy: unmanaged
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	a := cfg.Agents["a"]
+	if a.Replicas != 1 {
+		t.Errorf("replicas = %d, want 1", a.Replicas)
+	}
+	lb := a.LoadBalancing
+	if lb.Strategy != "round-robin" || lb.EjectAfter != 3 || lb.ProbeInterval != 10*time.Second || lb.ResolveInterval != 30*time.Second {
+		t.Errorf("load_balancing defaults = %+v", lb)
+	}
+	if len(a.Backends) != 1 {
+		t.Errorf("backends = %v", a.Backends)
+	}
+}
+
+func TestAutoscaleDefaults(t *testing.T) {
+	yaml := `
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://tasks.app:3000
+    policy: autoscale
+    container:
+      name: app
+    health:
+      url: http://tasks.app:3000/health
+    autoscale:
+      max: 4
+      target_rps: 20
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	as := cfg.Agents["a"].Autoscale
+	if as.Min != 1 || as.Max != 4 || as.TargetRPS != 20 {
+		t.Errorf("autoscale bounds = %+v", as)
+	}
+	if as.Tolerance != 0.1 || as.Interval != 15*time.Second || as.ScaleUpCooldown != 30*time.Second || as.ScaleDownCooldown != 5*time.Minute {
+		t.Errorf("autoscale defaults = %+v", as)
+	}
+}
+
+func TestIdleScheduleFromYAML(t *testing.T) {
+	yaml := `
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://tasks.app:3000
+    policy: on-demand
+    container:
+      name: app
+    health:
+      url: http://tasks.app:3000/health
+    idle:
+      timeout: 30m
+      schedule:
+        timezone: Europe/London
+        wake: ["45 8 * * MON-FRI"]
+        keep_awake:
+          - start: "0 9 * * MON-FRI"
+            duration: 8h
+        quiet_hours:
+          - start: "0 22 * * *"
+            duration: 9h
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sched := cfg.Agents["a"].Idle.Schedule
+	if sched.Timezone != "Europe/London" || len(sched.Wake) != 1 || sched.Wake[0] != "45 8 * * MON-FRI" {
+		t.Errorf("schedule = %+v", sched)
+	}
+	if len(sched.KeepAwake) != 1 || sched.KeepAwake[0].Duration != 8*time.Hour {
+		t.Errorf("keep_awake = %+v", sched.KeepAwake)
+	}
+	if len(sched.QuietHours) != 1 || sched.QuietHours[0].Start != "0 22 * * *" || sched.QuietHours[0].Duration != 9*time.Hour {
+		t.Errorf("quiet_hours = %+v", sched.QuietHours)
+	}
+}
+
+func TestEvictionFromYAML(t *testing.T) {
+	yaml := `
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://tasks.a:3000
+    policy: on-demand
+    container:
+      name: a
+    health:
+      url: http://tasks.a:3000/health
+    eviction:
+      priority: 10
+      pinned: true
+  b:
+    hostname: b.example.com
+    backend: http://tasks.b:3000
+    policy: on-demand
+    container:
+      name: b
+    health:
+      url: http://tasks.b:3000/health
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ev := cfg.Agents["a"].Eviction; ev.Priority != 10 || !ev.Pinned || ev.MinAwake != time.Minute {
+		t.Errorf("a eviction = %+v", ev)
+	}
+	if ev := cfg.Agents["b"].Eviction; ev.Priority != 0 || ev.Pinned || ev.MinAwake != time.Minute {
+		t.Errorf("b eviction = %+v, want defaults", ev)
+	}
+}
+
+func TestStateFromYAML(t *testing.T) {
+	yaml := `
+state:
+  path: /var/lib/warren/state.json
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://tasks.a:3000
+    policy: unmanaged
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.State.Path != "/var/lib/warren/state.json" {
+		t.Errorf("state.path = %q", cfg.State.Path)
+	}
+	if cfg.State.CheckpointInterval != 30*time.Second {
+		t.Errorf("state.checkpoint_interval = %v, want 30s default", cfg.State.CheckpointInterval)
+	}
+	if cfg.Reconcile.Interval != time.Minute {
+		t.Errorf("reconcile.interval = %v, want 1m default", cfg.Reconcile.Interval)
+	}
+}
+
+func TestProcessFromYAML(t *testing.T) {
+	yaml := `
+agents:
+  root:
+    hostname: root.example.com
+    backend: http://127.0.0.1:18789
+    policy: on-demand
+    process:
+      command: ["openclaw", "gateway"]
+      dir: /home/agent
+      env:
+        OPENCLAW_PROFILE: root
+      user: agent
+    health:
+      url: http://127.0.0.1:18789/health
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pc := cfg.Agents["root"].Process
+	if pc == nil || len(pc.Command) != 2 || pc.Dir != "/home/agent" || pc.Env["OPENCLAW_PROFILE"] != "root" || pc.User != "agent" {
+		t.Fatalf("process = %+v", pc)
+	}
+	if pc.LogDir != "/var/log/warren" || pc.LogMaxSize != 10<<20 || pc.LogMaxFiles != 5 {
+		t.Errorf("process log defaults = %q, %d, %d", pc.LogDir, pc.LogMaxSize, pc.LogMaxFiles)
+	}
+}
+
+func TestSystemdFromYAML(t *testing.T) {
+	yaml := `
+agents:
+  research:
+    hostname: research.example.com
+    backend: http://127.0.0.1:18790
+    policy: on-demand
+    systemd:
+      unit: openclaw-research.service
+    health:
+      url: http://127.0.0.1:18790/health
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sd := cfg.Agents["research"].Systemd; sd == nil || sd.Unit != "openclaw-research.service" {
+		t.Fatalf("systemd = %+v", sd)
+	}
+}
+
+func TestHealthProbeFromYAML(t *testing.T) {
+	yaml := `
+agents:
+  db:
+    hostname: db.example.com
+    backend: http://tasks.db:5432
+    policy: always-on
+    container:
+      name: db
+    health:
+      type: exec
+      command: ["pg_isready", "-q"]
+      timeout: 3s
+  api:
+    hostname: api.example.com
+    backend: http://tasks.api:8080
+    policy: always-on
+    container:
+      name: api
+    health:
+      url: http://tasks.api:8080/status
+      headers:
+        Authorization: Bearer probe
+      expect_status: [200, 204]
+      expect_json:
+        checks.db: up
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db := cfg.Agents["db"].Health
+	if db.Type != "exec" || len(db.Command) != 2 || db.Timeout != 3*time.Second {
+		t.Errorf("db health = %+v", db)
+	}
+	api := cfg.Agents["api"].Health
+	if api.Headers["Authorization"] != "Bearer probe" || len(api.ExpectStatus) != 2 || api.ExpectJSON["checks.db"] != "up" {
+		t.Errorf("api health = %+v", api)
+	}
+}
+
+func TestHealthStagesFromYAML(t *testing.T) {
+	yaml := `
+agents:
+  a:
+    hostname: a.example.com
+    backend: http://tasks.app:3000
+    policy: on-demand
+    container:
+      name: app
+    health:
+      startup_interval: 500ms
+      startup:
+        type: tcp
+        address: tasks.app:3000
+      readiness:
+        url: http://tasks.app:3000/ready
+        expect_json:
+          ready: "true"
+      liveness:
+        url: http://tasks.app:3000/live
+`
+	path := writeTemp(t, yaml)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := cfg.Agents["a"].Health
+	if h.StartupInterval != 500*time.Millisecond {
+		t.Errorf("startup_interval = %v", h.StartupInterval)
+	}
+	if p := h.StartupProbe(); p.Type != "tcp" || p.Address != "tasks.app:3000" {
+		t.Errorf("startup probe = %+v", p)
+	}
+	if h.Readiness == nil || h.Readiness.ExpectJSON["ready"] != "true" {
+		t.Errorf("readiness probe = %+v", h.Readiness)
+	}
+	if p := h.LivenessProbe(); p.URL != "http://tasks.app:3000/live" {
+		t.Errorf("liveness probe = %+v", p)
+	}
+}
+
 func writeTemp(t *testing.T, content string) string {
 	t.Helper()
 	dir := t.TempDir()
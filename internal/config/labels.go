@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LabelHostname marks a Swarm service or container as an agent; the other
+// warren.* labels are optional.
+const LabelHostname = "warren.hostname"
+
+// labelFields sets the agent field behind each supported label.
+var labelFields = map[string]func(a *Agent, v string) error{
+	LabelHostname: func(a *Agent, v string) error {
+		a.Hostname = v
+		return nil
+	},
+	"warren.hostnames": func(a *Agent, v string) error {
+		a.Hostnames = splitList(v)
+		return nil
+	},
+	"warren.path_prefix": func(a *Agent, v string) error {
+		a.PathPrefix = v
+		return nil
+	},
+	"warren.backend": func(a *Agent, v string) error {
+		a.Backend = v
+		return nil
+	},
+	"warren.port": func(a *Agent, v string) error {
+		_, err := strconv.ParseUint(v, 10, 16)
+		return err // used to build the backend once all labels are read
+	},
+	"warren.policy": func(a *Agent, v string) error {
+		a.Policy = v
+		return nil
+	},
+	"warren.health.url": func(a *Agent, v string) error {
+		a.Health.URL = v
+		return nil
+	},
+	"warren.health.check_interval": func(a *Agent, v string) (err error) {
+		a.Health.CheckInterval, err = time.ParseDuration(v)
+		return err
+	},
+	"warren.idle.timeout": func(a *Agent, v string) (err error) {
+		a.Idle.Timeout, err = time.ParseDuration(v)
+		return err
+	},
+	"warren.idle.drain_timeout": func(a *Agent, v string) (err error) {
+		a.Idle.DrainTimeout, err = time.ParseDuration(v)
+		return err
+	},
+	"warren.cold_start.mode": func(a *Agent, v string) error {
+		a.ColdStart.Mode = v
+		return nil
+	},
+	"warren.depends_on": func(a *Agent, v string) error {
+		a.DependsOn = splitList(v)
+		return nil
+	},
+	"warren.eviction.priority": func(a *Agent, v string) (err error) {
+		a.Eviction.Priority, err = strconv.Atoi(v)
+		return err
+	},
+	"warren.eviction.pinned": func(a *Agent, v string) (err error) {
+		a.Eviction.Pinned, err = strconv.ParseBool(v)
+		return err
+	},
+}
+
+// AgentFromLabels builds an agent from the warren.* labels on the Swarm
+// service or container called managedName, which becomes its container.name.
+// Without warren.backend, the backend is http://<managedName>:<warren.port>.
+// Defaults are not applied; see MergeLabeled.
+func AgentFromLabels(managedName string, labels map[string]string) (*Agent, error) {
+	agent := &Agent{Container: Container{Name: managedName}}
+	for key, value := range labels {
+		if !strings.HasPrefix(key, "warren.") {
+			continue
+		}
+		set, ok := labelFields[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown label %q", key)
+		}
+		if err := set(agent, value); err != nil {
+			return nil, fmt.Errorf("label %s: %w", key, err)
+		}
+	}
+	if agent.Hostname == "" {
+		return nil, fmt.Errorf("missing label %s", LabelHostname)
+	}
+	if port := labels["warren.port"]; agent.Backend == "" && port != "" {
+		agent.Backend = "http://" + managedName + ":" + port
+	}
+	return agent, nil
+}
+
+// MergeLabeled returns a copy of cfg with the label-defined agents added.
+// Agents from the YAML file win: a labelled agent with the same name is
+// ignored, and one that doesn't validate alongside the rest (for example
+// because it claims a route another agent already has) is left out and
+// reported in the returned errors.
+func MergeLabeled(cfg *Config, labeled map[string]*Agent) (*Config, []error) {
+	merged := *cfg
+	merged.Agents = maps.Clone(cfg.Agents)
+	if merged.Agents == nil {
+		merged.Agents = make(map[string]*Agent)
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(labeled)) {
+		if _, ok := merged.Agents[name]; ok {
+			continue
+		}
+		agent := *labeled[name]
+		applyDefaults(&Config{Defaults: cfg.Defaults, Agents: map[string]*Agent{name: &agent}})
+		merged.Agents[name] = &agent
+		if err := validate(&merged); err != nil {
+			delete(merged.Agents, name)
+			errs = append(errs, fmt.Errorf("labelled agent %q skipped: %w", name, err))
+		}
+	}
+	return &merged, errs
+}
+
+// splitList splits a comma-separated label value, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentFromLabels(t *testing.T) {
+	agent, err := AgentFromLabels("openclaw-research", map[string]string{
+		"warren.hostname":          "research.example.com",
+		"warren.hostnames":         "r.example.com, , research.example.org",
+		"warren.port":              "18790",
+		"warren.policy":            "on-demand",
+		"warren.health.url":        "http://openclaw-research:18790/health",
+		"warren.idle.timeout":      "10m",
+		"warren.eviction.pinned":   "true",
+		"com.docker.stack.service": "ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.Hostname != "research.example.com" || len(agent.Hostnames) != 2 || agent.Hostnames[1] != "research.example.org" {
+		t.Errorf("hostnames = %q, %q", agent.Hostname, agent.Hostnames)
+	}
+	if agent.Backend != "http://openclaw-research:18790" {
+		t.Errorf("backend = %q, want one built from the port", agent.Backend)
+	}
+	if agent.Container.Name != "openclaw-research" || agent.Policy != "on-demand" {
+		t.Errorf("container = %q, policy = %q", agent.Container.Name, agent.Policy)
+	}
+	if agent.Idle.Timeout != 10*time.Minute || !agent.Eviction.Pinned {
+		t.Errorf("idle.timeout = %v, pinned = %v", agent.Idle.Timeout, agent.Eviction.Pinned)
+	}
+}
+
+func TestAgentFromLabelsErrors(t *testing.T) {
+	tests := []struct {
+		labels  map[string]string
+		wantErr string
+	}{
+		{map[string]string{"warren.policy": "always-on"}, "missing label warren.hostname"},
+		{map[string]string{"warren.hostname": "a.com", "warren.idle.timeout": "soon"}, "label warren.idle.timeout"},
+		{map[string]string{"warren.hostname": "a.com", "warren.port": "http"}, "label warren.port"},
+		{map[string]string{"warren.hostname": "a.com", "warren.hostnmae": "b.com"}, `unknown label "warren.hostnmae"`},
+	}
+	for _, tt := range tests {
+		_, err := AgentFromLabels("a", tt.labels)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("labels %v: error = %v, want %q", tt.labels, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMergeLabeled(t *testing.T) {
+	cfg := &Config{
+		Defaults: Defaults{HealthCheckInterval: 30 * time.Second},
+		Agents: map[string]*Agent{
+			"yaml": {Hostname: "yaml.example.com", Backend: "http://yaml:80", Policy: "unmanaged"},
+		},
+	}
+	labeled := map[string]*Agent{
+		// Same name as a YAML agent: YAML wins.
+		"yaml": {Hostname: "other.example.com", Backend: "http://other:80", Policy: "unmanaged"},
+		// Claims the YAML agent's route: skipped.
+		"clash": {Hostname: "yaml.example.com", Backend: "http://clash:80", Policy: "unmanaged"},
+		"new": {Hostname: "new.example.com", Backend: "http://new:80", Policy: "on-demand",
+			Container: Container{Name: "new"}, Health: Health{Probe: Probe{URL: "http://new:80/health"}}},
+	}
+
+	merged, errs := MergeLabeled(cfg, labeled)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"clash"`) {
+		t.Errorf("errs = %v, want one for clash", errs)
+	}
+	if len(merged.Agents) != 2 {
+		t.Fatalf("agents = %d, want 2", len(merged.Agents))
+	}
+	if merged.Agents["yaml"].Hostname != "yaml.example.com" {
+		t.Errorf("YAML agent was overridden by labels")
+	}
+	if a := merged.Agents["new"]; a == nil || a.Idle.Timeout != 30*time.Minute || a.Health.CheckInterval != 30*time.Second {
+		t.Errorf("labelled agent missing defaults: %+v", a)
+	}
+	if len(cfg.Agents) != 1 {
+		t.Errorf("MergeLabeled modified the YAML config")
+	}
+}
@@ -2,9 +2,17 @@ package config
 
 import (
 	"fmt"
+	"html/template"
 	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
 
 	"warren/internal/security"
+	"warren/internal/services"
 )
 
 func validate(cfg *Config) error {
@@ -12,7 +20,15 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("config: no agents defined")
 	}
 
-	hostnames := make(map[string]string) // hostname → agent name
+	switch cfg.Runtime {
+	case "", "swarm", "docker":
+		// valid
+	default:
+		return fmt.Errorf("config: unknown runtime %q (want swarm or docker)", cfg.Runtime)
+	}
+
+	hostnames := make(map[string]string) // route key (hostname + path prefix) → agent name
+	wildcards := make(map[string]string) // lowercased wildcard route key → agent name
 	for name, agent := range cfg.Agents {
 		if agent.Hostname == "" {
 			return fmt.Errorf("config: agent %q missing hostname", name)
@@ -25,7 +41,7 @@ func validate(cfg *Config) error {
 		}
 
 		switch agent.Policy {
-		case "always-on", "unmanaged", "on-demand":
+		case "always-on", "unmanaged", "on-demand", "autoscale":
 			// valid
 		case "":
 			return fmt.Errorf("config: agent %q missing policy", name)
@@ -33,20 +49,31 @@ func validate(cfg *Config) error {
 			return fmt.Errorf("config: agent %q unknown policy %q", name, agent.Policy)
 		}
 
+		if agent.Process != nil {
+			if err := validateProcess(name, agent); err != nil {
+				return err
+			}
+		}
+		if agent.Systemd != nil {
+			if err := validateSystemd(name, agent); err != nil {
+				return err
+			}
+		}
+
 		if agent.Policy == "always-on" {
-			if agent.Container.Name == "" {
+			if agent.Container.Name == "" && agent.Process == nil && agent.Systemd == nil {
 				return fmt.Errorf("config: agent %q with always-on policy requires container.name", name)
 			}
-			if agent.Health.URL == "" {
+			if agent.Health.URL == "" && agent.Health.NeedsURL() && agent.Health.usesMainProbe() {
 				return fmt.Errorf("config: agent %q with always-on policy requires health.url", name)
 			}
 		}
 
 		if agent.Policy == "on-demand" {
-			if agent.Container.Name == "" {
+			if agent.Container.Name == "" && agent.Process == nil && agent.Systemd == nil {
 				return fmt.Errorf("config: agent %q with on-demand policy requires container.name", name)
 			}
-			if agent.Health.URL == "" {
+			if agent.Health.URL == "" && agent.Health.NeedsURL() && agent.Health.usesMainProbe() {
 				return fmt.Errorf("config: agent %q with on-demand policy requires health.url", name)
 			}
 			if agent.Idle.Timeout <= 0 {
@@ -54,19 +81,132 @@ func validate(cfg *Config) error {
 			}
 		}
 
+		if err := validateHealth(agent.Health); err != nil {
+			return fmt.Errorf("config: agent %q %w", name, err)
+		}
+
+		if agent.Health.RecoveryBackoff < 0 || agent.Health.RecoveryMaxBackoff < agent.Health.RecoveryBackoff {
+			return fmt.Errorf("config: agent %q requires 0 <= health.recovery_backoff <= health.recovery_max_backoff", name)
+		}
+
+		if sched := agent.Idle.Schedule; len(sched.Wake)+len(sched.KeepAwake)+len(sched.QuietHours) > 0 {
+			if agent.Policy != "on-demand" {
+				return fmt.Errorf("config: agent %q idle.schedule requires on-demand policy", name)
+			}
+			if err := validateSchedule(sched); err != nil {
+				return fmt.Errorf("config: agent %q idle.schedule: %w", name, err)
+			}
+		}
+
+		if ev := agent.Eviction; ev != (EvictionConfig{}) {
+			if agent.Policy != "on-demand" {
+				return fmt.Errorf("config: agent %q eviction requires on-demand policy", name)
+			}
+			if ev.MinAwake < 0 {
+				return fmt.Errorf("config: agent %q eviction.min_awake must be >= 0", name)
+			}
+		}
+
+		if agent.Policy == "autoscale" {
+			as := agent.Autoscale
+			if agent.Container.Name == "" {
+				return fmt.Errorf("config: agent %q with autoscale policy requires container.name", name)
+			}
+			if agent.Health.URL == "" && agent.Health.NeedsURL() && agent.Health.usesMainProbe() {
+				return fmt.Errorf("config: agent %q with autoscale policy requires health.url", name)
+			}
+			if as.Min < 1 || as.Max < as.Min {
+				return fmt.Errorf("config: agent %q autoscale requires 1 <= min <= max", name)
+			}
+			if as.TargetRPS <= 0 && as.TargetConnections <= 0 {
+				return fmt.Errorf("config: agent %q autoscale requires target_rps or target_connections", name)
+			}
+			if as.Tolerance < 0 || as.Tolerance >= 1 {
+				return fmt.Errorf("config: agent %q autoscale.tolerance must be in [0, 1)", name)
+			}
+		}
+
+		switch agent.ColdStart.Mode {
+		case "", "reject", "hold":
+			// valid
+		default:
+			return fmt.Errorf("config: agent %q unknown cold_start.mode %q", name, agent.ColdStart.Mode)
+		}
+		if agent.ColdStart.MaxQueue < 0 {
+			return fmt.Errorf("config: agent %q cold_start.max_queue must be >= 0", name)
+		}
+		if path := agent.ColdStart.InterstitialTemplate; path != "" {
+			// Parsed here so a bad template fails a reload instead of startup.
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("config: agent %q cold_start.interstitial_template: %w", name, err)
+			}
+			if _, err := template.New("interstitial").Parse(string(data)); err != nil {
+				return fmt.Errorf("config: agent %q cold_start.interstitial_template: %w", name, err)
+			}
+		}
+
+		for _, b := range agent.Backends {
+			if u, err := url.Parse(b); err != nil || u.Host == "" {
+				return fmt.Errorf("config: agent %q invalid backends entry %q", name, b)
+			}
+		}
+		if agent.Replicas < 0 {
+			return fmt.Errorf("config: agent %q replicas must be >= 0", name)
+		}
+		// Plain containers can't be scaled, only started and stopped.
+		if cfg.Runtime == "docker" {
+			if agent.Policy == "autoscale" {
+				return fmt.Errorf("config: agent %q autoscale policy requires runtime swarm", name)
+			}
+			if agent.Replicas > 1 {
+				return fmt.Errorf("config: agent %q replicas requires runtime swarm", name)
+			}
+		}
+		switch agent.LoadBalancing.Strategy {
+		case "", "round-robin", "least-connections":
+			// valid
+		case "consistent-hash":
+			if agent.LoadBalancing.HashHeader == "" && agent.LoadBalancing.HashCookie == "" {
+				return fmt.Errorf("config: agent %q consistent-hash load balancing requires hash_header or hash_cookie", name)
+			}
+		default:
+			return fmt.Errorf("config: agent %q unknown load_balancing.strategy %q", name, agent.LoadBalancing.Strategy)
+		}
+		if agent.LoadBalancing.EjectAfter < 0 {
+			return fmt.Errorf("config: agent %q load_balancing.eject_after must be >= 0", name)
+		}
+
+		if err := security.ValidatePathPrefix(agent.PathPrefix); err != nil {
+			return fmt.Errorf("config: agent %q path_prefix: %w", name, err)
+		}
+		prefix := services.NormalizePathPrefix(agent.PathPrefix)
+
 		// Validate and check all hostnames (primary + additional) for duplicates.
+		// Agents may share a hostname as long as their path prefixes differ.
 		allHostnames := append([]string{agent.Hostname}, agent.Hostnames...)
 		for _, h := range allHostnames {
 			if h == "" {
 				continue
 			}
-			if err := security.ValidateHostname(h); err != nil {
+			if err := security.ValidateHostnamePattern(h); err != nil {
 				return fmt.Errorf("config: agent %q hostname %q: %w", name, h, err)
 			}
-			if prev, ok := hostnames[h]; ok {
-				return fmt.Errorf("config: duplicate hostname %q (agents %q and %q)", h, prev, name)
+			route := services.RouteKey(h, prefix)
+			if prev, ok := hostnames[route]; ok {
+				return fmt.Errorf("config: duplicate hostname %q (agents %q and %q)", route, prev, name)
+			}
+			hostnames[route] = name
+
+			// Wildcards match case-insensitively, so two agents claiming the
+			// same suffix in different case would shadow each other.
+			if services.IsWildcard(h) {
+				key := strings.ToLower(route)
+				if prev, ok := wildcards[key]; ok && prev != name {
+					return fmt.Errorf("config: overlapping wildcard hostname %q (agents %q and %q)", h, prev, name)
+				}
+				wildcards[key] = name
 			}
-			hostnames[h] = name
 		}
 
 		// Validate health check URLs (M3: scheme validation, private IPs allowed).
@@ -77,6 +217,23 @@ func validate(cfg *Config) error {
 		}
 	}
 
+	if t := cfg.Resources.MemoryThreshold; t < 0 || t >= 1 {
+		return fmt.Errorf("config: resources.memory_threshold must be in [0, 1)")
+	}
+	if cfg.Resources.SampleInterval < 0 {
+		return fmt.Errorf("config: resources.sample_interval must be >= 0")
+	}
+	if cfg.State.CheckpointInterval < 0 {
+		return fmt.Errorf("config: state.checkpoint_interval must be >= 0")
+	}
+	if cfg.Reconcile.Interval < 0 {
+		return fmt.Errorf("config: reconcile.interval must be >= 0")
+	}
+
+	if err := validateDependencies(cfg.Agents); err != nil {
+		return err
+	}
+
 	// Validate webhook URLs (M2: SSRF protection).
 	for i, wh := range cfg.Webhooks {
 		if err := security.ValidateWebhookURL(wh.URL); err != nil {
@@ -86,3 +243,191 @@ func validate(cfg *Config) error {
 
 	return nil
 }
+
+// validateProcess checks an agent that runs as a native process.
+func validateProcess(name string, agent *Agent) error {
+	pc := agent.Process
+	if err := validateNonContainer(name, agent, "process"); err != nil {
+		return err
+	}
+	if len(pc.Command) == 0 || pc.Command[0] == "" {
+		return fmt.Errorf("config: agent %q process.command is required", name)
+	}
+	if pc.LogMaxSize < 0 || pc.LogMaxFiles < 0 {
+		return fmt.Errorf("config: agent %q process log_max_size and log_max_files must be >= 0", name)
+	}
+	return nil
+}
+
+// validateSystemd checks an agent that runs as a systemd unit.
+func validateSystemd(name string, agent *Agent) error {
+	if err := validateNonContainer(name, agent, "systemd"); err != nil {
+		return err
+	}
+	if agent.Process != nil {
+		return fmt.Errorf("config: agent %q cannot set both process and systemd", name)
+	}
+	if agent.Systemd.Unit == "" {
+		return fmt.Errorf("config: agent %q systemd.unit is required", name)
+	}
+	return nil
+}
+
+// validateNonContainer checks the settings shared by agents that run outside
+// a container; kind names the block that replaces it.
+func validateNonContainer(name string, agent *Agent, kind string) error {
+	if agent.Policy != "always-on" && agent.Policy != "on-demand" {
+		return fmt.Errorf("config: agent %q %s requires always-on or on-demand policy", name, kind)
+	}
+	if agent.Container.Name != "" {
+		return fmt.Errorf("config: agent %q cannot set both %s and container.name", name, kind)
+	}
+	if agent.Replicas > 1 {
+		return fmt.Errorf("config: agent %q replicas requires a container", name)
+	}
+	for _, p := range []*Probe{&agent.Health.Probe, agent.Health.Startup, agent.Health.Readiness, agent.Health.Liveness} {
+		if p != nil && p.Type == "exec" {
+			return fmt.Errorf("config: agent %q exec probes require a container", name)
+		}
+	}
+	return nil
+}
+
+// validateDependencies checks that every depends_on entry names another
+// configured agent and that following them never leads back to the start.
+func validateDependencies(agents map[string]*Agent) error {
+	names := make([]string, 0, len(agents))
+	for name, agent := range agents {
+		for _, dep := range agent.DependsOn {
+			if dep == name {
+				return fmt.Errorf("config: agent %q depends on itself", name)
+			}
+			if _, ok := agents[dep]; !ok {
+				return fmt.Errorf("config: agent %q depends_on unknown agent %q", name, dep)
+			}
+		}
+		names = append(names, name)
+	}
+	// Walk in name order so the reported cycle is stable.
+	slices.Sort(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(agents))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			start := slices.Index(path, name)
+			cycle := append(slices.Clone(path[start:]), name)
+			return fmt.Errorf("config: dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range agents[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSchedule checks that the timezone loads and every expression parses
+// as a standard five-field cron spec.
+func validateSchedule(sched ScheduleConfig) error {
+	if _, err := time.LoadLocation(sched.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", sched.Timezone, err)
+	}
+	for _, expr := range sched.Wake {
+		if _, err := cron.ParseStandard(expr); err != nil {
+			return fmt.Errorf("invalid wake %q: %w", expr, err)
+		}
+	}
+	windows := map[string][]ScheduleWindow{"keep_awake": sched.KeepAwake, "quiet_hours": sched.QuietHours}
+	for kind, list := range windows {
+		for _, w := range list {
+			if _, err := cron.ParseStandard(w.Start); err != nil {
+				return fmt.Errorf("invalid %s start %q: %w", kind, w.Start, err)
+			}
+			if w.Duration <= 0 {
+				return fmt.Errorf("%s %q requires duration > 0", kind, w.Start)
+			}
+		}
+	}
+	return nil
+}
+
+// validateHealth checks the main probe and any startup, readiness or liveness
+// probes.
+func validateHealth(h Health) error {
+	if h.StartupInterval < 0 {
+		return fmt.Errorf("health.startup_interval must not be negative")
+	}
+	if h.usesMainProbe() {
+		if err := validateProbe("health", h.Probe); err != nil {
+			return err
+		}
+	}
+	stages := []struct {
+		name  string
+		probe *Probe
+	}{
+		{"health.startup", h.Startup},
+		{"health.readiness", h.Readiness},
+		{"health.liveness", h.Liveness},
+	}
+	for _, st := range stages {
+		if st.probe == nil {
+			continue
+		}
+		if st.probe.URL == "" && st.probe.NeedsURL() {
+			return fmt.Errorf("%s requires url", st.name)
+		}
+		if err := validateProbe(st.name, *st.probe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProbe checks that the probe type is known and has what it needs.
+// prefix names the probe in errors, e.g. "health" or "health.readiness".
+func validateProbe(prefix string, p Probe) error {
+	switch p.Type {
+	case "", "http", "websocket":
+	case "tcp":
+		if p.Address == "" && p.URL == "" {
+			return fmt.Errorf("tcp probe requires %s.address or %s.url", prefix, prefix)
+		}
+	case "exec":
+		if len(p.Command) == 0 {
+			return fmt.Errorf("exec probe requires %s.command", prefix)
+		}
+	default:
+		return fmt.Errorf("unknown %s.type %q", prefix, p.Type)
+	}
+	if p.Timeout < 0 {
+		return fmt.Errorf("%s.timeout must not be negative", prefix)
+	}
+	for _, code := range p.ExpectStatus {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("invalid %s.expect_status %d", prefix, code)
+		}
+	}
+	return nil
+}
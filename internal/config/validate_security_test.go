@@ -45,7 +45,7 @@ func TestValidate_InvalidHealthURLSchemeRejected(t *testing.T) {
 			Backend:   "http://x",
 			Policy:    "always-on",
 			Container: Container{Name: "svc"},
-			Health:    Health{URL: "ftp://x/health"},
+			Health:    Health{Probe: Probe{URL: "ftp://x/health"}},
 		},
 	}}
 	err := validate(cfg)
@@ -82,7 +82,7 @@ func TestValidate_WakeCooldownDefault(t *testing.T) {
 			Backend:   "http://x",
 			Policy:    "on-demand",
 			Container: Container{Name: "svc"},
-			Health:    Health{URL: "http://x/h"},
+			Health:    Health{Probe: Probe{URL: "http://x/h"}},
 			Idle:      IdleConfig{Timeout: time.Minute},
 		},
 	}}
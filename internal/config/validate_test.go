@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -49,7 +51,7 @@ func TestValidateErrors(t *testing.T) {
 			name: "on-demand missing container name",
 			cfg: &Config{Agents: map[string]*Agent{
 				"a": {Hostname: "a.com", Backend: "http://x", Policy: "on-demand",
-					Health: Health{URL: "http://x/h"}, Idle: IdleConfig{Timeout: time.Minute}},
+					Health: Health{Probe: Probe{URL: "http://x/h"}}, Idle: IdleConfig{Timeout: time.Minute}},
 			}},
 			wantErr: "requires container.name",
 		},
@@ -65,7 +67,7 @@ func TestValidateErrors(t *testing.T) {
 			name: "always-on missing container name",
 			cfg: &Config{Agents: map[string]*Agent{
 				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
-					Health: Health{URL: "http://x/h"}},
+					Health: Health{Probe: Probe{URL: "http://x/h"}}},
 			}},
 			wantErr: "requires container.name",
 		},
@@ -93,6 +95,308 @@ func TestValidateErrors(t *testing.T) {
 			}},
 			wantErr: "duplicate hostname",
 		},
+		{
+			name: "duplicate hostname and path prefix",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "api.com", PathPrefix: "/x/", Backend: "http://x", Policy: "unmanaged"},
+				"b": {Hostname: "api.com", PathPrefix: "/x", Backend: "http://y", Policy: "unmanaged"},
+			}},
+			wantErr: "duplicate hostname",
+		},
+		{
+			name: "invalid path prefix",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "api.com", PathPrefix: "x", Backend: "http://x", Policy: "unmanaged"},
+			}},
+			wantErr: "path_prefix",
+		},
+		{
+			name: "unknown load balancing strategy",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged", LoadBalancing: LoadBalancingConfig{Strategy: "random"}},
+			}},
+			wantErr: "unknown load_balancing.strategy",
+		},
+		{
+			name: "consistent hash without key",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged", LoadBalancing: LoadBalancingConfig{Strategy: "consistent-hash"}},
+			}},
+			wantErr: "requires hash_header or hash_cookie",
+		},
+		{
+			name: "invalid backends entry",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Backends: []string{"not a url"}, Policy: "unmanaged"},
+			}},
+			wantErr: "invalid backends entry",
+		},
+		{
+			name: "autoscale without targets",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "autoscale",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}},
+					Autoscale: AutoscaleConfig{Min: 1, Max: 3}},
+			}},
+			wantErr: "requires target_rps or target_connections",
+		},
+		{
+			name: "autoscale max below min",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "autoscale",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}},
+					Autoscale: AutoscaleConfig{Min: 3, Max: 2, TargetRPS: 10}},
+			}},
+			wantErr: "1 <= min <= max",
+		},
+		{
+			name: "schedule on non on-demand agent",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged",
+					Idle: IdleConfig{Schedule: ScheduleConfig{Wake: []string{"45 8 * * MON-FRI"}}}},
+			}},
+			wantErr: "requires on-demand policy",
+		},
+		{
+			name: "invalid schedule expression",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "on-demand",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}},
+					Idle: IdleConfig{Timeout: time.Minute, Schedule: ScheduleConfig{Wake: []string{"weekday mornings"}}}},
+			}},
+			wantErr: "invalid wake",
+		},
+		{
+			name: "invalid schedule timezone",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "on-demand",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}},
+					Idle: IdleConfig{Timeout: time.Minute, Schedule: ScheduleConfig{
+						Timezone:   "Nowhere/Special",
+						QuietHours: []ScheduleWindow{{Start: "0 22 * * *", Duration: 9 * time.Hour}},
+					}}},
+			}},
+			wantErr: "invalid timezone",
+		},
+		{
+			name: "schedule window without duration",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "on-demand",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}},
+					Idle: IdleConfig{Timeout: time.Minute, Schedule: ScheduleConfig{
+						KeepAwake: []ScheduleWindow{{Start: "0 9 * * MON-FRI"}},
+					}}},
+			}},
+			wantErr: "requires duration > 0",
+		},
+		{
+			name: "recovery backoff above max",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "on-demand",
+					Container: Container{Name: "a"}, Idle: IdleConfig{Timeout: time.Minute},
+					Health: Health{Probe: Probe{URL: "http://x/health"}, RecoveryBackoff: time.Minute, RecoveryMaxBackoff: time.Second}},
+			}},
+			wantErr: "health.recovery_backoff",
+		},
+		{
+			name: "unknown health probe type",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health", Type: "grpc"}}},
+			}},
+			wantErr: "unknown health.type",
+		},
+		{
+			name: "exec probe without command",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{Type: "exec"}}},
+			}},
+			wantErr: "requires health.command",
+		},
+		{
+			name: "readiness probe without url",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
+					Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}, Readiness: &Probe{}}},
+			}},
+			wantErr: "health.readiness requires url",
+		},
+		{
+			name: "overlapping wildcard hostnames",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "*.dev.example.com", Backend: "http://x", Policy: "unmanaged"},
+				"b": {Hostname: "*.DEV.example.com", Backend: "http://y", Policy: "unmanaged"},
+			}},
+			wantErr: "overlapping wildcard",
+		},
+		{
+			name: "wildcard too broad",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "*.com", Backend: "http://x", Policy: "unmanaged"},
+			}},
+			wantErr: "too broad",
+		},
+		{
+			name: "unknown cold start mode",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged",
+					ColdStart: ColdStartConfig{Mode: "queue"}},
+			}},
+			wantErr: "unknown cold_start.mode",
+		},
+		{
+			name: "missing interstitial template",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged",
+					ColdStart: ColdStartConfig{Interstitial: true, InterstitialTemplate: "/nonexistent/waking.html"}},
+			}},
+			wantErr: "cold_start.interstitial_template",
+		},
+		{
+			name: "eviction on unmanaged agent",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged",
+					Eviction: EvictionConfig{Pinned: true}},
+			}},
+			wantErr: "eviction requires on-demand policy",
+		},
+		{
+			name: "process on unmanaged agent",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged",
+					Process: &ProcessConfig{Command: []string{"openclaw"}}},
+			}},
+			wantErr: "process requires always-on or on-demand policy",
+		},
+		{
+			name: "process without command",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
+					Health: Health{Probe: Probe{URL: "http://x/health"}}, Process: &ProcessConfig{}},
+			}},
+			wantErr: "process.command is required",
+		},
+		{
+			name: "process and container",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on", Container: Container{Name: "a"},
+					Process: &ProcessConfig{Command: []string{"openclaw"}}},
+			}},
+			wantErr: "cannot set both process and container.name",
+		},
+		{
+			name: "process with exec probe",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
+					Health:  Health{Probe: Probe{Type: "exec", Command: []string{"true"}}},
+					Process: &ProcessConfig{Command: []string{"openclaw"}}},
+			}},
+			wantErr: "exec probes require a container",
+		},
+		{
+			name: "systemd without unit",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "on-demand",
+					Health:  Health{Probe: Probe{URL: "http://x/health"}},
+					Idle:    IdleConfig{Timeout: time.Minute},
+					Systemd: &SystemdConfig{}},
+			}},
+			wantErr: "systemd.unit is required",
+		},
+		{
+			name: "systemd and process",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "always-on",
+					Health:  Health{Probe: Probe{URL: "http://x/health"}},
+					Process: &ProcessConfig{Command: []string{"openclaw"}},
+					Systemd: &SystemdConfig{Unit: "a.service"}},
+			}},
+			wantErr: "cannot set both process and systemd",
+		},
+		{
+			name: "systemd on autoscale agent",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "autoscale",
+					Systemd: &SystemdConfig{Unit: "a.service"}},
+			}},
+			wantErr: "systemd requires always-on or on-demand policy",
+		},
+		{
+			name: "unknown runtime",
+			cfg: &Config{
+				Runtime: "k8s",
+				Agents: map[string]*Agent{
+					"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged"},
+				},
+			},
+			wantErr: "unknown runtime",
+		},
+		{
+			name: "autoscale under docker runtime",
+			cfg: &Config{
+				Runtime: "docker",
+				Agents: map[string]*Agent{
+					"a": {Hostname: "a.com", Backend: "http://x", Policy: "autoscale",
+						Container: Container{Name: "a"}, Health: Health{Probe: Probe{URL: "http://x/health"}},
+						Autoscale: AutoscaleConfig{Min: 1, Max: 3, TargetRPS: 10}},
+				},
+			},
+			wantErr: "autoscale policy requires runtime swarm",
+		},
+		{
+			name: "replicas under docker runtime",
+			cfg: &Config{
+				Runtime: "docker",
+				Agents: map[string]*Agent{
+					"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged", Replicas: 2},
+				},
+			},
+			wantErr: "replicas requires runtime swarm",
+		},
+		{
+			name: "memory threshold out of range",
+			cfg: &Config{
+				Agents: map[string]*Agent{
+					"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged"},
+				},
+				Resources: ResourcesConfig{MemoryThreshold: 1.5},
+			},
+			wantErr: "resources.memory_threshold",
+		},
+		{
+			name: "negative reconcile interval",
+			cfg: &Config{
+				Agents: map[string]*Agent{
+					"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged"},
+				},
+				Reconcile: ReconcileConfig{Interval: -time.Second},
+			},
+			wantErr: "reconcile.interval",
+		},
+		{
+			name: "depends_on unknown agent",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"db"}},
+			}},
+			wantErr: `depends_on unknown agent "db"`,
+		},
+		{
+			name: "depends_on itself",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"a"}},
+			}},
+			wantErr: "depends on itself",
+		},
+		{
+			name: "dependency cycle",
+			cfg: &Config{Agents: map[string]*Agent{
+				"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"b"}},
+				"b": {Hostname: "b.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"c"}},
+				"c": {Hostname: "c.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"a"}},
+			}},
+			wantErr: "dependency cycle: a -> b -> c -> a",
+		},
 	}
 
 	for _, tt := range tests {
@@ -116,3 +420,50 @@ func TestValidateSuccess(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestValidateSharedHostnameDistinctPrefixes(t *testing.T) {
+	cfg := &Config{Agents: map[string]*Agent{
+		"a": {Hostname: "api.com", PathPrefix: "/agent-a", Backend: "http://x", Policy: "unmanaged"},
+		"b": {Hostname: "api.com", PathPrefix: "/agent-b", Backend: "http://y", Policy: "unmanaged"},
+	}}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateDependencyDiamond(t *testing.T) {
+	// Two paths to the same dependency is not a cycle.
+	cfg := &Config{Agents: map[string]*Agent{
+		"app":   {Hostname: "app.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"api", "cache"}},
+		"api":   {Hostname: "api.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"db"}},
+		"cache": {Hostname: "cache.com", Backend: "http://x", Policy: "unmanaged", DependsOn: []string{"db"}},
+		"db":    {Hostname: "db.com", Backend: "http://x", Policy: "unmanaged"},
+	}}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateNestedWildcardsAllowed(t *testing.T) {
+	// Nested wildcards across agents are resolved by longest suffix.
+	cfg := &Config{Agents: map[string]*Agent{
+		"a": {Hostname: "*.example.com", Backend: "http://x", Policy: "unmanaged"},
+		"b": {Hostname: "*.dev.example.com", Backend: "http://y", Policy: "unmanaged"},
+	}}
+	if err := validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateInterstitialTemplateParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "waking.html")
+	os.WriteFile(path, []byte("<p>{{.Agent</p>"), 0o644)
+	cfg := &Config{Agents: map[string]*Agent{
+		"a": {Hostname: "a.com", Backend: "http://x", Policy: "unmanaged",
+			ColdStart: ColdStartConfig{Interstitial: true, InterstitialTemplate: path}},
+	}}
+	err := validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cold_start.interstitial_template") {
+		t.Errorf("err = %v, want a template parse error", err)
+	}
+}
@@ -3,10 +3,15 @@ package container
 import (
 	"context"
 	"log/slog"
+	"strings"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+
+	"warren/internal/config"
 )
 
 type DiscoveredContainer struct {
@@ -49,10 +54,62 @@ func Discover(ctx context.Context, docker *client.Client, logger *slog.Logger) (
 		result = append(result, DiscoveredContainer{
 			Name:   name,
 			ID:     c.ID,
-			State:  c.State,
+			State:  ContainerStatus(c.State),
 			Labels: c.Labels,
 		})
 	}
 
 	return result, nil
 }
+
+// LabelClient is the part of the Docker API used to find labelled agents.
+type LabelClient interface {
+	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
+}
+
+// LabeledAgent is a Swarm service or container that declares an agent
+// through warren.* labels.
+type LabeledAgent struct {
+	Agent   string // the orchestrator.agent label, or Managed if unset
+	Managed string // service or container name
+	Labels  map[string]string
+}
+
+// DiscoverLabeled lists the Swarm services, or with the docker runtime the
+// containers, carrying a warren.hostname label.
+func DiscoverLabeled(ctx context.Context, docker LabelClient, runtime string) ([]LabeledAgent, error) {
+	f := filters.NewArgs()
+	f.Add("label", config.LabelHostname)
+
+	var result []LabeledAgent
+	add := func(name string, labels map[string]string) {
+		agent := labels["orchestrator.agent"]
+		if agent == "" {
+			agent = name
+		}
+		result = append(result, LabeledAgent{Agent: agent, Managed: name, Labels: labels})
+	}
+
+	if runtime == "docker" {
+		containers, err := docker.ContainerList(ctx, container.ListOptions{All: true, Filters: f})
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			if len(c.Names) > 0 {
+				add(strings.TrimPrefix(c.Names[0], "/"), c.Labels)
+			}
+		}
+		return result, nil
+	}
+
+	svcs, err := docker.ServiceList(ctx, types.ServiceListOptions{Filters: f})
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range svcs {
+		add(svc.Spec.Name, svc.Spec.Labels)
+	}
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+type fakeLabelClient struct {
+	services   []swarm.Service
+	containers []types.Container
+	filter     string
+}
+
+func (f *fakeLabelClient) ServiceList(_ context.Context, opts types.ServiceListOptions) ([]swarm.Service, error) {
+	f.filter = opts.Filters.Get("label")[0]
+	return f.services, nil
+}
+
+func (f *fakeLabelClient) ContainerList(_ context.Context, opts container.ListOptions) ([]types.Container, error) {
+	f.filter = opts.Filters.Get("label")[0]
+	return f.containers, nil
+}
+
+func TestDiscoverLabeled(t *testing.T) {
+	svc := swarm.Service{}
+	svc.Spec.Name = "openclaw-research"
+	svc.Spec.Labels = map[string]string{"warren.hostname": "research.example.com", "orchestrator.agent": "research"}
+	docker := &fakeLabelClient{
+		services:   []swarm.Service{svc},
+		containers: []types.Container{{Names: []string{"/scratch"}, Labels: map[string]string{"warren.hostname": "scratch.example.com"}}},
+	}
+	ctx := context.Background()
+
+	found, err := DiscoverLabeled(ctx, docker, "swarm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docker.filter != "warren.hostname" {
+		t.Errorf("filter = %q", docker.filter)
+	}
+	if len(found) != 1 || found[0].Agent != "research" || found[0].Managed != "openclaw-research" {
+		t.Errorf("swarm discovery = %+v", found)
+	}
+
+	found, err = DiscoverLabeled(ctx, docker, "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Agent != "scratch" || found[0].Managed != "scratch" {
+		t.Errorf("docker discovery = %+v", found)
+	}
+}
@@ -0,0 +1,106 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"warren/internal/config"
+)
+
+// DockerClient is the part of the Docker API used to manage plain containers.
+type DockerClient interface {
+	ExecSessionClient
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerRestart(ctx context.Context, containerID string, options container.StopOptions) error
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+}
+
+// ContainerManager manages plain Docker containers by name, for hosts that
+// aren't part of a Swarm. Containers must already exist; they are started and
+// stopped, never created or removed.
+type ContainerManager struct {
+	docker DockerClient
+	logger *slog.Logger
+}
+
+// NewContainerManager creates a manager for plain containers.
+func NewContainerManager(docker DockerClient, logger *slog.Logger) *ContainerManager {
+	return &ContainerManager{docker: docker, logger: logger}
+}
+
+func (m *ContainerManager) Start(ctx context.Context, name string) error {
+	m.logger.Info("starting container", "container", name)
+	if err := m.docker.ContainerStart(ctx, name, container.StartOptions{}); err != nil {
+		return fmt.Errorf("start container %q: %w", name, err)
+	}
+	return nil
+}
+
+func (m *ContainerManager) Stop(ctx context.Context, name string, gracePeriod time.Duration) error {
+	m.logger.Info("stopping container", "container", name, "grace_period", gracePeriod)
+	if err := m.docker.ContainerStop(ctx, name, stopOptions(gracePeriod)); err != nil {
+		return fmt.Errorf("stop container %q: %w", name, err)
+	}
+	return nil
+}
+
+func (m *ContainerManager) Restart(ctx context.Context, name string, gracePeriod time.Duration) error {
+	m.logger.Info("restarting container", "container", name, "grace_period", gracePeriod)
+	if err := m.docker.ContainerRestart(ctx, name, stopOptions(gracePeriod)); err != nil {
+		return fmt.Errorf("restart container %q: %w", name, err)
+	}
+	return nil
+}
+
+// Status reports the container's state as "running", "starting" or "exited".
+func (m *ContainerManager) Status(ctx context.Context, name string) (string, error) {
+	info, err := m.docker.ContainerInspect(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("inspect container %q: %w", name, err)
+	}
+	if info.State == nil {
+		return "exited", nil
+	}
+	return ContainerStatus(info.State.Status), nil
+}
+
+// Prober returns the health probe for a container, running exec probes in
+// the container itself.
+func (m *ContainerManager) Prober(name string, p config.Probe) Prober {
+	var docker ExecClient
+	if m.docker != nil {
+		docker = m.docker
+	}
+	probe := NewProber(p, "", docker)
+	if exec, ok := probe.(*ExecProbe); ok {
+		exec.Container = name
+	}
+	return probe
+}
+
+// ContainerStatus maps a Docker container state to the status reported by
+// Lifecycle: "running", "starting" while Docker is restarting it, and
+// "exited" for everything else (created, paused, exited, dead, removing).
+func ContainerStatus(state string) string {
+	switch state {
+	case "running":
+		return "running"
+	case "restarting":
+		return "starting"
+	default:
+		return "exited"
+	}
+}
+
+func stopOptions(gracePeriod time.Duration) container.StopOptions {
+	secs := int(gracePeriod.Seconds())
+	return container.StopOptions{Timeout: &secs}
+}
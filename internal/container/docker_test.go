@@ -0,0 +1,134 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+
+	"warren/internal/config"
+)
+
+type fakeDockerClient struct {
+	ExecSessionClient // unused methods panic
+
+	calls   []string
+	timeout *int
+	state   *types.ContainerState
+	tty     bool
+	logs    []byte // multiplexed, or raw with tty
+	logOpts container.LogsOptions
+	err     error
+}
+
+func (f *fakeDockerClient) ContainerStart(_ context.Context, id string, _ container.StartOptions) error {
+	f.calls = append(f.calls, "start "+id)
+	return f.err
+}
+
+func (f *fakeDockerClient) ContainerStop(_ context.Context, id string, opts container.StopOptions) error {
+	f.calls = append(f.calls, "stop "+id)
+	f.timeout = opts.Timeout
+	return f.err
+}
+
+func (f *fakeDockerClient) ContainerRestart(_ context.Context, id string, opts container.StopOptions) error {
+	f.calls = append(f.calls, "restart "+id)
+	f.timeout = opts.Timeout
+	return f.err
+}
+
+func (f *fakeDockerClient) ContainerInspect(_ context.Context, id string) (types.ContainerJSON, error) {
+	if f.err != nil {
+		return types.ContainerJSON{}, f.err
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{Name: "/" + id, State: f.state},
+		Config:            &container.Config{Tty: f.tty},
+	}, nil
+}
+
+func (f *fakeDockerClient) ContainerLogs(_ context.Context, id string, opts container.LogsOptions) (io.ReadCloser, error) {
+	f.calls = append(f.calls, "logs "+id)
+	f.logOpts = opts
+	return io.NopCloser(bytes.NewReader(f.logs)), f.err
+}
+
+func testContainerManager(docker *fakeDockerClient) *ContainerManager {
+	return NewContainerManager(docker, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestContainerManagerLifecycle(t *testing.T) {
+	docker := &fakeDockerClient{}
+	m := testContainerManager(docker)
+	ctx := context.Background()
+
+	if err := m.Start(ctx, "web"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(ctx, "web", 15*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if docker.timeout == nil || *docker.timeout != 15 {
+		t.Errorf("stop timeout = %v, want 15s", docker.timeout)
+	}
+	if err := m.Restart(ctx, "web", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"start web", "stop web", "restart web"}
+	if len(docker.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", docker.calls, want)
+	}
+	for i := range want {
+		if docker.calls[i] != want[i] {
+			t.Errorf("calls[%d] = %q, want %q", i, docker.calls[i], want[i])
+		}
+	}
+
+	docker.err = errors.New("no such container")
+	if err := m.Start(ctx, "missing"); err == nil {
+		t.Error("expected start error to be returned")
+	}
+}
+
+func TestContainerManagerStatus(t *testing.T) {
+	tests := map[string]string{
+		"running":    "running",
+		"restarting": "starting",
+		"created":    "exited",
+		"paused":     "exited",
+		"exited":     "exited",
+		"dead":       "exited",
+	}
+	for state, want := range tests {
+		m := testContainerManager(&fakeDockerClient{state: &types.ContainerState{Status: state}})
+		got, err := m.Status(context.Background(), "web")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Status with container %s = %q, want %q", state, got, want)
+		}
+	}
+}
+
+func TestContainerManagerExecProbeUsesContainer(t *testing.T) {
+	m := testContainerManager(&fakeDockerClient{})
+	probe, ok := m.Prober("web", config.Probe{Type: "exec", Command: []string{"true"}}).(*ExecProbe)
+	if !ok {
+		t.Fatal("expected an exec probe")
+	}
+	if probe.Container != "web" || probe.Service != "" {
+		t.Errorf("probe = %+v, want exec in container web", probe)
+	}
+	id, err := probe.taskContainer(context.Background())
+	if err != nil || id != "web" {
+		t.Errorf("taskContainer = %q, %v, want web", id, err)
+	}
+}
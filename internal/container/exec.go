@@ -0,0 +1,198 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ExecOptions describes a command to run in an agent's container.
+type ExecOptions struct {
+	Cmd    []string
+	TTY    bool
+	Width  uint // initial terminal size, with TTY; 0 = Docker's default
+	Height uint
+}
+
+// ExecSession is a command running in a container with its input and output
+// attached.
+type ExecSession interface {
+	// Container is the ID of the container the command runs in.
+	Container() string
+	// Write sends input to the command.
+	Write(p []byte) (int, error)
+	// CloseStdin tells the command its input has ended.
+	CloseStdin() error
+	// Output copies the command's output until it exits or the session is
+	// closed. With a TTY all output is written to stdout.
+	Output(stdout, stderr io.Writer) error
+	// Resize changes the size of the command's terminal.
+	Resize(ctx context.Context, width, height uint) error
+	// ExitCode returns the command's exit code once Output has returned.
+	ExitCode(ctx context.Context) (int, error)
+	// Close detaches from the command.
+	Close() error
+}
+
+// Execer is implemented by runtimes that can run commands inside what they
+// manage.
+type Execer interface {
+	Exec(ctx context.Context, name string, opts ExecOptions) (ExecSession, error)
+}
+
+// ExecSessionClient is the part of the Docker API used for interactive exec
+// sessions.
+type ExecSessionClient interface {
+	ExecClient
+	ContainerExecResize(ctx context.Context, execID string, options container.ResizeOptions) error
+}
+
+// Exec runs a command in one of the service's running task containers on
+// this node. Docker can only exec in its own containers, so a service whose
+// tasks all run on other Swarm nodes can't be reached; the error names the
+// nodes they run on.
+func (m *Manager) Exec(ctx context.Context, name string, opts ExecOptions) (ExecSession, error) {
+	id, err := runningTask(ctx, m.docker, name)
+	if errors.Is(err, ErrNoRunningTask) {
+		if nodes := m.taskNodes(ctx, name); len(nodes) > 0 {
+			return nil, fmt.Errorf("service %s: %w on this node, only on %s", name, ErrNoRunningTask, strings.Join(nodes, ", "))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return startExec(ctx, m.docker, id, opts)
+}
+
+// taskNodes returns the IDs of the Swarm nodes running tasks of service.
+func (m *Manager) taskNodes(ctx context.Context, service string) []string {
+	tasks, err := m.docker.TaskList(ctx, types.TaskListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("service", service),
+			filters.Arg("desired-state", "running"),
+		),
+	})
+	if err != nil {
+		return nil
+	}
+	var nodes []string
+	for _, task := range tasks {
+		if task.Status.State == "running" && !slices.Contains(nodes, task.NodeID) {
+			nodes = append(nodes, task.NodeID)
+		}
+	}
+	return nodes
+}
+
+// Exec runs a command in the container.
+func (m *ContainerManager) Exec(ctx context.Context, name string, opts ExecOptions) (ExecSession, error) {
+	return startExec(ctx, m.docker, name, opts)
+}
+
+// ErrNoRunningTask is returned when a service has no running container to
+// exec in.
+var ErrNoRunningTask = errors.New("no running container")
+
+// runningTask returns the ID of a running container of a Swarm service on
+// the local node.
+func runningTask(ctx context.Context, docker ExecClient, service string) (string, error) {
+	f := filters.NewArgs()
+	f.Add("label", swarmServiceLabel+"="+service)
+	f.Add("status", "running")
+	containers, err := docker.ContainerList(ctx, container.ListOptions{Filters: f})
+	if err != nil {
+		return "", fmt.Errorf("list containers of service %s: %w", service, err)
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("service %s: %w", service, ErrNoRunningTask)
+	}
+	return containers[0].ID, nil
+}
+
+func startExec(ctx context.Context, docker ExecSessionClient, id string, opts ExecOptions) (ExecSession, error) {
+	var size *[2]uint
+	if opts.TTY && opts.Width > 0 && opts.Height > 0 {
+		size = &[2]uint{opts.Height, opts.Width}
+	}
+	exec, err := docker.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          opts.Cmd,
+		Tty:          opts.TTY,
+		ConsoleSize:  size,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exec create in %s: %w", id, err)
+	}
+	resp, err := docker.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{Tty: opts.TTY, ConsoleSize: size})
+	if err != nil {
+		return nil, fmt.Errorf("exec attach in %s: %w", id, err)
+	}
+	return &dockerExec{docker: docker, container: id, id: exec.ID, tty: opts.TTY, resp: resp}, nil
+}
+
+// dockerExec is an ExecSession over a hijacked Docker exec connection.
+type dockerExec struct {
+	docker    ExecSessionClient
+	container string
+	id        string
+	tty       bool
+	resp      types.HijackedResponse
+}
+
+func (e *dockerExec) Container() string { return e.container }
+
+func (e *dockerExec) Write(p []byte) (int, error) { return e.resp.Conn.Write(p) }
+
+func (e *dockerExec) CloseStdin() error { return e.resp.CloseWrite() }
+
+func (e *dockerExec) Output(stdout, stderr io.Writer) error {
+	var err error
+	if e.tty {
+		_, err = io.Copy(stdout, e.resp.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, e.resp.Reader)
+	}
+	return err
+}
+
+func (e *dockerExec) Resize(ctx context.Context, width, height uint) error {
+	return e.docker.ContainerExecResize(ctx, e.id, container.ResizeOptions{Width: width, Height: height})
+}
+
+// ExitCode waits briefly for Docker to record the exit, which can lag
+// behind the end of the output stream.
+func (e *dockerExec) ExitCode(ctx context.Context) (int, error) {
+	for i := 0; ; i++ {
+		inspect, err := e.docker.ContainerExecInspect(ctx, e.id)
+		if err != nil {
+			return 0, fmt.Errorf("exec inspect: %w", err)
+		}
+		if !inspect.Running {
+			return inspect.ExitCode, nil
+		}
+		if i == 10 {
+			return 0, errors.New("exec is still running")
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (e *dockerExec) Close() error {
+	e.resp.Close()
+	return nil
+}
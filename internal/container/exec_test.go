@@ -0,0 +1,103 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// fakeExecSessionClient attaches exec sessions to a pipe whose far end the
+// test reads stdin from.
+type fakeExecSessionClient struct {
+	ExecClient // unused methods panic
+
+	create  container.ExecOptions
+	output  []byte
+	stdin   net.Conn // the test's end of the session
+	resized container.ResizeOptions
+}
+
+func (f *fakeExecSessionClient) ContainerExecCreate(_ context.Context, _ string, opts container.ExecOptions) (types.IDResponse, error) {
+	f.create = opts
+	return types.IDResponse{ID: "exec1"}, nil
+}
+
+func (f *fakeExecSessionClient) ContainerExecAttach(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+	client, server := net.Pipe()
+	f.stdin = server
+	return types.HijackedResponse{Conn: client, Reader: bufio.NewReader(bytes.NewReader(f.output))}, nil
+}
+
+func (f *fakeExecSessionClient) ContainerExecInspect(_ context.Context, _ string) (container.ExecInspect, error) {
+	return container.ExecInspect{ExitCode: 3}, nil
+}
+
+func (f *fakeExecSessionClient) ContainerExecResize(_ context.Context, _ string, opts container.ResizeOptions) error {
+	f.resized = opts
+	return nil
+}
+
+func TestContainerManagerExec(t *testing.T) {
+	var muxed bytes.Buffer
+	stdcopy.NewStdWriter(&muxed, stdcopy.Stdout).Write([]byte("out"))
+	stdcopy.NewStdWriter(&muxed, stdcopy.Stderr).Write([]byte("err"))
+	exec := &fakeExecSessionClient{output: muxed.Bytes()}
+	m := testContainerManager(&fakeDockerClient{ExecSessionClient: exec})
+	ctx := context.Background()
+
+	s, err := m.Exec(ctx, "web", ExecOptions{Cmd: []string{"cat"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if c := exec.create; c.Tty || !c.AttachStdin || c.Cmd[0] != "cat" || c.ConsoleSize != nil {
+		t.Errorf("exec options = %+v", c)
+	}
+
+	go s.Write([]byte("in"))
+	got := make([]byte, 2)
+	if _, err := io.ReadFull(exec.stdin, got); err != nil || string(got) != "in" {
+		t.Errorf("stdin = %q, %v", got, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := s.Output(&stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+	if code, err := s.ExitCode(ctx); err != nil || code != 3 {
+		t.Errorf("exit code = %d, %v", code, err)
+	}
+}
+
+func TestContainerManagerExecTTY(t *testing.T) {
+	exec := &fakeExecSessionClient{output: []byte("$ ")}
+	m := testContainerManager(&fakeDockerClient{ExecSessionClient: exec})
+	ctx := context.Background()
+
+	s, err := m.Exec(ctx, "web", ExecOptions{Cmd: []string{"sh"}, TTY: true, Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if c := exec.create; !c.Tty || c.ConsoleSize == nil || *c.ConsoleSize != [2]uint{24, 80} {
+		t.Errorf("exec options = %+v", c)
+	}
+
+	var stdout bytes.Buffer
+	if err := s.Output(&stdout, nil); err != nil || stdout.String() != "$ " {
+		t.Errorf("tty output = %q, %v", stdout.String(), err)
+	}
+	if err := s.Resize(ctx, 120, 40); err != nil || exec.resized.Width != 120 || exec.resized.Height != 40 {
+		t.Errorf("resize = %+v, %v", exec.resized, err)
+	}
+}
@@ -1,31 +1,141 @@
 package container
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var healthClient = &http.Client{
-	Timeout: 5 * time.Second,
-}
+var healthClient = &http.Client{}
+
+// maxProbeBody bounds how much of a response an HTTP probe reads.
+const maxProbeBody = 1 << 20
 
+// CheckHealth performs a plain HTTP GET and treats any 2xx or 3xx as healthy.
 func CheckHealth(ctx context.Context, url string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	return (&HTTPProbe{URL: url}).Probe(ctx)
+}
+
+// HTTPProbe checks an HTTP endpoint. With no expectations set, any 2xx or 3xx
+// status is healthy.
+type HTTPProbe struct {
+	URL          string
+	Headers      map[string]string
+	ExpectStatus []int             // acceptable status codes
+	ExpectBody   string            // substring the body must contain
+	ExpectJSON   map[string]string // dotted path → expected value
+	Timeout      time.Duration     // default 5s
+}
+
+func (p *HTTPProbe) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, orDefault(p.Timeout, 5*time.Second))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
 	if err != nil {
 		return fmt.Errorf("create health request: %w", err)
 	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	if host := p.Headers["Host"]; host != "" {
+		req.Host = host
+	}
 
 	resp, err := healthClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("health check failed: %w", err)
 	}
-	resp.Body.Close()
+	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+	if len(p.ExpectStatus) > 0 {
+		if !slices.Contains(p.ExpectStatus, resp.StatusCode) {
+			return fmt.Errorf("health check returned status %d, want one of %v", resp.StatusCode, p.ExpectStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
 		return fmt.Errorf("health check returned status %d", resp.StatusCode)
 	}
 
+	if p.ExpectBody == "" && len(p.ExpectJSON) == 0 {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return fmt.Errorf("read health response: %w", err)
+	}
+	if p.ExpectBody != "" && !bytes.Contains(body, []byte(p.ExpectBody)) {
+		return fmt.Errorf("health response does not contain %q", p.ExpectBody)
+	}
+	if len(p.ExpectJSON) > 0 {
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fmt.Errorf("health response is not JSON: %w", err)
+		}
+		for path, want := range p.ExpectJSON {
+			v, ok := jsonPath(doc, path)
+			if !ok {
+				return fmt.Errorf("health response has no %q", path)
+			}
+			if got := jsonString(v); got != want {
+				return fmt.Errorf("health response %s = %q, want %q", path, got, want)
+			}
+		}
+	}
 	return nil
 }
+
+// jsonPath walks a dotted path such as "checks.db.status" or "items.0.ok"
+// through a decoded JSON document.
+func jsonPath(doc any, path string) (any, bool) {
+	cur := doc
+	for _, key := range strings.Split(path, ".") {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// jsonString formats a decoded JSON scalar the way it appears in the source.
+func jsonString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
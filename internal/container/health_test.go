@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCheckHealthHealthy(t *testing.T) {
@@ -32,3 +34,60 @@ func TestCheckHealthUnreachable(t *testing.T) {
 		t.Error("expected error for unreachable server")
 	}
 }
+
+func TestHTTPProbeExpectations(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer probe" {
+			w.WriteHeader(401)
+			return
+		}
+		w.WriteHeader(202)
+		w.Write([]byte(`{"status":"ok","checks":{"db":{"up":true}},"workers":[{"busy":3}]}`))
+	}))
+	defer srv.Close()
+
+	headers := map[string]string{"Authorization": "Bearer probe"}
+	tests := []struct {
+		name    string
+		probe   HTTPProbe
+		wantErr string
+	}{
+		{"headers and status", HTTPProbe{Headers: headers, ExpectStatus: []int{202}}, ""},
+		{"missing header", HTTPProbe{ExpectStatus: []int{202}}, "status 401"},
+		{"unexpected status", HTTPProbe{Headers: headers, ExpectStatus: []int{200}}, "status 202"},
+		{"body substring", HTTPProbe{Headers: headers, ExpectBody: `"status":"ok"`}, ""},
+		{"body mismatch", HTTPProbe{Headers: headers, ExpectBody: "ready"}, "does not contain"},
+		{"json paths", HTTPProbe{Headers: headers, ExpectJSON: map[string]string{
+			"status": "ok", "checks.db.up": "true", "workers.0.busy": "3",
+		}}, ""},
+		{"json value mismatch", HTTPProbe{Headers: headers, ExpectJSON: map[string]string{"status": "down"}}, `status = "ok"`},
+		{"json missing path", HTTPProbe{Headers: headers, ExpectJSON: map[string]string{"checks.cache": "up"}}, "has no"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.probe.URL = srv.URL
+			err := tt.probe.Probe(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected healthy, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPProbeTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	p := &HTTPProbe{URL: srv.URL, Timeout: 20 * time.Millisecond}
+	if err := p.Probe(context.Background()); err == nil {
+		t.Error("expected timeout error")
+	}
+}
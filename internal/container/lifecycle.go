@@ -3,13 +3,24 @@ package container
 import (
 	"context"
 	"time"
+
+	"warren/internal/config"
 )
 
-// Lifecycle abstracts container/service start, stop, restart, and status.
-// Implemented by both Manager (docker containers) and ServiceManager (swarm services).
+// Lifecycle abstracts start, stop, restart, and status of whatever runs an
+// agent: a Swarm service, a Docker container, a native process or a systemd
+// unit.
 type Lifecycle interface {
 	Start(ctx context.Context, name string) error
 	Stop(ctx context.Context, name string, gracePeriod time.Duration) error
 	Restart(ctx context.Context, name string, gracePeriod time.Duration) error
 	Status(ctx context.Context, name string) (string, error)
 }
+
+// Runtime is a Lifecycle that also builds health probes for what it manages,
+// so exec probes run in the right container. Selected by the top-level
+// runtime setting.
+type Runtime interface {
+	Lifecycle
+	Prober(name string, p config.Probe) Prober
+}
@@ -0,0 +1,247 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// LogOptions selects the output a LogSource streams.
+type LogOptions struct {
+	Follow bool      // keep streaming new output until the context is cancelled
+	Since  time.Time // only output from this time on; zero = from the start
+	Tail   int       // only the last Tail lines of existing output; < 0 = all
+}
+
+// LogSource streams the output of what a runtime manages.
+type LogSource interface {
+	// Logs copies name's stdout and stderr to the given writers. It returns
+	// once the existing output has been copied or, when following, once ctx
+	// is cancelled.
+	Logs(ctx context.Context, name string, opts LogOptions, stdout, stderr io.Writer) error
+}
+
+// ErrSinceUnsupported is returned by log sources that can't filter by time.
+var ErrSinceUnsupported = errors.New("since is not supported for this agent's logs")
+
+// dockerLogsOptions translates opts for the Docker logs API.
+func dockerLogsOptions(opts LogOptions) container.LogsOptions {
+	o := container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: opts.Follow, Tail: "all"}
+	if !opts.Since.IsZero() {
+		o.Since = sinceParam(opts.Since)
+	}
+	if opts.Tail >= 0 {
+		o.Tail = strconv.Itoa(opts.Tail)
+	}
+	return o
+}
+
+// copyDockerLogs copies a Docker log stream to stdout and stderr. Output of
+// containers without a TTY is multiplexed and is split by stream; with a TTY
+// it is raw and all goes to stdout.
+func copyDockerLogs(rc io.ReadCloser, tty bool, stdout, stderr io.Writer) error {
+	defer rc.Close()
+	var err error
+	if tty {
+		_, err = io.Copy(stdout, rc)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	}
+	return err
+}
+
+// Logs streams the output of all of a service's tasks.
+func (m *Manager) Logs(ctx context.Context, name string, opts LogOptions, stdout, stderr io.Writer) error {
+	svc, _, err := m.docker.ServiceInspectWithRaw(ctx, name, types.ServiceInspectOptions{})
+	if err != nil {
+		return fmt.Errorf("inspect service %q: %w", name, err)
+	}
+	tty := svc.Spec.TaskTemplate.ContainerSpec != nil && svc.Spec.TaskTemplate.ContainerSpec.TTY
+	rc, err := m.docker.ServiceLogs(ctx, svc.ID, dockerLogsOptions(opts))
+	if err != nil {
+		return fmt.Errorf("service logs %q: %w", name, err)
+	}
+	return copyDockerLogs(rc, tty, stdout, stderr)
+}
+
+// Logs streams a container's output.
+func (m *ContainerManager) Logs(ctx context.Context, name string, opts LogOptions, stdout, stderr io.Writer) error {
+	info, err := m.docker.ContainerInspect(ctx, name)
+	if err != nil {
+		return fmt.Errorf("inspect container %q: %w", name, err)
+	}
+	tty := info.Config != nil && info.Config.Tty
+	rc, err := m.docker.ContainerLogs(ctx, name, dockerLogsOptions(opts))
+	if err != nil {
+		return fmt.Errorf("container logs %q: %w", name, err)
+	}
+	return copyDockerLogs(rc, tty, stdout, stderr)
+}
+
+// logPollInterval is how often a followed process log is checked for new
+// output.
+const logPollInterval = 250 * time.Millisecond
+
+// Logs streams a process's log file. Stdout and stderr share the file, so
+// everything goes to stdout. The file carries no timestamps, so Since is
+// not supported. When following, a rotation is picked up by reopening the
+// new file from its start.
+func (m *ProcessManager) Logs(ctx context.Context, name string, opts LogOptions, stdout, _ io.Writer) error {
+	if !opts.Since.IsZero() {
+		return ErrSinceUnsupported
+	}
+	m.mu.Lock()
+	p, ok := m.procs[name]
+	var path string
+	if ok {
+		path = filepath.Join(p.spec.LogDir, name+".log")
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("process %q is not configured", name)
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && opts.Follow {
+		f, err = waitForFile(ctx, path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil // never started, so no output yet
+	}
+	if err != nil {
+		return fmt.Errorf("open log: %w", err)
+	}
+	defer func() { f.Close() }()
+
+	if opts.Tail >= 0 {
+		err = copyTail(f, opts.Tail, stdout)
+	} else {
+		_, err = io.Copy(stdout, f)
+	}
+	if err != nil || !opts.Follow {
+		return err
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if _, err := io.Copy(stdout, f); err != nil {
+			return err
+		}
+		if rotated(f, path) {
+			next, err := os.Open(path)
+			if err != nil {
+				continue // not recreated yet
+			}
+			// Drain what was written to the old file before it moved.
+			if _, err := io.Copy(stdout, f); err != nil {
+				next.Close()
+				return err
+			}
+			f.Close()
+			f = next
+		}
+	}
+}
+
+// Logs streams a unit's output from the journal. The journal doesn't keep
+// stdout and stderr apart, so everything goes to stdout.
+func (m *SystemdManager) Logs(ctx context.Context, unit string, opts LogOptions, stdout, _ io.Writer) error {
+	args := []string{"--unit", unit, "--output", "cat", "--no-pager"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since", "@"+strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if opts.Tail >= 0 {
+		args = append(args, "--lines", strconv.Itoa(opts.Tail))
+	}
+
+	var errOut bytes.Buffer
+	cmd := exec.CommandContext(ctx, m.journalctl, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = &errOut
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil // stopped following
+	}
+	if err != nil {
+		return fmt.Errorf("journalctl: %w: %s", err, strings.TrimSpace(errOut.String()))
+	}
+	return nil
+}
+
+// waitForFile polls until path exists or ctx is cancelled.
+func waitForFile(ctx context.Context, path string) (*os.File, error) {
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, os.ErrNotExist
+		case <-ticker.C:
+		}
+		f, err := os.Open(path)
+		if !errors.Is(err, os.ErrNotExist) {
+			return f, err
+		}
+	}
+}
+
+// rotated reports whether path no longer names the open file f.
+func rotated(f *os.File, path string) bool {
+	open, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return !os.SameFile(open, current)
+}
+
+// copyTail copies the last n lines of r to w, leaving r at its end.
+func copyTail(r io.Reader, n int, w io.Writer) error {
+	if n == 0 {
+		_, err := io.Copy(io.Discard, r)
+		return err
+	}
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestContainerManagerLogs(t *testing.T) {
+	var muxed bytes.Buffer
+	stdcopy.NewStdWriter(&muxed, stdcopy.Stdout).Write([]byte("out\n"))
+	stdcopy.NewStdWriter(&muxed, stdcopy.Stderr).Write([]byte("err\n"))
+	docker := &fakeDockerClient{logs: muxed.Bytes()}
+	m := testContainerManager(docker)
+
+	since := time.Unix(1700000000, 0)
+	var stdout, stderr bytes.Buffer
+	if err := m.Logs(context.Background(), "web", LogOptions{Since: since, Tail: 10}, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+	if o := docker.logOpts; o.Tail != "10" || o.Since != "1700000000.000000000" || o.Follow {
+		t.Errorf("logs options = %+v", o)
+	}
+
+	// TTY output isn't multiplexed.
+	docker = &fakeDockerClient{tty: true, logs: []byte("raw\n")}
+	stdout.Reset()
+	if err := testContainerManager(docker).Logs(context.Background(), "web", LogOptions{Tail: -1}, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "raw\n" || docker.logOpts.Tail != "all" {
+		t.Errorf("stdout = %q, tail = %q", stdout.String(), docker.logOpts.Tail)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to read while Logs writes to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestProcessManagerLogs(t *testing.T) {
+	logDir := t.TempDir()
+	m := testProcessManager()
+	m.Register("agent", ProcessSpec{Command: []string{"true"}, LogDir: logDir})
+	path := filepath.Join(logDir, "agent.log")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := m.Logs(context.Background(), "agent", LogOptions{Tail: 2}, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "two\nthree\n" {
+		t.Errorf("tail 2 = %q", out.String())
+	}
+	if err := m.Logs(context.Background(), "agent", LogOptions{Since: time.Now()}, &out, nil); err != ErrSinceUnsupported {
+		t.Errorf("since err = %v, want ErrSinceUnsupported", err)
+	}
+
+	// Following picks up appends and the file that replaces a rotated one.
+	ctx, cancel := context.WithCancel(context.Background())
+	followed := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() { done <- m.Logs(ctx, "agent", LogOptions{Follow: true, Tail: 0}, followed, nil) }()
+
+	logs, err := OpenRotatingFile(path, 20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	time.Sleep(2 * logPollInterval)
+	logs.Write([]byte("four\n"))      // appended: 19 bytes
+	logs.Write([]byte("five\nsix\n")) // rotates first
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(followed.String(), "six") && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := followed.String(); got != "four\nfive\nsix\n" {
+		t.Errorf("followed = %q", got)
+	}
+}
+
+func TestSystemdManagerLogs(t *testing.T) {
+	// A stand-in journalctl that prints its arguments.
+	script := filepath.Join(t.TempDir(), "journalctl")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewSystemdManager(&fakeSystemdBus{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.journalctl = script
+
+	var out bytes.Buffer
+	opts := LogOptions{Follow: true, Since: time.Unix(1700000000, 0), Tail: 5}
+	if err := m.Logs(context.Background(), "agent.service", opts, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "--unit agent.service --output cat --no-pager --follow --since @1700000000 --lines 5\n"
+	if out.String() != want {
+		t.Errorf("journalctl args = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,264 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"warren/internal/config"
+)
+
+// Prober runs a single health check against an agent.
+type Prober interface {
+	Probe(ctx context.Context) error
+}
+
+// ExecClient is the part of the Docker API used by exec probes.
+type ExecClient interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
+	ContainerExecCreate(ctx context.Context, container string, options container.ExecOptions) (types.IDResponse, error)
+	ContainerExecAttach(ctx context.Context, execID string, config container.ExecAttachOptions) (types.HijackedResponse, error)
+	ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error)
+}
+
+// NewProber builds the probe described by p. service is the Swarm service an
+// exec probe runs in; docker may be nil if no exec probes are configured.
+func NewProber(p config.Probe, service string, docker ExecClient) Prober {
+	switch p.Type {
+	case "tcp":
+		addr := p.Address
+		if addr == "" {
+			addr = hostPort(p.URL)
+		}
+		return &TCPProbe{Address: addr, Timeout: p.Timeout}
+	case "websocket":
+		return &WebSocketProbe{URL: p.URL, Headers: p.Headers, Timeout: p.Timeout}
+	case "exec":
+		return &ExecProbe{Docker: docker, Service: service, Command: p.Command, Timeout: p.Timeout}
+	default:
+		return &HTTPProbe{
+			URL:          p.URL,
+			Headers:      p.Headers,
+			ExpectStatus: p.ExpectStatus,
+			ExpectBody:   p.ExpectBody,
+			ExpectJSON:   p.ExpectJSON,
+			Timeout:      p.Timeout,
+		}
+	}
+}
+
+// Prober returns the health probe for service, running exec probes through
+// the manager's Docker client.
+func (m *Manager) Prober(service string, p config.Probe) Prober {
+	var docker ExecClient
+	if m.docker != nil {
+		docker = m.docker
+	}
+	return NewProber(p, service, docker)
+}
+
+// TCPProbe is healthy if a TCP connection to Address succeeds.
+type TCPProbe struct {
+	Address string
+	Timeout time.Duration // default 3s
+}
+
+func (p *TCPProbe) Probe(ctx context.Context) error {
+	d := net.Dialer{Timeout: orDefault(p.Timeout, 3*time.Second)}
+	conn, err := d.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return fmt.Errorf("tcp health check failed: %w", err)
+	}
+	return conn.Close()
+}
+
+// WebSocketProbe is healthy if the server completes a WebSocket handshake.
+// The connection is closed straight after the upgrade.
+type WebSocketProbe struct {
+	URL     string // ws://, wss://, http:// or https://
+	Headers map[string]string
+	Timeout time.Duration // default 5s
+}
+
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+func (p *WebSocketProbe) Probe(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, orDefault(p.Timeout, 5*time.Second))
+	defer cancel()
+
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("parse websocket health url: %w", err)
+	}
+	secure := u.Scheme == "wss" || u.Scheme == "https"
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", hostPort(p.URL))
+	if err != nil {
+		return fmt.Errorf("websocket health check failed: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if secure {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("websocket health check tls: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("create websocket health request: %w", err)
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("websocket health check failed: %w", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return fmt.Errorf("websocket health check failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("websocket health check returned status %d", resp.StatusCode)
+	}
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return fmt.Errorf("websocket health check: bad Sec-WebSocket-Accept")
+	}
+	return nil
+}
+
+// ExecProbe runs Command inside a running task of Service on this node, or
+// in Container if set, and is healthy if it exits 0.
+type ExecProbe struct {
+	Docker    ExecClient
+	Service   string
+	Container string // plain container to exec in, instead of a Swarm task
+	Command   []string
+	Timeout   time.Duration // default 10s
+}
+
+func (p *ExecProbe) Probe(ctx context.Context) error {
+	if p.Docker == nil {
+		return fmt.Errorf("exec health check: no docker client")
+	}
+	ctx, cancel := context.WithTimeout(ctx, orDefault(p.Timeout, 10*time.Second))
+	defer cancel()
+
+	id, err := p.taskContainer(ctx)
+	if err != nil {
+		return err
+	}
+	exec, err := p.Docker.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          p.Command,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("exec health check create: %w", err)
+	}
+	resp, err := p.Docker.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("exec health check attach: %w", err)
+	}
+	defer resp.Close()
+	// The attached stream doesn't watch ctx; closing it unblocks the read.
+	stop := context.AfterFunc(ctx, resp.Close)
+	defer stop()
+
+	var out bytes.Buffer
+	w := &limitWriter{buf: &out, n: 512}
+	_, err = stdcopy.StdCopy(w, w, resp.Reader)
+	if ctx.Err() != nil {
+		return fmt.Errorf("exec health check: %w", ctx.Err())
+	}
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("exec health check output: %w", err)
+	}
+
+	inspect, err := p.Docker.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return fmt.Errorf("exec health check inspect: %w", err)
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("exec health check exited %d: %s", inspect.ExitCode, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// taskContainer finds a running container belonging to the service.
+func (p *ExecProbe) taskContainer(ctx context.Context) (string, error) {
+	if p.Container != "" {
+		return p.Container, nil
+	}
+	id, err := runningTask(ctx, p.Docker, p.Service)
+	if err != nil {
+		return "", fmt.Errorf("exec health check: %w", err)
+	}
+	return id, nil
+}
+
+// limitWriter keeps the first n bytes written and discards the rest.
+type limitWriter struct {
+	buf *bytes.Buffer
+	n   int
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if room := w.n - w.buf.Len(); room > 0 {
+		w.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// hostPort returns host:port for a URL, filling in the scheme's default port.
+func hostPort(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,166 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"warren/internal/config"
+)
+
+func TestNewProberTypes(t *testing.T) {
+	tests := []struct {
+		health config.Probe
+		want   string
+	}{
+		{config.Probe{URL: "http://a:8080/health"}, "*container.HTTPProbe"},
+		{config.Probe{Type: "tcp", URL: "http://a/health"}, "*container.TCPProbe"},
+		{config.Probe{Type: "websocket", URL: "ws://a/ws"}, "*container.WebSocketProbe"},
+		{config.Probe{Type: "exec", Command: []string{"true"}}, "*container.ExecProbe"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf("%T", NewProber(tt.health, "svc", nil)); got != tt.want {
+			t.Errorf("NewProber(%q) = %s, want %s", tt.health.Type, got, tt.want)
+		}
+	}
+
+	// A TCP probe without an address dials the URL's host and default port.
+	p := NewProber(config.Probe{Type: "tcp", URL: "https://a.example.com/health"}, "svc", nil).(*TCPProbe)
+	if p.Address != "a.example.com:443" {
+		t.Errorf("tcp address = %q, want a.example.com:443", p.Address)
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	if err := (&TCPProbe{Address: addr}).Probe(context.Background()); err != nil {
+		t.Errorf("expected healthy, got %v", err)
+	}
+	ln.Close()
+	if err := (&TCPProbe{Address: addr}).Probe(context.Background()); err == nil {
+		t.Error("expected error after listener closed")
+	}
+}
+
+func TestWebSocketProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("X-Probe") != "1" {
+			w.WriteHeader(400)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsAcceptGUID))
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		buf.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		buf.Flush()
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ok := &WebSocketProbe{URL: url, Headers: map[string]string{"X-Probe": "1"}}
+	if err := ok.Probe(context.Background()); err != nil {
+		t.Errorf("expected healthy, got %v", err)
+	}
+
+	rejected := &WebSocketProbe{URL: url}
+	if err := rejected.Probe(context.Background()); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("error = %v, want status 400", err)
+	}
+}
+
+type fakeExecClient struct {
+	containers []types.Container
+	exitCode   int
+	output     string
+	execTarget string
+	execCmd    []string
+	hang       bool // attach to a stream that never produces output
+}
+
+func (f *fakeExecClient) ContainerList(_ context.Context, _ container.ListOptions) ([]types.Container, error) {
+	return f.containers, nil
+}
+
+func (f *fakeExecClient) ContainerExecCreate(_ context.Context, id string, opts container.ExecOptions) (types.IDResponse, error) {
+	f.execTarget = id
+	f.execCmd = opts.Cmd
+	return types.IDResponse{ID: "exec1"}, nil
+}
+
+func (f *fakeExecClient) ContainerExecAttach(_ context.Context, _ string, _ container.ExecAttachOptions) (types.HijackedResponse, error) {
+	var framed bytes.Buffer
+	stdcopy.NewStdWriter(&framed, stdcopy.Stdout).Write([]byte(f.output))
+	client, server := net.Pipe()
+	if f.hang {
+		return types.HijackedResponse{Conn: client, Reader: bufio.NewReader(client)}, nil
+	}
+	server.Close()
+	return types.HijackedResponse{Conn: client, Reader: bufio.NewReader(&framed)}, nil
+}
+
+func (f *fakeExecClient) ContainerExecInspect(_ context.Context, _ string) (container.ExecInspect, error) {
+	return container.ExecInspect{ExitCode: f.exitCode}, nil
+}
+
+func TestExecProbe(t *testing.T) {
+	docker := &fakeExecClient{containers: []types.Container{{ID: "abc123"}}}
+	p := &ExecProbe{Docker: docker, Service: "svc", Command: []string{"pg_isready"}}
+
+	if err := p.Probe(context.Background()); err != nil {
+		t.Fatalf("expected healthy, got %v", err)
+	}
+	if docker.execTarget != "abc123" || docker.execCmd[0] != "pg_isready" {
+		t.Errorf("exec ran %v in %q", docker.execCmd, docker.execTarget)
+	}
+
+	docker.exitCode = 2
+	docker.output = "no response\n"
+	err := p.Probe(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "exited 2: no response") {
+		t.Errorf("error = %v, want exit code and output", err)
+	}
+
+	docker.containers = nil
+	if err := p.Probe(context.Background()); err == nil || !strings.Contains(err.Error(), "no running container") {
+		t.Errorf("error = %v, want no running container", err)
+	}
+}
+
+func TestExecProbeTimeout(t *testing.T) {
+	docker := &fakeExecClient{containers: []types.Container{{ID: "abc123"}}, hang: true}
+	p := &ExecProbe{Docker: docker, Service: "svc", Command: []string{"sleep", "inf"}, Timeout: 50 * time.Millisecond}
+
+	done := make(chan error, 1)
+	go func() { done <- p.Probe(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want deadline exceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("probe did not return after its timeout")
+	}
+}
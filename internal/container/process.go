@@ -0,0 +1,250 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"warren/internal/config"
+)
+
+// ProcessSpec describes a native process run in place of a container.
+type ProcessSpec struct {
+	Command     []string
+	Dir         string
+	Env         []string // KEY=VALUE, added to the orchestrator's environment
+	User        string   // run as this user; empty = the orchestrator's user
+	LogDir      string   // stdout and stderr go to LogDir/<name>.log
+	LogMaxSize  int64    // bytes before the log is rotated
+	LogMaxFiles int      // rotated logs kept
+}
+
+// ProcessManager runs agents as native processes. Each process is started in
+// its own process group, so stopping it also stops anything it spawned, and
+// is reaped when it exits so Status reports it as exited. Restarting a
+// process that died is left to the policy, as with containers.
+type ProcessManager struct {
+	mu     sync.Mutex
+	procs  map[string]*managedProcess
+	logger *slog.Logger
+}
+
+type managedProcess struct {
+	spec ProcessSpec
+	cmd  *exec.Cmd     // nil when not running
+	done chan struct{} // closed when cmd exits
+}
+
+// NewProcessManager creates an empty process manager.
+func NewProcessManager(logger *slog.Logger) *ProcessManager {
+	return &ProcessManager{procs: make(map[string]*managedProcess), logger: logger}
+}
+
+// Register sets the spec for name. A running process keeps its old spec until
+// it is next started.
+func (m *ProcessManager) Register(name string, spec ProcessSpec) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p, ok := m.procs[name]; ok {
+		p.spec = spec
+		return
+	}
+	m.procs[name] = &managedProcess{spec: spec}
+}
+
+// Unregister stops name's process if it is running and forgets it.
+func (m *ProcessManager) Unregister(ctx context.Context, name string, gracePeriod time.Duration) error {
+	err := m.Stop(ctx, name, gracePeriod)
+	m.mu.Lock()
+	delete(m.procs, name)
+	m.mu.Unlock()
+	return err
+}
+
+// Start launches name's process. Starting a process that is already running
+// does nothing.
+func (m *ProcessManager) Start(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.procs[name]
+	if !ok {
+		return fmt.Errorf("process %q is not configured", name)
+	}
+	if p.cmd != nil {
+		return nil
+	}
+	spec := p.spec
+	if len(spec.Command) == 0 {
+		return fmt.Errorf("process %q has no command", name)
+	}
+
+	cmd := exec.Command(spec.Command[0], spec.Command[1:]...)
+	cmd.Dir = spec.Dir
+	cmd.Env = append(os.Environ(), spec.Env...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if spec.User != "" {
+		cred, err := credential(spec.User)
+		if err != nil {
+			return fmt.Errorf("process %q: %w", name, err)
+		}
+		cmd.SysProcAttr.Credential = cred
+	}
+
+	logs, err := OpenRotatingFile(filepath.Join(spec.LogDir, name+".log"), spec.LogMaxSize, spec.LogMaxFiles)
+	if err != nil {
+		return fmt.Errorf("process %q: %w", name, err)
+	}
+	cmd.Stdout = logs
+	cmd.Stderr = logs
+
+	m.logger.Info("starting process", "process", name, "command", spec.Command)
+	if err := cmd.Start(); err != nil {
+		logs.Close()
+		return fmt.Errorf("start process %q: %w", name, err)
+	}
+
+	done := make(chan struct{})
+	p.cmd, p.done = cmd, done
+	go func() {
+		err := cmd.Wait()
+		logs.Close()
+		m.mu.Lock()
+		p.cmd = nil
+		m.mu.Unlock()
+		close(done)
+		m.logger.Info("process exited", "process", name, "pid", cmd.Process.Pid, "status", exitStatus(err))
+	}()
+	return nil
+}
+
+// Stop sends SIGTERM to name's process group and SIGKILL once gracePeriod
+// has passed. It returns once the process has exited.
+func (m *ProcessManager) Stop(ctx context.Context, name string, gracePeriod time.Duration) error {
+	m.mu.Lock()
+	p, ok := m.procs[name]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("process %q is not configured", name)
+	}
+	cmd, done := p.cmd, p.done
+	m.mu.Unlock()
+	if cmd == nil {
+		return nil
+	}
+
+	pid := cmd.Process.Pid
+	m.logger.Info("stopping process", "process", name, "pid", pid, "grace_period", gracePeriod)
+	if err := syscall.Kill(-pid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("signal process %q: %w", name, err)
+	}
+
+	grace := time.NewTimer(gracePeriod)
+	defer grace.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-grace.C:
+		m.logger.Warn("process ignored SIGTERM, killing", "process", name, "pid", pid)
+	case <-ctx.Done():
+	}
+	if err := syscall.Kill(-pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("kill process %q: %w", name, err)
+	}
+	<-done
+	return ctx.Err()
+}
+
+// Restart stops name's process and starts it again.
+func (m *ProcessManager) Restart(ctx context.Context, name string, gracePeriod time.Duration) error {
+	m.logger.Info("restarting process", "process", name)
+	if err := m.Stop(ctx, name, gracePeriod); err != nil {
+		return err
+	}
+	return m.Start(ctx, name)
+}
+
+// Status reports "running" or "exited".
+func (m *ProcessManager) Status(_ context.Context, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.procs[name]
+	if !ok {
+		return "", fmt.Errorf("process %q is not configured", name)
+	}
+	if p.cmd != nil {
+		return "running", nil
+	}
+	return "exited", nil
+}
+
+// Prober returns the health probe for a process. Exec probes have no
+// container to run in and always fail.
+func (m *ProcessManager) Prober(_ string, p config.Probe) Prober {
+	return NewProber(p, "", nil)
+}
+
+// StopAll stops every running process, in parallel. Processes can't be
+// picked up again by a later orchestrator run, so they are stopped on
+// shutdown rather than left behind.
+func (m *ProcessManager) StopAll(ctx context.Context, gracePeriod time.Duration) {
+	m.mu.Lock()
+	var names []string
+	for name, p := range m.procs {
+		if p.cmd != nil {
+			names = append(names, name)
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Stop(ctx, name, gracePeriod); err != nil {
+				m.logger.Error("failed to stop process", "process", name, "error", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// credential looks up the uid and gid to run a process as.
+func credential(name string) (*syscall.Credential, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("look up user: %w", err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s uid %q: %w", name, u.Uid, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s gid %q: %w", name, u.Gid, err)
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}, nil
+}
+
+// exitStatus describes how a process exited for logging.
+func exitStatus(err error) string {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return "exit 0"
+	case errors.As(err, &exitErr):
+		return exitErr.ProcessState.String()
+	default:
+		return err.Error()
+	}
+}
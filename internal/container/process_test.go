@@ -0,0 +1,142 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProcessManager() *ProcessManager {
+	return NewProcessManager(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// waitForLog waits until the process log contains want.
+func waitForLog(t *testing.T, path, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := os.ReadFile(path); strings.Contains(string(data), want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	data, _ := os.ReadFile(path)
+	t.Fatalf("log %s = %q, want it to contain %q", path, data, want)
+}
+
+func TestProcessManagerStartStop(t *testing.T) {
+	logDir, workDir := t.TempDir(), t.TempDir()
+	m := testProcessManager()
+	m.Register("agent", ProcessSpec{
+		Command: []string{"sh", "-c", `echo "greeting=$GREETING dir=$(pwd)"; echo oops >&2; exec sleep 60`},
+		Dir:     workDir,
+		Env:     []string{"GREETING=hello"},
+		LogDir:  logDir,
+	})
+	ctx := context.Background()
+
+	if s, _ := m.Status(ctx, "agent"); s != "exited" {
+		t.Errorf("status before start = %q, want exited", s)
+	}
+	if err := m.Start(ctx, "agent"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(ctx, "agent"); err != nil {
+		t.Errorf("second start: %v", err)
+	}
+	if s, _ := m.Status(ctx, "agent"); s != "running" {
+		t.Errorf("status = %q, want running", s)
+	}
+	log := filepath.Join(logDir, "agent.log")
+	waitForLog(t, log, "greeting=hello dir="+workDir)
+	waitForLog(t, log, "oops")
+
+	if err := m.Stop(ctx, "agent", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := m.Status(ctx, "agent"); s != "exited" {
+		t.Errorf("status after stop = %q, want exited", s)
+	}
+}
+
+func TestProcessManagerKillsAfterGracePeriod(t *testing.T) {
+	logDir := t.TempDir()
+	m := testProcessManager()
+	// The shell and its children ignore SIGTERM.
+	m.Register("stubborn", ProcessSpec{
+		Command: []string{"sh", "-c", `trap "" TERM; echo up; while true; do sleep 0.05; done`},
+		LogDir:  logDir,
+	})
+	ctx := context.Background()
+	if err := m.Start(ctx, "stubborn"); err != nil {
+		t.Fatal(err)
+	}
+	waitForLog(t, filepath.Join(logDir, "stubborn.log"), "up")
+
+	start := time.Now()
+	if err := m.Stop(ctx, "stubborn", 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("stopped after %v, before the grace period", elapsed)
+	}
+	if s, _ := m.Status(ctx, "stubborn"); s != "exited" {
+		t.Errorf("status = %q, want exited", s)
+	}
+}
+
+func TestProcessManagerReportsExit(t *testing.T) {
+	m := testProcessManager()
+	m.Register("short", ProcessSpec{Command: []string{"sh", "-c", "exit 3"}, LogDir: t.TempDir()})
+	ctx := context.Background()
+	if err := m.Start(ctx, "short"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if s, _ := m.Status(ctx, "short"); s == "exited" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("process never reported exited")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := m.Status(ctx, "unknown"); err == nil {
+		t.Error("expected error for unknown process")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 4 {
+		fmt.Fprintf(f, "line %d\n", i) // 7 bytes: one line per file
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path:        "line 3\n",
+		path + ".1": "line 2\n",
+		path + ".2": "line 1\n",
+	}
+	for p, content := range want {
+		if data, err := os.ReadFile(p); err != nil || string(data) != content {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(p), data, err, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 rotated files to be kept")
+	}
+}
@@ -17,7 +17,8 @@ import (
 	"warren/internal/hermes"
 )
 
-// Manager manages Docker swarm services via scale 0/1.
+// Manager manages Docker swarm services by scaling them between 0 and the
+// agent's configured replica count.
 type Manager struct {
 	docker        *client.Client
 	logger        *slog.Logger
@@ -53,8 +54,9 @@ func NewManagerWithConfig(docker *client.Client, logger *slog.Logger, cfg *confi
 }
 
 func (m *Manager) Start(ctx context.Context, name string) error {
-	m.logger.Info("scaling service to 1", "service", name)
-	return m.scale(ctx, name, 1)
+	replicas := m.replicasFor(name)
+	m.logger.Info("scaling service up", "service", name, "replicas", replicas)
+	return m.scale(ctx, name, replicas)
 }
 
 func (m *Manager) Stop(ctx context.Context, name string, _ time.Duration) error {
@@ -69,7 +71,33 @@ func (m *Manager) Restart(ctx context.Context, name string, _ time.Duration) err
 	}
 	// Brief pause to let the container fully stop.
 	time.Sleep(2 * time.Second)
-	return m.scale(ctx, name, 1)
+	return m.scale(ctx, name, m.replicasFor(name))
+}
+
+// Scale sets the replica count of a running service.
+func (m *Manager) Scale(ctx context.Context, name string, replicas uint64) error {
+	m.logger.Info("scaling service", "service", name, "replicas", replicas)
+	return m.scale(ctx, name, replicas)
+}
+
+// Replicas returns the desired replica count of a service.
+func (m *Manager) Replicas(ctx context.Context, name string) (uint64, error) {
+	svc, _, err := m.docker.ServiceInspectWithRaw(ctx, name, types.ServiceInspectOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("inspect service %q: %w", name, err)
+	}
+	if svc.Spec.Mode.Replicated == nil || svc.Spec.Mode.Replicated.Replicas == nil {
+		return 0, nil
+	}
+	return *svc.Spec.Mode.Replicated.Replicas, nil
+}
+
+// replicasFor returns how many replicas a service should run while awake.
+func (m *Manager) replicasFor(name string) uint64 {
+	if agent, _ := m.findAgentForService(name); agent != nil && agent.Replicas > 1 {
+		return uint64(agent.Replicas)
+	}
+	return 1
 }
 
 func (m *Manager) Status(ctx context.Context, name string) (string, error) {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	"warren/internal/config"
 )
 
 // mockLifecycle implements Lifecycle for testing.
@@ -66,3 +68,20 @@ func TestLifecycleStatus(t *testing.T) {
 		t.Errorf("status = %q, want running", s)
 	}
 }
+
+func TestManagerReplicasFor(t *testing.T) {
+	m := &Manager{cfg: &config.Config{Agents: map[string]*config.Agent{
+		"web": {Container: config.Container{Name: "stack_web"}, Replicas: 3},
+		"one": {Container: config.Container{Name: "stack_one"}},
+	}}}
+
+	tests := map[string]uint64{"stack_web": 3, "stack_one": 1, "unknown": 1}
+	for svc, want := range tests {
+		if got := m.replicasFor(svc); got != want {
+			t.Errorf("replicasFor(%q) = %d, want %d", svc, got, want)
+		}
+	}
+	if got := (&Manager{}).replicasFor("stack_web"); got != 1 {
+		t.Errorf("replicasFor without config = %d, want 1", got)
+	}
+}
@@ -0,0 +1,216 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/system"
+)
+
+// swarmServiceLabel is set by Swarm on every task container.
+const swarmServiceLabel = "com.docker.swarm.service.name"
+
+// StatsClient is the part of the Docker API used to sample resource usage.
+type StatsClient interface {
+	Info(ctx context.Context) (system.Info, error)
+	ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error)
+	ContainerStatsOneShot(ctx context.Context, containerID string) (container.StatsResponseReader, error)
+}
+
+// ServiceStats is the resource usage of a Swarm service's running tasks on
+// this host.
+type ServiceStats struct {
+	CPUPercent  float64 `json:"cpu_percent"` // 100 = one full core, summed across tasks
+	MemoryBytes uint64  `json:"memory_bytes"`
+	Tasks       int     `json:"tasks"`
+}
+
+// HostStats is one sample of resource usage on the Docker host.
+type HostStats struct {
+	SampledAt       time.Time
+	MemoryTotal     uint64                  // host memory
+	MemoryUsed      uint64                  // host memory in use by anything, containers or not
+	ContainerMemory uint64                  // memory used by all running containers
+	Services        map[string]ServiceStats // by Swarm service name, or container name outside Swarm
+}
+
+// MemoryFraction returns MemoryUsed as a fraction of MemoryTotal, or 0 if the
+// total is unknown.
+func (h HostStats) MemoryFraction() float64 {
+	if h.MemoryTotal == 0 {
+		return 0
+	}
+	return float64(h.MemoryUsed) / float64(h.MemoryTotal)
+}
+
+// StatsSampler samples every running container through the Docker stats API,
+// and host memory from /proc/meminfo. CPU usage is measured between
+// consecutive samples, so the first sample of a container reports 0%.
+type StatsSampler struct {
+	docker  StatsClient
+	meminfo string
+
+	mu   sync.Mutex
+	prev map[string]container.CPUStats // container ID → CPU counters at the last sample
+}
+
+// NewStatsSampler creates a sampler backed by docker.
+func NewStatsSampler(docker StatsClient) *StatsSampler {
+	return &StatsSampler{docker: docker, meminfo: "/proc/meminfo", prev: make(map[string]container.CPUStats)}
+}
+
+// Sample takes one-shot stats of every running container and totals them per
+// Swarm service, and reads host memory use. Containers that aren't Swarm
+// tasks are reported under their own name. Where /proc/meminfo can't be read,
+// host memory falls back to docker info's total and the containers' usage.
+func (s *StatsSampler) Sample(ctx context.Context) (HostStats, error) {
+	info, err := s.docker.Info(ctx)
+	if err != nil {
+		return HostStats{}, fmt.Errorf("docker info: %w", err)
+	}
+	f := filters.NewArgs()
+	f.Add("status", "running")
+	containers, err := s.docker.ContainerList(ctx, container.ListOptions{Filters: f})
+	if err != nil {
+		return HostStats{}, fmt.Errorf("list containers: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := HostStats{
+		SampledAt:   time.Now(),
+		MemoryTotal: uint64(max(info.MemTotal, 0)),
+		Services:    make(map[string]ServiceStats),
+	}
+	seen := make(map[string]bool, len(containers))
+	for _, c := range containers {
+		st, err := s.containerStats(ctx, c.ID)
+		if err != nil {
+			// The container may have stopped since it was listed.
+			continue
+		}
+		seen[c.ID] = true
+		mem := memoryUsage(st.MemoryStats)
+		cpu := cpuPercent(s.prev[c.ID], st.CPUStats)
+		s.prev[c.ID] = st.CPUStats
+
+		stats.ContainerMemory += mem
+		if service := serviceName(c); service != "" {
+			svc := stats.Services[service]
+			svc.CPUPercent += cpu
+			svc.MemoryBytes += mem
+			svc.Tasks++
+			stats.Services[service] = svc
+		}
+	}
+	for id := range s.prev {
+		if !seen[id] {
+			delete(s.prev, id)
+		}
+	}
+
+	stats.MemoryUsed = stats.ContainerMemory
+	if total, available, err := readMeminfo(s.meminfo); err == nil && available <= total {
+		stats.MemoryTotal = total
+		stats.MemoryUsed = total - available
+	}
+	return stats, nil
+}
+
+// readMeminfo returns MemTotal and MemAvailable from a /proc/meminfo file, in
+// bytes. MemAvailable already leaves out page cache the kernel can reclaim.
+func readMeminfo(path string) (total, available uint64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	var haveTotal, haveAvailable bool
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if !ok || (key != "MemTotal" && key != "MemAvailable") {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+		if key == "MemTotal" {
+			total, haveTotal = kb*1024, true
+		} else {
+			available, haveAvailable = kb*1024, true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, 0, err
+	}
+	if !haveTotal || !haveAvailable {
+		return 0, 0, fmt.Errorf("%s: no MemTotal or MemAvailable", path)
+	}
+	return total, available, nil
+}
+
+// serviceName returns the Swarm service c belongs to, or its container name
+// if it isn't a Swarm task.
+func serviceName(c types.Container) string {
+	if service := c.Labels[swarmServiceLabel]; service != "" {
+		return service
+	}
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return ""
+}
+
+func (s *StatsSampler) containerStats(ctx context.Context, id string) (container.StatsResponse, error) {
+	resp, err := s.docker.ContainerStatsOneShot(ctx, id)
+	if err != nil {
+		return container.StatsResponse{}, err
+	}
+	defer resp.Body.Close()
+	var st container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return container.StatsResponse{}, err
+	}
+	return st, nil
+}
+
+// cpuPercent computes CPU use between two samples the way `docker stats`
+// does: the container's share of host CPU time, scaled by the CPU count.
+func cpuPercent(prev, cur container.CPUStats) float64 {
+	if prev.SystemUsage == 0 || cur.SystemUsage <= prev.SystemUsage || cur.CPUUsage.TotalUsage < prev.CPUUsage.TotalUsage {
+		return 0
+	}
+	cpus := float64(cur.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(cur.CPUUsage.PercpuUsage))
+	}
+	delta := float64(cur.CPUUsage.TotalUsage - prev.CPUUsage.TotalUsage)
+	system := float64(cur.SystemUsage - prev.SystemUsage)
+	return delta / system * cpus * 100
+}
+
+// memoryUsage returns memory in use excluding reclaimable page cache, as
+// `docker stats` reports it.
+func memoryUsage(m container.MemoryStats) uint64 {
+	// cgroup v2 reports inactive_file, v1 total_inactive_file.
+	for _, key := range []string{"inactive_file", "total_inactive_file"} {
+		if v, ok := m.Stats[key]; ok && v < m.Usage {
+			return m.Usage - v
+		}
+	}
+	return m.Usage
+}
@@ -0,0 +1,118 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/system"
+)
+
+type fakeStatsClient struct {
+	memTotal   int64
+	containers []types.Container
+	stats      map[string]container.StatsResponse
+}
+
+func (f *fakeStatsClient) Info(context.Context) (system.Info, error) {
+	return system.Info{MemTotal: f.memTotal}, nil
+}
+
+func (f *fakeStatsClient) ContainerList(context.Context, container.ListOptions) ([]types.Container, error) {
+	return f.containers, nil
+}
+
+func (f *fakeStatsClient) ContainerStatsOneShot(_ context.Context, id string) (container.StatsResponseReader, error) {
+	data, _ := json.Marshal(f.stats[id])
+	return container.StatsResponseReader{Body: io.NopCloser(strings.NewReader(string(data)))}, nil
+}
+
+func statsAt(cpuTotal, system, mem uint64) container.StatsResponse {
+	var st container.StatsResponse
+	st.CPUStats = container.CPUStats{CPUUsage: container.CPUUsage{TotalUsage: cpuTotal}, SystemUsage: system, OnlineCPUs: 2}
+	st.MemoryStats = container.MemoryStats{Usage: mem, Stats: map[string]uint64{"inactive_file": 100}}
+	return st
+}
+
+func TestStatsSamplerTotalsPerService(t *testing.T) {
+	docker := &fakeStatsClient{
+		memTotal: 10000,
+		containers: []types.Container{
+			{ID: "a1", Labels: map[string]string{swarmServiceLabel: "svc-a"}},
+			{ID: "a2", Labels: map[string]string{swarmServiceLabel: "svc-a"}},
+			{ID: "other", Names: []string{"/plain"}},
+		},
+		stats: map[string]container.StatsResponse{
+			"a1":    statsAt(1000, 10000, 1100),
+			"a2":    statsAt(1000, 10000, 600),
+			"other": statsAt(0, 10000, 2100),
+		},
+	}
+	s := NewStatsSampler(docker)
+	s.meminfo = filepath.Join(t.TempDir(), "missing")
+
+	host, err := s.Sample(context.Background())
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	// Page cache (inactive_file) is not counted as used. Without
+	// /proc/meminfo the host falls back to the containers' total.
+	if host.MemoryTotal != 10000 || host.ContainerMemory != 1000+500+2000 || host.MemoryUsed != host.ContainerMemory {
+		t.Errorf("host memory = %d (containers %d)/%d, want 3500/10000", host.MemoryUsed, host.ContainerMemory, host.MemoryTotal)
+	}
+	if got := host.Services["svc-a"]; got.MemoryBytes != 1500 || got.Tasks != 2 || got.CPUPercent != 0 {
+		t.Errorf("svc-a = %+v, want 1500 bytes, 2 tasks, no CPU on first sample", got)
+	}
+	// Containers outside Swarm are reported under their own name.
+	if got := host.Services["plain"]; got.MemoryBytes != 2000 || got.Tasks != 1 || len(host.Services) != 2 {
+		t.Errorf("services = %v, want svc-a and plain", host.Services)
+	}
+
+	// a1 used 500 of 10000 ns of system time across 2 CPUs: 10%.
+	docker.stats["a1"] = statsAt(1500, 20000, 1100)
+	docker.stats["a2"] = statsAt(1000, 20000, 600)
+	host, err = s.Sample(context.Background())
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	if got := host.Services["svc-a"].CPUPercent; math.Abs(got-10) > 0.001 {
+		t.Errorf("svc-a CPU = %v%%, want 10%%", got)
+	}
+	if got := host.MemoryFraction(); math.Abs(got-0.35) > 0.001 {
+		t.Errorf("MemoryFraction = %v, want 0.35", got)
+	}
+}
+
+func TestStatsSamplerReadsHostMemory(t *testing.T) {
+	meminfo := filepath.Join(t.TempDir(), "meminfo")
+	data := "MemTotal:       16000 kB\nMemFree:         1000 kB\nMemAvailable:    4000 kB\nBuffers:          100 kB\n"
+	if err := os.WriteFile(meminfo, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	docker := &fakeStatsClient{
+		memTotal:   1,
+		containers: []types.Container{{ID: "a1", Labels: map[string]string{swarmServiceLabel: "svc-a"}}},
+		stats:      map[string]container.StatsResponse{"a1": statsAt(0, 10000, 1100)},
+	}
+	s := NewStatsSampler(docker)
+	s.meminfo = meminfo
+
+	host, err := s.Sample(context.Background())
+	if err != nil {
+		t.Fatalf("Sample: %v", err)
+	}
+	// Memory used outside containers counts toward the host.
+	if host.MemoryTotal != 16000*1024 || host.MemoryUsed != 12000*1024 {
+		t.Errorf("host memory = %d/%d, want %d/%d", host.MemoryUsed, host.MemoryTotal, 12000*1024, 16000*1024)
+	}
+	if host.ContainerMemory != 1000 || host.Services["svc-a"].MemoryBytes != 1000 {
+		t.Errorf("container memory = %d, svc-a = %+v, want 1000", host.ContainerMemory, host.Services["svc-a"])
+	}
+}
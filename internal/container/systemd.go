@@ -0,0 +1,221 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"syscall"
+	"time"
+
+	sdbus "github.com/coreos/go-systemd/v22/dbus"
+
+	"warren/internal/config"
+)
+
+// SystemdBus is the part of the systemd D-Bus API (org.freedesktop.systemd1)
+// used to manage units.
+type SystemdBus interface {
+	// StartUnit, StopUnit and RestartUnit queue a job for unit with the
+	// given mode, e.g. "replace", and return without waiting for it.
+	StartUnit(ctx context.Context, unit, mode string) error
+	StopUnit(ctx context.Context, unit, mode string) error
+	RestartUnit(ctx context.Context, unit, mode string) error
+	// KillUnit sends signal to whom ("main", "control" or "all") in unit.
+	KillUnit(ctx context.Context, unit, whom string, signal int32) error
+	// ActiveState returns the unit's ActiveState property: active,
+	// reloading, inactive, failed, activating or deactivating.
+	ActiveState(ctx context.Context, unit string) (string, error)
+}
+
+// systemdKillWait is how long Stop waits for a unit to go inactive after
+// killing it.
+const systemdKillWait = 10 * time.Second
+
+// SystemdManager manages agents that run as systemd units. Units must
+// already be installed; they are started and stopped, never created.
+type SystemdManager struct {
+	bus          SystemdBus
+	logger       *slog.Logger
+	pollInterval time.Duration // how often Stop checks the unit has stopped
+	killWait     time.Duration // how long Stop waits for a killed unit to stop
+	journalctl   string        // binary Logs reads the journal with
+}
+
+// NewSystemdManager creates a manager that talks to systemd over bus.
+func NewSystemdManager(bus SystemdBus, logger *slog.Logger) *SystemdManager {
+	return &SystemdManager{bus: bus, logger: logger, pollInterval: 200 * time.Millisecond, killWait: systemdKillWait, journalctl: "journalctl"}
+}
+
+func (m *SystemdManager) Start(ctx context.Context, unit string) error {
+	m.logger.Info("starting unit", "unit", unit)
+	if err := m.bus.StartUnit(ctx, unit, "replace"); err != nil {
+		return fmt.Errorf("start unit %s: %w", unit, err)
+	}
+	return nil
+}
+
+// Stop queues a stop job and waits for the unit to go inactive. If it is
+// still stopping after gracePeriod, everything left in it is sent SIGKILL,
+// and Stop waits up to systemdKillWait more for it to go inactive.
+func (m *SystemdManager) Stop(ctx context.Context, unit string, gracePeriod time.Duration) error {
+	m.logger.Info("stopping unit", "unit", unit, "grace_period", gracePeriod)
+	if err := m.bus.StopUnit(ctx, unit, "replace"); err != nil {
+		return fmt.Errorf("stop unit %s: %w", unit, err)
+	}
+
+	deadline := time.Now().Add(gracePeriod)
+	killed := false
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	for {
+		state, err := m.bus.ActiveState(ctx, unit)
+		if err != nil {
+			return fmt.Errorf("unit %s state: %w", unit, err)
+		}
+		if state == "inactive" || state == "failed" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if killed {
+				return fmt.Errorf("unit %s still %s after SIGKILL", unit, state)
+			}
+			m.logger.Warn("unit still stopping after grace period, killing", "unit", unit, "state", state)
+			if err := m.bus.KillUnit(ctx, unit, "all", int32(syscall.SIGKILL)); err != nil {
+				return fmt.Errorf("kill unit %s: %w", unit, err)
+			}
+			killed = true
+			deadline = time.Now().Add(m.killWait)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *SystemdManager) Restart(ctx context.Context, unit string, _ time.Duration) error {
+	m.logger.Info("restarting unit", "unit", unit)
+	if err := m.bus.RestartUnit(ctx, unit, "replace"); err != nil {
+		return fmt.Errorf("restart unit %s: %w", unit, err)
+	}
+	return nil
+}
+
+// Status reports "running" for an active unit, "starting" while it is
+// activating or reloading, and "exited" otherwise.
+func (m *SystemdManager) Status(ctx context.Context, unit string) (string, error) {
+	state, err := m.bus.ActiveState(ctx, unit)
+	if err != nil {
+		return "", fmt.Errorf("unit %s state: %w", unit, err)
+	}
+	return UnitStatus(state), nil
+}
+
+// Prober returns the health probe for a unit. Exec probes have no container
+// to run in and always fail.
+func (m *SystemdManager) Prober(_ string, p config.Probe) Prober {
+	return NewProber(p, "", nil)
+}
+
+// UnitStatus maps a systemd ActiveState to the status reported by Lifecycle.
+func UnitStatus(activeState string) string {
+	switch activeState {
+	case "active":
+		return "running"
+	case "activating", "reloading":
+		return "starting"
+	default:
+		return "exited"
+	}
+}
+
+// SystemdDBus calls the systemd D-Bus API on the system bus
+// (DBUS_SYSTEM_BUS_ADDRESS, or the default socket). It connects on first use
+// and again once the connection drops.
+type SystemdDBus struct {
+	mu   sync.Mutex
+	conn *sdbus.Conn
+}
+
+func (b *SystemdDBus) StartUnit(ctx context.Context, unit, mode string) error {
+	return b.job(ctx, "StartUnit", unit, func(c *sdbus.Conn) (int, error) {
+		return c.StartUnitContext(ctx, unit, mode, nil)
+	})
+}
+
+func (b *SystemdDBus) StopUnit(ctx context.Context, unit, mode string) error {
+	return b.job(ctx, "StopUnit", unit, func(c *sdbus.Conn) (int, error) {
+		return c.StopUnitContext(ctx, unit, mode, nil)
+	})
+}
+
+func (b *SystemdDBus) RestartUnit(ctx context.Context, unit, mode string) error {
+	return b.job(ctx, "RestartUnit", unit, func(c *sdbus.Conn) (int, error) {
+		return c.RestartUnitContext(ctx, unit, mode, nil)
+	})
+}
+
+func (b *SystemdDBus) KillUnit(ctx context.Context, unit, whom string, signal int32) error {
+	conn, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := conn.KillUnitWithTarget(ctx, unit, sdbus.Who(whom), signal); err != nil {
+		return fmt.Errorf("systemd KillUnit %s: %w", unit, err)
+	}
+	return nil
+}
+
+func (b *SystemdDBus) ActiveState(ctx context.Context, unit string) (string, error) {
+	conn, err := b.connect(ctx)
+	if err != nil {
+		return "", err
+	}
+	// systemd loads the unit on demand, so this also works for units that
+	// are stopped and have been unloaded.
+	prop, err := conn.GetUnitPropertyContext(ctx, unit, "ActiveState")
+	if err != nil {
+		return "", fmt.Errorf("systemd ActiveState of %s: %w", unit, err)
+	}
+	state, ok := prop.Value.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("systemd ActiveState of %s: unexpected value %v", unit, prop.Value)
+	}
+	return state, nil
+}
+
+// job queues a job through one of the Manager methods. A nil result channel
+// makes systemd return as soon as the job is queued.
+func (b *SystemdDBus) job(ctx context.Context, method, unit string, call func(*sdbus.Conn) (int, error)) error {
+	conn, err := b.connect(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := call(conn); err != nil {
+		return fmt.Errorf("systemd %s %s: %w", method, unit, err)
+	}
+	return nil
+}
+
+// connect returns the bus connection, dialling it first if there is none or
+// the last one dropped.
+func (b *SystemdDBus) connect(ctx context.Context) (*sdbus.Conn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn != nil && b.conn.Connected() {
+		return b.conn, nil
+	}
+	if b.conn != nil {
+		b.conn.Close()
+		b.conn = nil
+	}
+	conn, err := sdbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connect to systemd: %w", err)
+	}
+	b.conn = conn
+	return conn, nil
+}
@@ -0,0 +1,150 @@
+package container
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSystemdBus fakes the systemd D-Bus calls. Stopping a unit takes
+// stopPolls ActiveState reads, or never finishes if stopPolls < 0. Killing
+// it takes killPolls reads, or never finishes if killPolls < 0.
+type fakeSystemdBus struct {
+	mu        sync.Mutex
+	calls     []string
+	state     string
+	stopPolls int
+	killPolls int
+}
+
+func (f *fakeSystemdBus) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakeSystemdBus) StartUnit(_ context.Context, unit, mode string) error {
+	f.record("StartUnit " + unit + " " + mode)
+	f.mu.Lock()
+	f.state = "active"
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSystemdBus) StopUnit(_ context.Context, unit, mode string) error {
+	f.record("StopUnit " + unit + " " + mode)
+	f.mu.Lock()
+	f.state = "deactivating"
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSystemdBus) RestartUnit(_ context.Context, unit, mode string) error {
+	f.record("RestartUnit " + unit + " " + mode)
+	return nil
+}
+
+func (f *fakeSystemdBus) KillUnit(_ context.Context, unit, whom string, signal int32) error {
+	f.record("KillUnit " + unit + " " + whom)
+	f.mu.Lock()
+	f.state = "deactivating"
+	f.stopPolls = f.killPolls
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSystemdBus) ActiveState(_ context.Context, unit string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.state == "deactivating" && f.stopPolls >= 0 {
+		if f.stopPolls == 0 {
+			f.state = "inactive"
+		}
+		f.stopPolls--
+	}
+	return f.state, nil
+}
+
+func testSystemdManager(bus *fakeSystemdBus) *SystemdManager {
+	m := NewSystemdManager(bus, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.pollInterval = 10 * time.Millisecond
+	return m
+}
+
+func TestSystemdManagerStartStop(t *testing.T) {
+	bus := &fakeSystemdBus{state: "inactive", stopPolls: 2}
+	m := testSystemdManager(bus)
+	ctx := context.Background()
+
+	if err := m.Start(ctx, "agent.service"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := m.Status(ctx, "agent.service"); s != "running" {
+		t.Errorf("status = %q, want running", s)
+	}
+	if err := m.Stop(ctx, "agent.service", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := m.Status(ctx, "agent.service"); s != "exited" {
+		t.Errorf("status after stop = %q, want exited", s)
+	}
+	if err := m.Restart(ctx, "agent.service", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"StartUnit agent.service replace", "StopUnit agent.service replace", "RestartUnit agent.service replace"}
+	if !slices.Equal(bus.calls, want) {
+		t.Errorf("calls = %v, want %v", bus.calls, want)
+	}
+}
+
+func TestSystemdManagerKillsAfterGracePeriod(t *testing.T) {
+	bus := &fakeSystemdBus{state: "active", stopPolls: -1, killPolls: 2}
+	m := testSystemdManager(bus)
+
+	start := time.Now()
+	if err := m.Stop(context.Background(), "stuck.service", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("killed after %v, before the grace period", elapsed)
+	}
+	if !slices.Contains(bus.calls, "KillUnit stuck.service all") {
+		t.Errorf("calls = %v, want a KillUnit", bus.calls)
+	}
+	if bus.state != "inactive" {
+		t.Errorf("Stop returned with the unit %s, want inactive", bus.state)
+	}
+}
+
+func TestSystemdManagerKillTimeout(t *testing.T) {
+	bus := &fakeSystemdBus{state: "active", stopPolls: -1, killPolls: -1}
+	m := testSystemdManager(bus)
+	m.killWait = 50 * time.Millisecond
+
+	err := m.Stop(context.Background(), "stuck.service", 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "still deactivating after SIGKILL") {
+		t.Errorf("error = %v, want still deactivating after SIGKILL", err)
+	}
+}
+
+func TestUnitStatus(t *testing.T) {
+	tests := map[string]string{
+		"active":       "running",
+		"activating":   "starting",
+		"reloading":    "starting",
+		"deactivating": "exited",
+		"inactive":     "exited",
+		"failed":       "exited",
+	}
+	for state, want := range tests {
+		if got := UnitStatus(state); got != want {
+			t.Errorf("UnitStatus(%q) = %q, want %q", state, got, want)
+		}
+	}
+}
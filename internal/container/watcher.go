@@ -2,78 +2,176 @@ package container
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/client"
 )
 
+// EventClient is the part of the Docker API the Watcher uses.
+type EventClient interface {
+	Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error)
+}
+
+// Event is a service or container change seen by the Watcher.
+type Event struct {
+	ID        string // service or container ID
+	Service   string // Swarm service name; for containers, the service of their task
+	Container string // container name, for container events
+	Action    string // e.g. "container.die", "container.health_status", "service.update"
+	Health    string // "healthy" or "unhealthy", for container.health_status
+}
+
 // EventHandler is called when a Docker event is observed.
-type EventHandler func(serviceID, serviceName, action string)
+type EventHandler func(Event)
 
 // Watcher subscribes to Docker events and calls the handler on state changes.
+// If the event stream drops it reconnects with backoff, resuming from the
+// last event seen so nothing in between is missed.
 type Watcher struct {
-	docker  *client.Client
-	handler EventHandler
-	logger  *slog.Logger
+	docker     EventClient
+	handler    EventHandler
+	logger     *slog.Logger
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	since time.Time       // daemon's time of the last event seen; zero before the first
+	seen  map[string]bool // events at since, dropped if the daemon replays them
 }
 
 // NewWatcher creates a new Docker event watcher.
-func NewWatcher(docker *client.Client, handler EventHandler, logger *slog.Logger) *Watcher {
+func NewWatcher(docker EventClient, handler EventHandler, logger *slog.Logger) *Watcher {
 	return &Watcher{
-		docker:  docker,
-		handler: handler,
-		logger:  logger.With("component", "docker-watcher"),
+		docker:     docker,
+		handler:    handler,
+		logger:     logger.With("component", "docker-watcher"),
+		minBackoff: time.Second,
+		maxBackoff: 30 * time.Second,
 	}
 }
 
 // Watch subscribes to Docker events and blocks until ctx is cancelled.
 func (w *Watcher) Watch(ctx context.Context) {
+	delay := w.minBackoff
+	for {
+		received := w.watchOnce(ctx)
+		if ctx.Err() != nil {
+			w.logger.Info("docker watcher stopped")
+			return
+		}
+		if received {
+			delay = w.minBackoff
+		}
+		w.logger.Warn("docker event stream lost, reconnecting", "in", delay, "since", w.since)
+		select {
+		case <-ctx.Done():
+			w.logger.Info("docker watcher stopped")
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, w.maxBackoff)
+	}
+}
+
+// watchOnce follows one event subscription until it fails, and reports
+// whether it delivered any events.
+func (w *Watcher) watchOnce(ctx context.Context) (received bool) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	f := filters.NewArgs()
 	f.Add("type", "service")
 	f.Add("type", "container")
-
-	msgCh, errCh := w.docker.Events(ctx, events.ListOptions{Filters: f})
+	msgCh, errCh := w.docker.Events(ctx, events.ListOptions{
+		Filters: f,
+		Since:   sinceParam(w.since),
+	})
 
 	w.logger.Info("watching Docker events")
 	for {
 		select {
 		case <-ctx.Done():
-			w.logger.Info("docker watcher stopped")
-			return
+			return received
 		case err := <-errCh:
-			if ctx.Err() != nil {
-				return
+			if ctx.Err() == nil {
+				w.logger.Error("docker events error", "error", err)
 			}
-			w.logger.Error("docker events error", "error", err)
-			return
+			return received
 		case msg := <-msgCh:
-			w.handleEvent(msg)
+			received = true
+			if !w.replayed(msg) {
+				w.handleEvent(msg)
+			}
 		}
 	}
 }
 
+// replayed reports whether msg was already handled before a reconnect. The
+// daemon resends events at exactly the since time, so those are remembered.
+// since only ever comes from the daemon's own timestamps, so a clock skewed
+// from the daemon's can't make us drop events.
+func (w *Watcher) replayed(msg events.Message) bool {
+	t := time.Unix(0, msg.TimeNano)
+	key := fmt.Sprintf("%s/%s/%s", msg.Type, msg.Actor.ID, msg.Action)
+	switch {
+	case w.since.IsZero():
+		w.since = t
+		w.seen = make(map[string]bool)
+	case t.Before(w.since):
+		return true
+	case t.Equal(w.since):
+		if w.seen[key] {
+			return true
+		}
+	default:
+		w.since = t
+		w.seen = make(map[string]bool)
+	}
+	w.seen[key] = true
+	return false
+}
+
+// sinceParam formats t as the seconds.nanoseconds timestamp the events API
+// takes for since. Before any event has been seen it is empty, subscribing
+// from now by the daemon's clock.
+func sinceParam(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
 func (w *Watcher) handleEvent(msg events.Message) {
 	switch msg.Type {
 	case events.ContainerEventType:
-		switch msg.Action {
-		case "start", "die", "health_status":
+		// Health events carry the status in the action, e.g.
+		// "health_status: unhealthy".
+		action, health, _ := strings.Cut(string(msg.Action), ": ")
+		switch action {
+		case "start", "die", "health_status", "create", "destroy":
 			name := msg.Actor.Attributes["name"]
-			service := msg.Actor.Attributes["com.docker.swarm.service.name"]
 			id := msg.Actor.ID
 			if len(id) > 12 {
 				id = id[:12]
 			}
 			w.logger.Info("container event", "action", msg.Action, "container", name, "id", id)
-			w.handler(msg.Actor.ID, service, "container."+string(msg.Action))
+			w.handler(Event{
+				ID:        msg.Actor.ID,
+				Service:   msg.Actor.Attributes["com.docker.swarm.service.name"],
+				Container: name,
+				Action:    "container." + action,
+				Health:    health,
+			})
 		}
 	case events.ServiceEventType:
 		switch msg.Action {
-		case "update":
+		case "create", "update", "remove":
 			name := msg.Actor.Attributes["name"]
 			w.logger.Info("service event", "action", msg.Action, "service", name)
-			w.handler(msg.Actor.ID, name, "service."+string(msg.Action))
+			w.handler(Event{ID: msg.Actor.ID, Service: name, Action: "service." + string(msg.Action)})
 		}
 	}
 }
@@ -0,0 +1,112 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// fakeEventClient serves one scripted subscription per Events call. Each
+// subscription delivers its messages and then fails.
+type fakeEventClient struct {
+	mu      sync.Mutex
+	streams [][]events.Message
+	since   []string
+}
+
+func (f *fakeEventClient) Events(ctx context.Context, opts events.ListOptions) (<-chan events.Message, <-chan error) {
+	f.mu.Lock()
+	f.since = append(f.since, opts.Since)
+	var msgs []events.Message
+	if len(f.streams) > 0 {
+		msgs, f.streams = f.streams[0], f.streams[1:]
+	}
+	f.mu.Unlock()
+
+	msgCh := make(chan events.Message)
+	errCh := make(chan error, 1)
+	go func() {
+		for _, m := range msgs {
+			select {
+			case msgCh <- m:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if msgs == nil {
+			<-ctx.Done() // last subscription stays open
+			return
+		}
+		errCh <- errors.New("unexpected EOF")
+	}()
+	return msgCh, errCh
+}
+
+func containerMsg(at time.Time, id, action string) events.Message {
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   events.Action(action),
+		Actor:    events.Actor{ID: id, Attributes: map[string]string{"name": id, "com.docker.swarm.service.name": "svc"}},
+		TimeNano: at.UnixNano(),
+	}
+}
+
+func TestWatcherReconnectsFromLastEvent(t *testing.T) {
+	// The daemon's clock is behind ours, which must not drop its events.
+	start := time.Now().Add(-time.Hour)
+	t1, t2 := start.Add(time.Second), start.Add(2*time.Second)
+	docker := &fakeEventClient{streams: [][]events.Message{
+		{containerMsg(t1, "a", "start"), containerMsg(t2, "a", "die")},
+		// The daemon replays the event at since before the new ones.
+		{containerMsg(t2, "a", "die"), containerMsg(t2, "b", "die"), containerMsg(t2.Add(time.Second), "a", "health_status: unhealthy")},
+	}}
+
+	got := make(chan Event, 10)
+	w := NewWatcher(docker, func(ev Event) { got <- ev }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.minBackoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Watch(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	want := []Event{
+		{ID: "a", Service: "svc", Container: "a", Action: "container.start"},
+		{ID: "a", Service: "svc", Container: "a", Action: "container.die"},
+		{ID: "b", Service: "svc", Container: "b", Action: "container.die"},
+		{ID: "a", Service: "svc", Container: "a", Action: "container.health_status", Health: "unhealthy"},
+	}
+	for i, wantEv := range want {
+		select {
+		case ev := <-got:
+			if ev != wantEv {
+				t.Errorf("event %d = %+v, want %+v", i, ev, wantEv)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+	select {
+	case ev := <-got:
+		t.Errorf("unexpected extra event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	docker.mu.Lock()
+	defer docker.mu.Unlock()
+	if len(docker.since) < 2 || docker.since[0] != "" || docker.since[1] != sinceParam(t2) {
+		t.Errorf("subscribed with since = %q, want \"\" then %s", docker.since, sinceParam(t2))
+	}
+}
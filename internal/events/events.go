@@ -9,14 +9,20 @@ import (
 // Event type constants.
 const (
 	AgentReady        = "agent.ready"
+	AgentUnready      = "agent.unready"
 	AgentDegraded     = "agent.degraded"
 	AgentWake         = "agent.wake"
 	AgentSleep        = "agent.sleep"
+	AgentDraining     = "agent.draining"
 	AgentStarting     = "agent.starting"
 	AgentHealthFailed = "agent.health_failed"
 	RestartExhausted  = "restart.exhausted"
 	AgentAdded        = "agent.added"
 	AgentRemoved      = "agent.removed"
+	AgentScaled       = "agent.scaled"
+	AgentEvicted      = "agent.evicted"
+	AgentDrift        = "agent.drift"
+	AgentExec         = "agent.exec"
 )
 
 // Event represents a lifecycle event for an agent.
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -44,6 +45,56 @@ var (
 		Name: "warren_agent_sleep_total",
 		Help: "Sleep events per agent",
 	}, []string{"agent"})
+
+	ProxyRequestsHeld = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "warren_proxy_requests_held",
+		Help: "Requests currently held waiting for a cold-starting agent",
+	}, []string{"agent"})
+
+	ProxyHeldRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "warren_proxy_held_requests_total",
+		Help: "Held requests per agent by outcome (forwarded, expired, failed, rejected)",
+	}, []string{"agent", "result"})
+
+	AgentEvictionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "warren_agent_evictions_total",
+		Help: "LRU evictions per agent by reason",
+	}, []string{"agent", "reason"})
+
+	AgentDriftTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "warren_agent_drift_total",
+		Help: "Disagreements between policy state and the runtime found by the reconciler, by corrective action",
+	}, []string{"agent", "action"})
+
+	AgentDrainForcedClosedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "warren_agent_drain_forced_closed_total",
+		Help: "WebSocket connections still open when drain_timeout expired and the agent was stopped",
+	}, []string{"agent"})
+
+	AgentReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "warren_agent_replicas",
+		Help: "Replica count set by the autoscale policy",
+	}, []string{"agent"})
+
+	AgentCPUPercent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "warren_agent_cpu_percent",
+		Help: "CPU use of the agent's running tasks from Docker stats (100 = one core)",
+	}, []string{"agent"})
+
+	AgentMemoryBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "warren_agent_memory_bytes",
+		Help: "Memory use of the agent's running tasks from Docker stats",
+	}, []string{"agent"})
+
+	HostMemoryBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "warren_host_memory_bytes",
+		Help: "Host memory: total, used by the host as a whole, and used by all running containers",
+	}, []string{"kind"})
+
+	ProxyTargetEjectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "warren_proxy_target_ejections_total",
+		Help: "Backend targets ejected from load balancing after repeated proxy errors",
+	}, []string{"agent"})
 )
 
 func init() {
@@ -55,6 +106,16 @@ func init() {
 		ServiceRegistrations,
 		AgentWakeTotal,
 		AgentSleepTotal,
+		ProxyRequestsHeld,
+		ProxyHeldRequestsTotal,
+		ProxyTargetEjectionsTotal,
+		AgentReplicas,
+		AgentDrainForcedClosedTotal,
+		AgentEvictionsTotal,
+		AgentDriftTotal,
+		AgentCPUPercent,
+		AgentMemoryBytes,
+		HostMemoryBytes,
 	)
 }
 
@@ -63,7 +124,7 @@ func Handler() http.Handler {
 	return promhttp.Handler()
 }
 
-var allStates = []string{"sleeping", "starting", "ready", "degraded"}
+var allStates = []string{"sleeping", "starting", "ready", "unready", "draining", "degraded"}
 
 func setAgentState(agent, state string) {
 	for _, s := range allStates {
@@ -81,17 +142,32 @@ func RegisterEventHandler(emitter *events.Emitter) {
 		switch ev.Type {
 		case events.AgentReady:
 			setAgentState(ev.Agent, "ready")
+		case events.AgentUnready:
+			setAgentState(ev.Agent, "unready")
 		case events.AgentDegraded:
 			setAgentState(ev.Agent, "degraded")
 		case events.AgentStarting:
 			setAgentState(ev.Agent, "starting")
+		case events.AgentDraining:
+			setAgentState(ev.Agent, "draining")
 		case events.AgentSleep:
 			setAgentState(ev.Agent, "sleeping")
 			AgentSleepTotal.WithLabelValues(ev.Agent).Inc()
+			if n, err := strconv.Atoi(ev.Fields["forced_closed"]); err == nil {
+				AgentDrainForcedClosedTotal.WithLabelValues(ev.Agent).Add(float64(n))
+			}
 		case events.AgentWake:
 			AgentWakeTotal.WithLabelValues(ev.Agent).Inc()
 		case events.AgentHealthFailed:
 			AgentHealthChecksTotal.WithLabelValues(ev.Agent, "fail").Inc()
+		case events.AgentEvicted:
+			AgentEvictionsTotal.WithLabelValues(ev.Agent, ev.Fields["reason"]).Inc()
+		case events.AgentDrift:
+			AgentDriftTotal.WithLabelValues(ev.Agent, ev.Fields["action"]).Inc()
+		case events.AgentScaled:
+			if n, err := strconv.Atoi(ev.Fields["to"]); err == nil {
+				AgentReplicas.WithLabelValues(ev.Agent).Set(float64(n))
+			}
 		}
 	})
 }
@@ -28,4 +28,6 @@ func TestRegisterEventHandlerUpdatesCounters(t *testing.T) {
 	emitter.Emit(events.Event{Type: events.AgentWake, Agent: "test"})
 	emitter.Emit(events.Event{Type: events.AgentHealthFailed, Agent: "test"})
 	emitter.Emit(events.Event{Type: events.AgentStarting, Agent: "test"})
+	emitter.Emit(events.Event{Type: events.AgentDraining, Agent: "test"})
+	emitter.Emit(events.Event{Type: events.AgentSleep, Agent: "test", Fields: map[string]string{"forced_closed": "2"}})
 }
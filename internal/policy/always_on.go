@@ -2,6 +2,7 @@ package policy
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
@@ -11,51 +12,116 @@ import (
 )
 
 type AlwaysOn struct {
-	agent     string
-	healthURL string
+	manager        container.Lifecycle
+	agent          string
+	containerName  string
+	probe          container.Prober // liveness
+	startupProbe   container.Prober
+	readinessProbe container.Prober // nil means always ready
 
-	checkInterval time.Duration
-	maxFailures   int
+	checkInterval      time.Duration
+	startupInterval    time.Duration
+	startupTimeout     time.Duration
+	maxFailures        int
+	maxRestartAttempts int
 
-	mu       sync.RWMutex
-	state    string
-	failures int
+	mu              sync.RWMutex
+	state           string
+	startedAt       time.Time // when the agent last entered starting
+	failures        int
+	restartAttempts int       // consecutive restarts since the agent was last ready
+	restarts        int       // total restarts performed
+	lastRestart     time.Time // when the last restart was issued
+	nextRestart     time.Time // earliest time the next restart may be issued
+	backoff         *backoff
+	notify          stateBroadcast
+
+	recoveryBackoff    time.Duration
+	recoveryMaxBackoff time.Duration
+	recovery           *backoff  // delay between recovery attempts while degraded; nil until needed
+	recovering         bool      // starting after a recovery restart
+	healthySince       time.Time // start of the current run of passing checks
 
 	emitter *events.Emitter
 	logger  *slog.Logger
 }
 
 type AlwaysOnConfig struct {
-	Agent         string
-	HealthURL     string
-	CheckInterval time.Duration
-	MaxFailures   int
+	Agent              string
+	ContainerName      string // service to restart; empty leaves recovery to Swarm
+	HealthURL          string
+	Probe              container.Prober // liveness; defaults to an HTTP GET of HealthURL
+	StartupProbe       container.Prober // used while starting; defaults to Probe
+	ReadinessProbe     container.Prober // optional; failing takes the agent out of routing
+	CheckInterval      time.Duration
+	StartupInterval    time.Duration // poll interval while starting; defaults to CheckInterval
+	StartupTimeout     time.Duration // failures while starting don't count until this has passed
+	MaxFailures        int
+	MaxRestartAttempts int
+	RecoveryBackoff    time.Duration // delay before the second restart and the first recovery attempt, doubling after
+	RecoveryMaxBackoff time.Duration
+}
+
+// RestartStatus summarises the restarts an always-on policy has performed.
+type RestartStatus struct {
+	Count       int        `json:"count"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastRestart *time.Time `json:"last_restart,omitempty"`
 }
 
-func NewAlwaysOn(cfg AlwaysOnConfig, emitter *events.Emitter, logger *slog.Logger) *AlwaysOn {
+// NewAlwaysOn creates an always-on policy. If manager is nil or the config has
+// no ContainerName, the policy only monitors health and never restarts.
+func NewAlwaysOn(manager container.Lifecycle, cfg AlwaysOnConfig, emitter *events.Emitter, logger *slog.Logger) *AlwaysOn {
+	probe := probeFor(cfg.Probe, cfg.HealthURL)
+	startupProbe := cfg.StartupProbe
+	if startupProbe == nil {
+		startupProbe = probe
+	}
+	startupInterval := cfg.StartupInterval
+	if startupInterval <= 0 {
+		startupInterval = cfg.CheckInterval
+	}
 	return &AlwaysOn{
-		agent:         cfg.Agent,
-		healthURL:     cfg.HealthURL,
-		checkInterval: cfg.CheckInterval,
-		maxFailures:   cfg.MaxFailures,
-		state:         "starting",
-		emitter:       emitter,
-		logger:        logger.With("agent", cfg.Agent, "policy", "always-on"),
+		manager:            manager,
+		agent:              cfg.Agent,
+		containerName:      cfg.ContainerName,
+		probe:              probe,
+		startupProbe:       startupProbe,
+		readinessProbe:     cfg.ReadinessProbe,
+		checkInterval:      cfg.CheckInterval,
+		startupInterval:    startupInterval,
+		startupTimeout:     cfg.StartupTimeout,
+		maxFailures:        cfg.MaxFailures,
+		maxRestartAttempts: cfg.MaxRestartAttempts,
+		state:              "starting",
+		backoff:            newBackoff(cfg.RecoveryBackoff, cfg.RecoveryMaxBackoff),
+		recoveryBackoff:    cfg.RecoveryBackoff,
+		recoveryMaxBackoff: cfg.RecoveryMaxBackoff,
+		emitter:            emitter,
+		logger:             logger.With("agent", cfg.Agent, "policy", "always-on"),
 	}
 }
 
 func (a *AlwaysOn) Start(ctx context.Context) {
+	a.mu.Lock()
+	a.startedAt = time.Now()
+	a.mu.Unlock()
 	a.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: a.agent})
 
-	ticker := time.NewTicker(a.checkInterval)
-	defer ticker.Stop()
+	timer := time.NewTimer(a.nextCheck())
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case <-timer.C:
 			a.tick(ctx)
+			if a.State() == "degraded" && a.canRestart() {
+				a.recoverDegraded(ctx)
+			}
+			timer.Reset(a.nextCheck())
 		}
 	}
 }
@@ -68,54 +134,413 @@ func (a *AlwaysOn) State() string {
 
 func (a *AlwaysOn) OnRequest() {}
 
+// StateChanged returns a channel that is closed on the next state transition.
+func (a *AlwaysOn) StateChanged() <-chan struct{} {
+	return a.notify.wait()
+}
+
+// Restarts reports how many restarts the policy has issued.
+func (a *AlwaysOn) Restarts() RestartStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	st := RestartStatus{
+		Count:       a.restarts,
+		Attempts:    a.restartAttempts,
+		MaxAttempts: a.maxRestartAttempts,
+	}
+	if !a.lastRestart.IsZero() {
+		t := a.lastRestart
+		st.LastRestart = &t
+	}
+	return st
+}
+
+// RuntimeState returns the restart counters to checkpoint across
+// orchestrator restarts.
+func (a *AlwaysOn) RuntimeState() RuntimeState {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return RuntimeState{
+		RestartAttempts: a.restartAttempts,
+		Restarts:        a.restarts,
+		LastRestart:     a.lastRestart,
+	}
+}
+
+// RestoreRuntimeState restores checkpointed restart counters, so a restart
+// loop that spans an orchestrator restart still runs out of attempts and
+// keeps backing off. Call it before Start.
+func (a *AlwaysOn) RestoreRuntimeState(st RuntimeState) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.restartAttempts = st.RestartAttempts
+	a.restarts = st.Restarts
+	a.lastRestart = st.LastRestart
+	a.backoff.reset()
+	var delay time.Duration
+	for range st.RestartAttempts {
+		delay = a.backoff.next()
+	}
+	if delay > 0 && !st.LastRestart.IsZero() {
+		a.nextRestart = st.LastRestart.Add(delay)
+	}
+}
+
+// Reset clears the degraded state by restarting the container, and refills
+// the restart budget. The agent moves to starting and becomes ready once
+// health checks pass. A monitor-only policy has nothing to restart and just
+// goes back to starting.
+func (a *AlwaysOn) Reset(ctx context.Context) error {
+	if a.State() != "degraded" {
+		return ErrNotDegraded
+	}
+	a.logger.Info("manual reset requested")
+	if a.canRestart() {
+		if err := a.manager.Restart(ctx, a.containerName, 10*time.Second); err != nil {
+			return fmt.Errorf("restart %s: %w", a.containerName, err)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.leaveDegraded("starting", map[string]string{"reason": "reset"}) {
+		return ErrNotDegraded
+	}
+	a.recovery = nil
+	return nil
+}
+
+// ObserveRuntime reconciles the policy with a runtime event. A container
+// that died puts the agent back to starting, where the startup timeout gives
+// Swarm time to replace it before failures count toward a restart; one whose
+// own healthcheck fails is unready until the next passing check.
+func (a *AlwaysOn) ObserveRuntime(event string) {
+	switch event {
+	case RuntimeDied:
+		if a.manager != nil && a.containerName != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			status, err := a.manager.Status(ctx, a.containerName)
+			cancel()
+			if err != nil || status == "running" {
+				return
+			}
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.state != "ready" && a.state != "unready" {
+			return
+		}
+		a.logger.Warn("container exited, waiting for it to come back")
+		a.state = "starting"
+		a.startedAt = time.Now()
+		a.failures = 0
+		a.notify.notify()
+		a.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: a.agent, Fields: map[string]string{"reason": "container_exited"}})
+	case RuntimeUnhealthy:
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.state != "ready" {
+			return
+		}
+		a.logger.Warn("container reported unhealthy, taking agent out of routing")
+		a.state = "unready"
+		a.notify.notify()
+		a.emitter.Emit(events.Event{Type: events.AgentUnready, Agent: a.agent, Fields: map[string]string{"reason": "container_unhealthy"}})
+	}
+}
+
+// CorrectDrift starts the container again if the runtime reports it stopped
+// while the agent is ready or unready, and moves the agent to starting.
+// Starting a container that is in fact running does nothing, so a stale
+// status is harmless.
+func (a *AlwaysOn) CorrectDrift(ctx context.Context, status string, _ time.Time) (Drift, bool) {
+	if status != "exited" || a.manager == nil || a.containerName == "" {
+		return Drift{}, false
+	}
+	state := a.State()
+	if state != "ready" && state != "unready" {
+		return Drift{}, false
+	}
+	drift := Drift{PolicyState: state, RuntimeState: status, Action: "start"}
+	if err := a.manager.Start(ctx, a.containerName); err != nil {
+		a.logger.Error("failed to start drifted container", "error", err)
+		drift.Action = "start_failed"
+		return drift, true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state == state {
+		a.state = "starting"
+		a.startedAt = time.Now()
+		a.failures = 0
+		a.notify.notify()
+		a.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: a.agent, Fields: map[string]string{"reason": "drift"}})
+	}
+	return drift, true
+}
+
 // Reconfigure updates runtime parameters that can change safely.
-func (a *AlwaysOn) Reconfigure(checkInterval time.Duration, maxFailures int) {
+func (a *AlwaysOn) Reconfigure(checkInterval time.Duration, maxFailures, maxRestartAttempts int) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.checkInterval = checkInterval
 	a.maxFailures = maxFailures
-	a.logger.Info("reconfigured", "check_interval", checkInterval, "max_failures", maxFailures)
+	a.maxRestartAttempts = maxRestartAttempts
+	a.logger.Info("reconfigured", "check_interval", checkInterval, "max_failures", maxFailures, "max_restart_attempts", maxRestartAttempts)
+}
+
+// nextCheck returns how long to wait before the next probe.
+func (a *AlwaysOn) nextCheck() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.state == "starting" {
+		return a.startupInterval
+	}
+	return a.checkInterval
 }
 
 func (a *AlwaysOn) tick(ctx context.Context) {
-	err := container.CheckHealth(ctx, a.healthURL)
-	if err == nil {
-		a.onHealthy()
+	probe := a.probe
+	if a.State() == "starting" {
+		probe = a.startupProbe
+	}
+	if err := probe.Probe(ctx); err != nil {
+		if a.onUnhealthy(err) {
+			a.restart(ctx)
+		}
 		return
 	}
-	a.onUnhealthy(err)
+	var readyErr error
+	if a.readinessProbe != nil {
+		readyErr = a.readinessProbe.Probe(ctx)
+	}
+	a.onHealthy(readyErr)
 }
 
-func (a *AlwaysOn) onHealthy() {
+// onHealthy records a passing liveness (or startup) check. readyErr is the
+// readiness result and decides between ready and unready.
+func (a *AlwaysOn) onHealthy(readyErr error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	state := "ready"
+	if readyErr != nil {
+		state = "unready"
+	}
 	prev := a.state
-	a.state = "ready"
+	a.state = state
 	a.failures = 0
+	a.recovering = false
+	a.clearRestarts()
+	// Healthy long enough that an earlier degraded spell is over.
+	if a.healthySince.IsZero() {
+		a.healthySince = time.Now()
+	} else if time.Since(a.healthySince) >= a.recoveryMaxBackoff {
+		a.recovery = nil
+	}
 
-	if prev != "ready" {
-		a.logger.Info("agent became healthy", "state", "ready")
-		a.emitter.Emit(events.Event{Type: events.AgentReady, Agent: a.agent})
+	if prev == state {
+		return
+	}
+	a.notify.notify()
+	if readyErr != nil {
+		a.logger.Warn("agent alive but not ready", "error", readyErr)
+		a.emitter.Emit(events.Event{Type: events.AgentUnready, Agent: a.agent, Fields: map[string]string{"error": readyErr.Error()}})
+		return
 	}
+	a.logger.Info("agent became healthy", "state", "ready")
+	a.emitter.Emit(events.Event{Type: events.AgentReady, Agent: a.agent})
 }
 
-func (a *AlwaysOn) onUnhealthy(err error) {
+// onUnhealthy records a failed check and reports whether a restart is due.
+func (a *AlwaysOn) onUnhealthy(err error) bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if a.state == "starting" && time.Since(a.startedAt) < a.startupTimeout {
+		a.logger.Info("startup probe failed, still within startup timeout", "error", err)
+		return false
+	}
+
 	a.failures++
+	a.healthySince = time.Time{}
 	a.logger.Warn("health check failed", "error", err, "consecutive_failures", a.failures)
 	a.emitter.Emit(events.Event{Type: events.AgentHealthFailed, Agent: a.agent, Fields: map[string]string{"error": err.Error()}})
 
-	if a.failures >= a.maxFailures {
-		if a.state != "degraded" {
-			a.logger.Error("agent degraded, max failures reached",
-				"consecutive_failures", a.failures,
-				"max_failures", a.maxFailures,
-			)
-			a.emitter.Emit(events.Event{Type: events.AgentDegraded, Agent: a.agent})
-		}
+	// A recovery restart that doesn't come up leaves the agent degraded, so
+	// recovery carries on with the next backoff rather than a fresh budget.
+	if a.recovering && a.state == "starting" {
+		a.recovering = false
+		a.logger.Error("startup timeout exceeded after recovery restart, still degraded")
 		a.state = "degraded"
+		a.notify.notify()
+		a.emitter.Emit(events.Event{Type: events.AgentDegraded, Agent: a.agent, Fields: map[string]string{"reason": "recovery_timeout"}})
+		return false
+	}
+	if a.failures < a.maxFailures || a.state == "degraded" {
+		return false
+	}
+	if !a.canRestart() {
+		a.degrade()
+		return false
+	}
+	if a.restartAttempts >= a.maxRestartAttempts {
+		a.logger.Error("all restart attempts exhausted", "max", a.maxRestartAttempts)
+		a.emitter.Emit(events.Event{Type: events.RestartExhausted, Agent: a.agent})
+		a.degrade()
+		return false
+	}
+	return !time.Now().Before(a.nextRestart)
+}
+
+// restart issues one restart through the manager. Failed calls still use up
+// an attempt, and the next one waits out the backoff either way.
+func (a *AlwaysOn) restart(ctx context.Context) {
+	a.mu.Lock()
+	a.restartAttempts++
+	attempt, maxAttempts := a.restartAttempts, a.maxRestartAttempts
+	a.nextRestart = time.Now().Add(a.backoff.next())
+	a.mu.Unlock()
+
+	a.logger.Info("restarting container", "attempt", attempt, "max", maxAttempts)
+	if err := a.manager.Restart(ctx, a.containerName, 10*time.Second); err != nil {
+		a.logger.Error("restart failed", "attempt", attempt, "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.restarts++
+	a.lastRestart = time.Now()
+	a.startedAt = a.lastRestart
+	a.failures = 0
+	if a.state != "starting" {
+		a.state = "starting"
+		a.notify.notify()
+		a.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: a.agent, Fields: map[string]string{
+			"reason":  "restart",
+			"attempt": fmt.Sprintf("%d", attempt),
+		}})
+	}
+}
+
+// recoverDegraded runs while the agent is degraded. With exponential backoff
+// between attempts it probes health, going straight back to ready if the
+// backend has recovered on its own, and otherwise restarts the container and
+// moves to starting. It returns as soon as the agent leaves the degraded
+// state, whether through recovery or a manual reset. The backoff carries over
+// to the next degraded spell unless the agent stays healthy for
+// recovery_max_backoff in between.
+func (a *AlwaysOn) recoverDegraded(ctx context.Context) {
+	for attempt := 1; ; attempt++ {
+		delay := a.nextRecoveryDelay()
+		a.logger.Info("agent degraded, scheduling recovery attempt", "attempt", attempt, "in", delay)
+		if !a.waitWhileDegraded(ctx, delay) {
+			return
+		}
+
+		if err := a.probe.Probe(ctx); err == nil {
+			state := "ready"
+			if a.readinessProbe != nil && a.readinessProbe.Probe(ctx) != nil {
+				state = "unready"
+			}
+			a.mu.Lock()
+			if a.leaveDegraded(state, map[string]string{"reason": "recovered"}) {
+				a.logger.Info("health recovered while degraded", "attempt", attempt)
+			}
+			a.mu.Unlock()
+			return
+		}
+
+		a.logger.Info("recovery restart", "attempt", attempt)
+		if err := a.manager.Restart(ctx, a.containerName, 10*time.Second); err != nil {
+			a.logger.Error("recovery restart failed", "attempt", attempt, "error", err)
+			continue
+		}
+		a.mu.Lock()
+		if a.leaveDegraded("starting", map[string]string{"reason": "recovery"}) {
+			a.recovering = true
+			a.restarts++
+			a.lastRestart = a.startedAt
+		}
+		a.mu.Unlock()
+		return
 	}
 }
+
+// nextRecoveryDelay returns the wait before the next recovery attempt.
+func (a *AlwaysOn) nextRecoveryDelay() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.recovery == nil {
+		a.recovery = newBackoff(a.recoveryBackoff, a.recoveryMaxBackoff)
+	}
+	return a.recovery.next()
+}
+
+// waitWhileDegraded sleeps for d, returning false early if ctx is cancelled or
+// the agent leaves the degraded state.
+func (a *AlwaysOn) waitWhileDegraded(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		changed := a.notify.wait()
+		if a.State() != "degraded" {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		case <-timer.C:
+			return a.State() == "degraded"
+		}
+	}
+}
+
+// leaveDegraded moves a degraded agent to state with a fresh failure count
+// and restart budget, reporting false if it was no longer degraded. Callers
+// hold a.mu.
+func (a *AlwaysOn) leaveDegraded(state string, fields map[string]string) bool {
+	if a.state != "degraded" {
+		return false
+	}
+	a.state = state
+	a.failures = 0
+	a.clearRestarts()
+	typ := events.AgentReady
+	switch state {
+	case "starting":
+		a.startedAt = time.Now()
+		typ = events.AgentStarting
+	case "unready":
+		typ = events.AgentUnready
+	}
+	a.notify.notify()
+	a.emitter.Emit(events.Event{Type: typ, Agent: a.agent, Fields: fields})
+	return true
+}
+
+// canRestart reports whether the policy has a container to restart.
+func (a *AlwaysOn) canRestart() bool {
+	return a.manager != nil && a.containerName != ""
+}
+
+// degrade marks the agent degraded. Callers hold a.mu.
+func (a *AlwaysOn) degrade() {
+	a.logger.Error("agent degraded, max failures reached",
+		"consecutive_failures", a.failures,
+		"max_failures", a.maxFailures,
+	)
+	a.emitter.Emit(events.Event{Type: events.AgentDegraded, Agent: a.agent})
+	a.state = "degraded"
+	a.notify.notify()
+}
+
+// clearRestarts restores the full restart budget. Callers hold a.mu.
+func (a *AlwaysOn) clearRestarts() {
+	a.restartAttempts = 0
+	a.nextRestart = time.Time{}
+	a.backoff.reset()
+}
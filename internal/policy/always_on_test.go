@@ -31,7 +31,7 @@ func TestAlwaysOnStartingToReady(t *testing.T) {
 		}
 	})
 
-	ao := NewAlwaysOn(AlwaysOnConfig{
+	ao := NewAlwaysOn(nil, AlwaysOnConfig{
 		Agent:         "test",
 		HealthURL:     srv.URL,
 		CheckInterval: 50 * time.Millisecond,
@@ -82,7 +82,7 @@ func TestAlwaysOnReadyToDegraded(t *testing.T) {
 		}
 	})
 
-	ao := NewAlwaysOn(AlwaysOnConfig{
+	ao := NewAlwaysOn(nil, AlwaysOnConfig{
 		Agent:         "test",
 		HealthURL:     srv.URL,
 		CheckInterval: 50 * time.Millisecond,
@@ -129,7 +129,7 @@ func TestAlwaysOnDegradedToReady(t *testing.T) {
 	defer srv.Close()
 
 	emitter := events.NewEmitter(quietLogger())
-	ao := NewAlwaysOn(AlwaysOnConfig{
+	ao := NewAlwaysOn(nil, AlwaysOnConfig{
 		Agent:         "test",
 		HealthURL:     srv.URL,
 		CheckInterval: 50 * time.Millisecond,
@@ -164,14 +164,14 @@ func TestAlwaysOnDegradedToReady(t *testing.T) {
 
 func TestAlwaysOnReconfigure(t *testing.T) {
 	emitter := events.NewEmitter(quietLogger())
-	ao := NewAlwaysOn(AlwaysOnConfig{
+	ao := NewAlwaysOn(nil, AlwaysOnConfig{
 		Agent:         "test",
 		HealthURL:     "http://localhost:1",
 		CheckInterval: time.Second,
 		MaxFailures:   3,
 	}, emitter, quietLogger())
 
-	ao.Reconfigure(5*time.Second, 10)
+	ao.Reconfigure(5*time.Second, 10, 4)
 	ao.mu.RLock()
 	if ao.checkInterval != 5*time.Second {
 		t.Errorf("checkInterval = %v", ao.checkInterval)
@@ -181,3 +181,143 @@ func TestAlwaysOnReconfigure(t *testing.T) {
 	}
 	ao.mu.RUnlock()
 }
+
+func TestAlwaysOnRestartsThenExhausts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	emitter := events.NewEmitter(quietLogger())
+	mgr := &mockLifecycle{status: "running"}
+	var exhausted, restartsAtExhaustion int32
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.RestartExhausted {
+			atomic.AddInt32(&exhausted, 1)
+			// Recovery restarts follow, so count the budgeted ones here.
+			atomic.StoreInt32(&restartsAtExhaustion, atomic.LoadInt32(&mgr.restartCalled))
+		}
+	})
+
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:              "test",
+		ContainerName:      "test-svc",
+		HealthURL:          srv.URL,
+		CheckInterval:      10 * time.Millisecond,
+		MaxFailures:        2,
+		MaxRestartAttempts: 3,
+		RecoveryBackoff:    20 * time.Millisecond,
+		RecoveryMaxBackoff: 40 * time.Millisecond,
+	}, emitter, quietLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ao.Start(ctx)
+	defer cancel()
+
+	deadline := time.After(3 * time.Second)
+	for ao.State() != "degraded" {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for degraded, state = %q", ao.State())
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if n := atomic.LoadInt32(&restartsAtExhaustion); n != 3 {
+		t.Errorf("restartCalled = %d, want 3", n)
+	}
+	if atomic.LoadInt32(&exhausted) != 1 {
+		t.Errorf("RestartExhausted events = %d, want 1", atomic.LoadInt32(&exhausted))
+	}
+	st := ao.Restarts()
+	if st.Count < 3 || st.LastRestart == nil {
+		t.Errorf("Restarts() = %+v, want count of at least 3 with last restart", st)
+	}
+}
+
+func TestAlwaysOnRestartBudgetClearedWhenReady(t *testing.T) {
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if healthy.Load() {
+			w.WriteHeader(200)
+		} else {
+			w.WriteHeader(500)
+		}
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "running"}
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:              "test",
+		ContainerName:      "test-svc",
+		HealthURL:          srv.URL,
+		CheckInterval:      10 * time.Millisecond,
+		MaxFailures:        2,
+		MaxRestartAttempts: 3,
+		RecoveryBackoff:    time.Hour,
+	}, events.NewEmitter(quietLogger()), quietLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ao.Start(ctx)
+	defer cancel()
+
+	deadline := time.After(2 * time.Second)
+	for atomic.LoadInt32(&mgr.restartCalled) < 1 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for restart")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	healthy.Store(true)
+	for ao.State() != "ready" {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for ready")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	st := ao.Restarts()
+	if st.Count != 1 || st.Attempts != 0 {
+		t.Errorf("Restarts() = %+v, want count 1 and attempts reset", st)
+	}
+}
+
+type probeFunc func(ctx context.Context) error
+
+func (f probeFunc) Probe(ctx context.Context) error { return f(ctx) }
+
+func TestAlwaysOnUsesConfiguredProbe(t *testing.T) {
+	var probes int32
+	ao := NewAlwaysOn(nil, AlwaysOnConfig{
+		Agent:         "test",
+		HealthURL:     "http://127.0.0.1:1", // unreachable; the probe takes precedence
+		CheckInterval: 10 * time.Millisecond,
+		MaxFailures:   3,
+		Probe: probeFunc(func(context.Context) error {
+			atomic.AddInt32(&probes, 1)
+			return nil
+		}),
+	}, events.NewEmitter(quietLogger()), quietLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ao.Start(ctx)
+	defer cancel()
+
+	deadline := time.After(2 * time.Second)
+	for ao.State() != "ready" {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for ready")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if atomic.LoadInt32(&probes) < 1 {
+		t.Error("expected configured probe to be used")
+	}
+}
@@ -0,0 +1,355 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"warren/internal/container"
+	"warren/internal/events"
+)
+
+// Scaler adjusts the replica count of a running service.
+type Scaler interface {
+	Scale(ctx context.Context, name string, replicas uint64) error
+	Replicas(ctx context.Context, name string) (uint64, error)
+}
+
+// RequestSource provides cumulative request counts per hostname.
+type RequestSource interface {
+	Requests(hostname string) uint64
+}
+
+type AutoscaleConfig struct {
+	Agent             string
+	ContainerName     string
+	HealthURL         string
+	Probe             container.Prober // liveness; defaults to an HTTP GET of HealthURL
+	ReadinessProbe    container.Prober // optional; failing takes the agent out of routing
+	Hostname          string
+	Hostnames         []string      // the agent's other route keys; load on all of them counts
+	CheckInterval     time.Duration // health check interval
+	MaxFailures       int
+	Interval          time.Duration // how often load is sampled and replicas evaluated
+	MinReplicas       int
+	MaxReplicas       int
+	TargetRPS         float64 // requests/sec per replica, 0 = not used
+	TargetConnections int     // WebSocket connections per replica, 0 = not used
+	Tolerance         float64 // load within ±Tolerance of the target doesn't trigger scaling
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+}
+
+// Autoscale keeps a swarm service running and sets its replicas between a
+// minimum and maximum from proxied request rate and open WebSocket
+// connections.
+type Autoscale struct {
+	agent, containerName    string
+	hostnames               []string // every route key the agent's load is counted under
+	probe, readinessProbe   container.Prober
+	checkInterval, interval time.Duration
+	maxFailures             int
+
+	scaler   Scaler
+	requests RequestSource
+	ws       WSSource
+	emitter  *events.Emitter
+
+	mu                sync.RWMutex
+	state             string
+	failures          int
+	notify            stateBroadcast
+	minReplicas       int
+	maxReplicas       int
+	targetRPS         float64
+	targetConnections int
+	tolerance         float64
+	scaleUpCooldown   time.Duration
+	scaleDownCooldown time.Duration
+	replicas          int
+	lastScale         time.Time
+	lastRequests      uint64
+	lastSample        time.Time
+
+	now    func() time.Time
+	logger *slog.Logger
+}
+
+func NewAutoscale(scaler Scaler, cfg AutoscaleConfig, requests RequestSource, ws WSSource, emitter *events.Emitter, logger *slog.Logger) *Autoscale {
+	return &Autoscale{
+		agent:             cfg.Agent,
+		containerName:     cfg.ContainerName,
+		probe:             probeFor(cfg.Probe, cfg.HealthURL),
+		readinessProbe:    cfg.ReadinessProbe,
+		hostnames:         append([]string{cfg.Hostname}, cfg.Hostnames...),
+		checkInterval:     cfg.CheckInterval,
+		interval:          cfg.Interval,
+		maxFailures:       cfg.MaxFailures,
+		scaler:            scaler,
+		requests:          requests,
+		ws:                ws,
+		emitter:           emitter,
+		state:             "starting",
+		minReplicas:       cfg.MinReplicas,
+		maxReplicas:       cfg.MaxReplicas,
+		targetRPS:         cfg.TargetRPS,
+		targetConnections: cfg.TargetConnections,
+		tolerance:         cfg.Tolerance,
+		scaleUpCooldown:   cfg.ScaleUpCooldown,
+		scaleDownCooldown: cfg.ScaleDownCooldown,
+		now:               time.Now,
+		logger:            logger.With("agent", cfg.Agent, "policy", "autoscale"),
+	}
+}
+
+func (a *Autoscale) Start(ctx context.Context) {
+	a.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: a.agent})
+	a.initReplicas(ctx)
+
+	healthTicker := time.NewTicker(a.checkInterval)
+	defer healthTicker.Stop()
+	scaleTimer := time.NewTimer(a.evaluateInterval())
+	defer scaleTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-healthTicker.C:
+			a.checkHealth(ctx)
+		case <-scaleTimer.C:
+			a.evaluate(ctx)
+			scaleTimer.Reset(a.evaluateInterval())
+		}
+	}
+}
+
+// evaluateInterval returns how long to wait before the next evaluation.
+func (a *Autoscale) evaluateInterval() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.interval
+}
+
+func (a *Autoscale) State() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.state
+}
+
+func (a *Autoscale) OnRequest() {}
+
+// StateChanged returns a channel that is closed on the next state transition.
+func (a *Autoscale) StateChanged() <-chan struct{} {
+	return a.notify.wait()
+}
+
+// Replicas returns the replica count last set (or observed) by the policy.
+func (a *Autoscale) Replicas() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.replicas
+}
+
+// Reconfigure updates the scaling settings from cfg: bounds, targets,
+// tolerance, cooldowns, interval and, if Hostname is set, the routes load is
+// counted on. The other fields are ignored. The new settings are applied on
+// the next evaluation, and the new interval after it.
+func (a *Autoscale) Reconfigure(cfg AutoscaleConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if cfg.Hostname != "" {
+		hostnames := append([]string{cfg.Hostname}, cfg.Hostnames...)
+		if !slices.Equal(hostnames, a.hostnames) {
+			// Requests on the new routes are counted from here.
+			a.hostnames = hostnames
+			a.lastRequests, _ = a.loadOf(hostnames)
+		}
+	}
+	a.minReplicas = cfg.MinReplicas
+	a.maxReplicas = cfg.MaxReplicas
+	a.targetRPS = cfg.TargetRPS
+	a.targetConnections = cfg.TargetConnections
+	a.tolerance = cfg.Tolerance
+	a.scaleUpCooldown = cfg.ScaleUpCooldown
+	a.scaleDownCooldown = cfg.ScaleDownCooldown
+	if cfg.Interval > 0 {
+		a.interval = cfg.Interval
+	}
+	a.logger.Info("reconfigured", "min", cfg.MinReplicas, "max", cfg.MaxReplicas, "target_rps", cfg.TargetRPS, "target_connections", cfg.TargetConnections,
+		"tolerance", cfg.Tolerance, "scale_up_cooldown", cfg.ScaleUpCooldown, "scale_down_cooldown", cfg.ScaleDownCooldown, "interval", a.interval)
+}
+
+// load returns the requests and WebSocket connections seen across all of
+// the agent's routes.
+func (a *Autoscale) load() (requests uint64, conns int64) {
+	a.mu.RLock()
+	hostnames := a.hostnames
+	a.mu.RUnlock()
+	return a.loadOf(hostnames)
+}
+
+// loadOf returns the requests and WebSocket connections seen on hostnames.
+func (a *Autoscale) loadOf(hostnames []string) (requests uint64, conns int64) {
+	for _, h := range hostnames {
+		requests += a.requests.Requests(h)
+		conns += a.ws.Count(h)
+	}
+	return requests, conns
+}
+
+// initReplicas reads the current replica count and moves it into [min, max].
+func (a *Autoscale) initReplicas(ctx context.Context) {
+	current, err := a.scaler.Replicas(ctx, a.containerName)
+	if err != nil {
+		a.logger.Error("failed to read replicas", "error", err)
+	}
+
+	total, _ := a.load()
+	a.mu.Lock()
+	a.replicas = int(current)
+	a.lastRequests = total
+	a.lastSample = a.now()
+	want := min(max(a.replicas, a.minReplicas), a.maxReplicas)
+	a.mu.Unlock()
+
+	if want != int(current) {
+		a.scaleTo(ctx, want, "bounds", 0, 0)
+	}
+}
+
+// evaluate samples load since the previous evaluation and scales if the
+// desired replica count differs and the relevant cooldown has passed.
+func (a *Autoscale) evaluate(ctx context.Context) {
+	now := a.now()
+	total, conns := a.load()
+
+	a.mu.Lock()
+	elapsed := now.Sub(a.lastSample)
+	if elapsed <= 0 {
+		a.mu.Unlock()
+		return
+	}
+	rps := float64(total-a.lastRequests) / elapsed.Seconds()
+	a.lastRequests, a.lastSample = total, now
+
+	current := a.replicas
+	desired, reason := a.desiredReplicas(current, rps, conns)
+	sinceScale := now.Sub(a.lastScale)
+	blocked := (desired > current && sinceScale < a.scaleUpCooldown) ||
+		(desired < current && sinceScale < a.scaleDownCooldown)
+	a.mu.Unlock()
+
+	if desired == current {
+		return
+	}
+	if blocked {
+		a.logger.Debug("scaling deferred by cooldown", "from", current, "to", desired, "since_last_scale", sinceScale)
+		return
+	}
+	a.scaleTo(ctx, desired, reason, rps, conns)
+}
+
+// desiredReplicas computes the replica count for the observed load. The
+// busiest signal wins; load within the tolerance band of the target keeps the
+// current count to avoid flapping. Caller must hold a.mu.
+func (a *Autoscale) desiredReplicas(current int, rps float64, conns int64) (int, string) {
+	if current < 1 {
+		current = 1
+	}
+	ratio, reason := 0.0, "idle"
+	if a.targetRPS > 0 {
+		if r := rps / (a.targetRPS * float64(current)); r > ratio {
+			ratio, reason = r, "rps"
+		}
+	}
+	if a.targetConnections > 0 {
+		if r := float64(conns) / float64(a.targetConnections*current); r > ratio {
+			ratio, reason = r, "connections"
+		}
+	}
+
+	desired := current
+	if math.Abs(ratio-1) > a.tolerance {
+		desired = int(math.Ceil(float64(current) * ratio))
+	}
+	return min(max(desired, a.minReplicas), a.maxReplicas), reason
+}
+
+func (a *Autoscale) scaleTo(ctx context.Context, to int, reason string, rps float64, conns int64) {
+	a.mu.RLock()
+	from := a.replicas
+	a.mu.RUnlock()
+
+	if err := a.scaler.Scale(ctx, a.containerName, uint64(to)); err != nil {
+		a.logger.Error("scale failed", "from", from, "to", to, "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	a.replicas = to
+	a.lastScale = a.now()
+	a.mu.Unlock()
+
+	a.logger.Info("scaled", "from", from, "to", to, "reason", reason, "rps", rps, "connections", conns)
+	a.emitter.Emit(events.Event{
+		Type:  events.AgentScaled,
+		Agent: a.agent,
+		Fields: map[string]string{
+			"from":        strconv.Itoa(from),
+			"to":          strconv.Itoa(to),
+			"reason":      reason,
+			"rps":         fmt.Sprintf("%.2f", rps),
+			"connections": strconv.FormatInt(conns, 10),
+		},
+	})
+}
+
+func (a *Autoscale) checkHealth(ctx context.Context) {
+	err := a.probe.Probe(ctx)
+	var readyErr error
+	if err == nil && a.readinessProbe != nil {
+		readyErr = a.readinessProbe.Probe(ctx)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err == nil {
+		state := "ready"
+		if readyErr != nil {
+			state = "unready"
+		}
+		prev := a.state
+		a.state = state
+		a.failures = 0
+		if prev == state {
+			return
+		}
+		a.notify.notify()
+		if readyErr != nil {
+			a.logger.Warn("agent alive but not ready", "error", readyErr)
+			a.emitter.Emit(events.Event{Type: events.AgentUnready, Agent: a.agent, Fields: map[string]string{"error": readyErr.Error()}})
+			return
+		}
+		a.logger.Info("agent became healthy", "state", "ready")
+		a.emitter.Emit(events.Event{Type: events.AgentReady, Agent: a.agent})
+		return
+	}
+
+	a.failures++
+	a.logger.Warn("health check failed", "error", err, "consecutive_failures", a.failures)
+	a.emitter.Emit(events.Event{Type: events.AgentHealthFailed, Agent: a.agent, Fields: map[string]string{"error": err.Error()}})
+
+	if a.failures >= a.maxFailures && a.state != "degraded" {
+		a.logger.Error("agent degraded, max failures reached", "consecutive_failures", a.failures, "max_failures", a.maxFailures)
+		a.emitter.Emit(events.Event{Type: events.AgentDegraded, Agent: a.agent})
+		a.state = "degraded"
+		a.notify.notify()
+	}
+}
@@ -0,0 +1,276 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+type mockScaler struct {
+	mu       sync.Mutex
+	replicas uint64
+	calls    []uint64
+}
+
+func (m *mockScaler) Scale(_ context.Context, _ string, replicas uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replicas = replicas
+	m.calls = append(m.calls, replicas)
+	return nil
+}
+
+func (m *mockScaler) Replicas(_ context.Context, _ string) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.replicas, nil
+}
+
+type mockRequests struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// add counts n requests on the harness's primary hostname.
+func (m *mockRequests) add(n uint64) {
+	m.addTo("test.com", n)
+}
+
+func (m *mockRequests) addTo(hostname string, n uint64) {
+	m.mu.Lock()
+	if m.counts == nil {
+		m.counts = make(map[string]uint64)
+	}
+	m.counts[hostname] += n
+	m.mu.Unlock()
+}
+
+func (m *mockRequests) Requests(hostname string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[hostname]
+}
+
+type autoscaleHarness struct {
+	as       *Autoscale
+	scaler   *mockScaler
+	requests *mockRequests
+	ws       *mockWSSource
+	now      time.Time
+	scaled   []events.Event
+}
+
+func newAutoscaleHarness(t *testing.T, replicas uint64, cfg AutoscaleConfig) *autoscaleHarness {
+	t.Helper()
+	h := &autoscaleHarness{
+		scaler:   &mockScaler{replicas: replicas},
+		requests: &mockRequests{},
+		ws:       &mockWSSource{},
+		now:      time.Unix(1_700_000_000, 0),
+	}
+	emitter := events.NewEmitter(quietLogger())
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentScaled {
+			h.scaled = append(h.scaled, ev)
+		}
+	})
+
+	cfg.Agent = "test"
+	cfg.ContainerName = "test-svc"
+	cfg.Hostname = "test.com"
+	if cfg.MaxReplicas == 0 {
+		cfg.MaxReplicas = 10
+	}
+	if cfg.MinReplicas == 0 {
+		cfg.MinReplicas = 1
+	}
+	h.as = NewAutoscale(h.scaler, cfg, h.requests, h.ws, emitter, quietLogger())
+	h.as.now = func() time.Time { return h.now }
+	h.as.initReplicas(context.Background())
+	return h
+}
+
+// tick advances the clock by d and runs one evaluation.
+func (h *autoscaleHarness) tick(d time.Duration) {
+	h.now = h.now.Add(d)
+	h.as.evaluate(context.Background())
+}
+
+func TestAutoscaleScalesUpOnRequestRate(t *testing.T) {
+	h := newAutoscaleHarness(t, 1, AutoscaleConfig{TargetRPS: 10, Tolerance: 0.1})
+
+	// 350 requests over 10s = 35 rps against a target of 10 per replica.
+	h.requests.add(350)
+	h.tick(10 * time.Second)
+
+	if got := h.as.Replicas(); got != 4 {
+		t.Fatalf("replicas = %d, want 4", got)
+	}
+	if len(h.scaled) != 1 {
+		t.Fatalf("scaled events = %d, want 1", len(h.scaled))
+	}
+	ev := h.scaled[0]
+	if ev.Fields["from"] != "1" || ev.Fields["to"] != "4" || ev.Fields["reason"] != "rps" {
+		t.Errorf("event fields = %v", ev.Fields)
+	}
+}
+
+func TestAutoscaleScalesUpOnConnections(t *testing.T) {
+	h := newAutoscaleHarness(t, 2, AutoscaleConfig{TargetConnections: 50, Tolerance: 0.1})
+
+	h.ws.count = 250
+	h.tick(10 * time.Second)
+
+	if got := h.as.Replicas(); got != 5 {
+		t.Fatalf("replicas = %d, want 5", got)
+	}
+	if reason := h.scaled[0].Fields["reason"]; reason != "connections" {
+		t.Errorf("reason = %q, want connections", reason)
+	}
+}
+
+func TestAutoscaleToleranceBand(t *testing.T) {
+	h := newAutoscaleHarness(t, 2, AutoscaleConfig{TargetRPS: 10, Tolerance: 0.1})
+
+	// 21 rps across 2 replicas is 5% over target: within tolerance.
+	h.requests.add(210)
+	h.tick(10 * time.Second)
+
+	if got := h.as.Replicas(); got != 2 {
+		t.Errorf("replicas = %d, want 2 (within tolerance)", got)
+	}
+	if len(h.scaled) != 0 {
+		t.Errorf("scaled events = %d, want 0", len(h.scaled))
+	}
+}
+
+func TestAutoscaleCooldowns(t *testing.T) {
+	h := newAutoscaleHarness(t, 1, AutoscaleConfig{
+		TargetRPS:         10,
+		Tolerance:         0.1,
+		ScaleUpCooldown:   30 * time.Second,
+		ScaleDownCooldown: 5 * time.Minute,
+	})
+
+	h.requests.add(300)
+	h.tick(10 * time.Second) // 30 rps -> 3 replicas
+	if got := h.as.Replicas(); got != 3 {
+		t.Fatalf("replicas = %d, want 3", got)
+	}
+
+	h.requests.add(600)
+	h.tick(10 * time.Second) // 60 rps wants 6, but up-cooldown blocks
+	if got := h.as.Replicas(); got != 3 {
+		t.Fatalf("replicas = %d, want 3 during scale-up cooldown", got)
+	}
+
+	h.requests.add(1800)
+	h.tick(30 * time.Second) // 60 rps, cooldown passed
+	if got := h.as.Replicas(); got != 6 {
+		t.Fatalf("replicas = %d, want 6", got)
+	}
+
+	h.tick(time.Minute) // idle wants min, but down-cooldown blocks
+	if got := h.as.Replicas(); got != 6 {
+		t.Fatalf("replicas = %d, want 6 during scale-down cooldown", got)
+	}
+
+	h.tick(5 * time.Minute)
+	if got := h.as.Replicas(); got != 1 {
+		t.Fatalf("replicas = %d, want 1 after scale-down cooldown", got)
+	}
+	if reason := h.scaled[len(h.scaled)-1].Fields["reason"]; reason != "idle" {
+		t.Errorf("reason = %q, want idle", reason)
+	}
+}
+
+func TestAutoscaleClampsToBounds(t *testing.T) {
+	h := newAutoscaleHarness(t, 2, AutoscaleConfig{MinReplicas: 2, MaxReplicas: 4, TargetRPS: 1})
+
+	h.requests.add(1000)
+	h.tick(10 * time.Second)
+	if got := h.as.Replicas(); got != 4 {
+		t.Errorf("replicas = %d, want max 4", got)
+	}
+
+	h.tick(10 * time.Second)
+	if got := h.as.Replicas(); got != 2 {
+		t.Errorf("replicas = %d, want min 2", got)
+	}
+}
+
+func TestAutoscaleInitReplicasBounds(t *testing.T) {
+	h := newAutoscaleHarness(t, 0, AutoscaleConfig{MinReplicas: 2, MaxReplicas: 5, TargetRPS: 10})
+	if got := h.as.Replicas(); got != 2 {
+		t.Errorf("replicas = %d, want 2 (raised to min)", got)
+	}
+
+	h = newAutoscaleHarness(t, 8, AutoscaleConfig{MinReplicas: 2, MaxReplicas: 5, TargetRPS: 10})
+	if got := h.as.Replicas(); got != 5 {
+		t.Errorf("replicas = %d, want 5 (lowered to max)", got)
+	}
+
+	h = newAutoscaleHarness(t, 3, AutoscaleConfig{MinReplicas: 2, MaxReplicas: 5, TargetRPS: 10})
+	if len(h.scaler.calls) != 0 {
+		t.Errorf("scale calls = %v, want none when within bounds", h.scaler.calls)
+	}
+}
+
+func TestAutoscaleReconfigure(t *testing.T) {
+	h := newAutoscaleHarness(t, 1, AutoscaleConfig{MaxReplicas: 10, TargetRPS: 10})
+
+	h.as.Reconfigure(AutoscaleConfig{MinReplicas: 1, MaxReplicas: 2, TargetRPS: 10, Interval: time.Minute})
+	h.requests.add(1000)
+	h.tick(10 * time.Second)
+
+	if got := h.as.Replicas(); got != 2 {
+		t.Errorf("replicas = %d, want 2 after lowering max", got)
+	}
+	if got := h.as.evaluateInterval(); got != time.Minute {
+		t.Errorf("interval = %v, want 1m", got)
+	}
+}
+
+func TestAutoscaleReconfigureToleranceAndCooldowns(t *testing.T) {
+	h := newAutoscaleHarness(t, 2, AutoscaleConfig{TargetRPS: 10})
+
+	h.as.Reconfigure(AutoscaleConfig{MinReplicas: 1, MaxReplicas: 10, TargetRPS: 10, Tolerance: 0.1, ScaleUpCooldown: time.Hour})
+	h.requests.add(210)
+	h.tick(10 * time.Second) // 21 rps on 2 replicas is within the new tolerance
+	if got := h.as.Replicas(); got != 2 {
+		t.Fatalf("replicas = %d, want 2 (within tolerance)", got)
+	}
+
+	h.requests.add(400)
+	h.tick(10 * time.Second) // 40 rps wants 4
+	if got := h.as.Replicas(); got != 4 {
+		t.Fatalf("replicas = %d, want 4", got)
+	}
+	h.requests.add(800)
+	h.tick(10 * time.Second) // 80 rps wants 8, but the new up-cooldown blocks
+	if got := h.as.Replicas(); got != 4 {
+		t.Errorf("replicas = %d, want 4 during scale-up cooldown", got)
+	}
+}
+
+func TestAutoscaleCountsAllHostnames(t *testing.T) {
+	h := newAutoscaleHarness(t, 1, AutoscaleConfig{
+		TargetRPS: 10,
+		Tolerance: 0.1,
+		Hostnames: []string{"alias.com", "other.com/api"},
+	})
+
+	// 10 rps on each of three routes is 30 rps for the agent.
+	h.requests.add(100)
+	h.requests.addTo("alias.com", 100)
+	h.requests.addTo("other.com/api", 100)
+	h.requests.addTo("unrelated.com", 1000)
+	h.tick(10 * time.Second)
+
+	if got := h.as.Replicas(); got != 3 {
+		t.Errorf("replicas = %d, want 3", got)
+	}
+}
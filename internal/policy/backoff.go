@@ -0,0 +1,34 @@
+package policy
+
+import "time"
+
+// backoff produces exponentially growing delays, doubling from initial up to max.
+type backoff struct {
+	initial, max time.Duration
+	current      time.Duration
+}
+
+func newBackoff(initial, max time.Duration) *backoff {
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if max < initial {
+		max = initial
+	}
+	return &backoff{initial: initial, max: max}
+}
+
+// next returns the delay before the next attempt.
+func (b *backoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.initial
+	} else {
+		b.current = min(b.current*2, b.max)
+	}
+	return b.current
+}
+
+// reset starts the sequence again from initial.
+func (b *backoff) reset() {
+	b.current = 0
+}
@@ -0,0 +1,174 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// Dependencies tracks the depends_on graph between agents. Waking an
+// on-demand agent first wakes the agents it depends on and waits for them to
+// be ready, and an agent is not put to sleep for idleness or LRU eviction
+// while anything that depends on it is up.
+type Dependencies struct {
+	mu        sync.RWMutex
+	policies  map[string]Policy
+	dependsOn map[string][]string
+	logger    *slog.Logger
+}
+
+// DependencyNode is one agent in a rendered dependency tree.
+type DependencyNode struct {
+	Name      string           `json:"name"`
+	State     string           `json:"state"`
+	DependsOn []DependencyNode `json:"depends_on,omitempty"`
+}
+
+// NewDependencies creates an empty dependency graph.
+func NewDependencies(logger *slog.Logger) *Dependencies {
+	return &Dependencies{
+		policies:  make(map[string]Policy),
+		dependsOn: make(map[string][]string),
+		logger:    logger.With("component", "dependencies"),
+	}
+}
+
+// Register records an agent's policy and the agents it depends on, replacing
+// any earlier registration under name.
+func (d *Dependencies) Register(name string, pol Policy, dependsOn []string) {
+	d.mu.Lock()
+	d.policies[name] = pol
+	d.dependsOn[name] = slices.Clone(dependsOn)
+	d.mu.Unlock()
+
+	if od, ok := pol.(*OnDemand); ok {
+		od.setDependencies(d)
+	}
+}
+
+// Unregister forgets an agent. Agents that still list it in depends_on will
+// fail to wake until it is registered again.
+func (d *Dependencies) Unregister(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.policies, name)
+	delete(d.dependsOn, name)
+}
+
+// DependsOn returns the agents name depends on directly.
+func (d *Dependencies) DependsOn(name string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return slices.Clone(d.dependsOn[name])
+}
+
+// Dependents returns the agents that depend directly on name.
+func (d *Dependencies) Dependents(name string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var dependents []string
+	for dependent, deps := range d.dependsOn {
+		if slices.Contains(deps, name) {
+			dependents = append(dependents, dependent)
+		}
+	}
+	slices.Sort(dependents)
+	return dependents
+}
+
+// AwakeDependents returns the agents that depend directly on name and are
+// starting or serving. While any are returned, name must stay up. Draining
+// and degraded dependents don't count, or a dependency could never sleep
+// while its dependent sat degraded.
+func (d *Dependencies) AwakeDependents(name string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var awake []string
+	for dependent, deps := range d.dependsOn {
+		if !slices.Contains(deps, name) {
+			continue
+		}
+		pol, ok := d.policies[dependent]
+		if !ok {
+			continue
+		}
+		switch pol.State() {
+		case "starting", "ready", "unready":
+			awake = append(awake, dependent)
+		}
+	}
+	slices.Sort(awake)
+	return awake
+}
+
+// WakeDependencies wakes every agent name depends on and blocks until they
+// are all ready, or ctx is done. Each on-demand dependency wakes its own
+// dependencies in turn before it starts.
+func (d *Dependencies) WakeDependencies(ctx context.Context, name string) error {
+	for _, dep := range d.DependsOn(name) {
+		d.mu.RLock()
+		pol, ok := d.policies[dep]
+		d.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("dependency %s is not configured", dep)
+		}
+		d.logger.Info("waking dependency", "agent", name, "dependency", dep)
+		if err := wakeDependency(ctx, pol, name); err != nil {
+			return fmt.Errorf("dependency %s: %w", dep, err)
+		}
+	}
+	return nil
+}
+
+// wakeDependency wakes pol on behalf of dependent and waits for it to be
+// ready. Policies other than on-demand can't be woken and are only waited on.
+func wakeDependency(ctx context.Context, pol Policy, dependent string) error {
+	if od, ok := pol.(*OnDemand); ok {
+		// A dependency part way through going to sleep has to finish before
+		// it can be woken again.
+		if _, err := WaitForState(ctx, od, "sleeping", "starting", "ready", "unready", "degraded"); err != nil {
+			return err
+		}
+		if err := od.wakeFor(dependent); err != nil {
+			return err
+		}
+	}
+	state, err := WaitForState(ctx, pol, "ready", "degraded")
+	if err != nil {
+		return fmt.Errorf("not ready (%s): %w", state, err)
+	}
+	if state == "degraded" {
+		return errors.New("degraded")
+	}
+	return nil
+}
+
+// Tree renders name and everything it depends on, directly or indirectly,
+// with each agent's current state.
+func (d *Dependencies) Tree(name string) DependencyNode {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.tree(name, nil)
+}
+
+func (d *Dependencies) tree(name string, path []string) DependencyNode {
+	node := DependencyNode{Name: name, State: "unknown"}
+	if pol, ok := d.policies[name]; ok {
+		node.State = pol.State()
+	}
+	// Config validation rejects cycles, but don't recurse forever if one
+	// slips through.
+	if slices.Contains(path, name) {
+		return node
+	}
+	path = append(path, name)
+	for _, dep := range d.dependsOn[name] {
+		node.DependsOn = append(node.DependsOn, d.tree(dep, path))
+	}
+	return node
+}
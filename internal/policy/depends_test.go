@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+func newDependencyAgent(name string, mgr *mockLifecycle, probe *switchProbe, activity *mockActivity) *OnDemand {
+	logger := quietLogger()
+	return NewOnDemand(mgr, OnDemandConfig{
+		Agent:              name,
+		ContainerName:      name + "-svc",
+		Probe:              probe,
+		Hostname:           name + ".com",
+		CheckInterval:      time.Hour,
+		StartupTimeout:     5 * time.Second,
+		StartupInterval:    10 * time.Millisecond,
+		IdleTimeout:        200 * time.Millisecond,
+		MaxFailures:        2,
+		MaxRestartAttempts: 2,
+	}, activity, &mockWSSource{}, events.NewEmitter(logger), logger)
+}
+
+func TestDependenciesWakeBeforeDependent(t *testing.T) {
+	activity := newMockActivity()
+	dbMgr, appMgr := &mockLifecycle{status: "exited"}, &mockLifecycle{status: "exited"}
+	dbProbe := &switchProbe{}
+	db := newDependencyAgent("db", dbMgr, dbProbe, activity)
+	app := newDependencyAgent("app", appMgr, passing(), activity)
+	db.SetInitialState(false)
+	app.SetInitialState(false)
+
+	deps := NewDependencies(quietLogger())
+	deps.Register("db", db, nil)
+	deps.Register("app", app, []string{"db"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Start(ctx)
+	go app.Start(ctx)
+
+	app.Wake()
+	waitForODState(t, db, "starting")
+	waitForODState(t, app, "starting")
+
+	// The dependent waits for its dependency before starting its container.
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&appMgr.startCalled); n != 0 {
+		t.Fatalf("app started %d times before db was ready", n)
+	}
+
+	dbProbe.ok.Store(true)
+	waitForODState(t, app, "ready")
+	if s := db.State(); s != "ready" {
+		t.Errorf("db state = %q, want ready", s)
+	}
+	if n := atomic.LoadInt32(&dbMgr.startCalled); n != 1 {
+		t.Errorf("db started %d times, want 1", n)
+	}
+}
+
+func TestDependenciesFailedWakeSleepsDependent(t *testing.T) {
+	activity := newMockActivity()
+	appMgr := &mockLifecycle{status: "exited"}
+	db := newDependencyAgent("db", &mockLifecycle{status: "exited"}, &switchProbe{}, activity)
+	app := newDependencyAgent("app", appMgr, passing(), activity)
+	app.startupTimeout = 200 * time.Millisecond
+	db.SetInitialState(false)
+	app.SetInitialState(false)
+
+	deps := NewDependencies(quietLogger())
+	deps.Register("db", db, nil)
+	deps.Register("app", app, []string{"db"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Start(ctx)
+	go app.Start(ctx)
+
+	app.Wake()
+	waitForODState(t, app, "starting")
+	waitForODState(t, app, "sleeping")
+	if n := atomic.LoadInt32(&appMgr.startCalled); n != 0 {
+		t.Errorf("app started %d times, want 0 when db never became ready", n)
+	}
+}
+
+func TestDependenciesKeepDependencyAwake(t *testing.T) {
+	activity := newMockActivity()
+	dbMgr := &mockLifecycle{status: "running"}
+	db := newDependencyAgent("db", dbMgr, passing(), activity)
+	app := newDependencyAgent("app", &mockLifecycle{status: "running"}, passing(), activity)
+	app.idleTimeout = time.Hour
+	db.SetInitialState(true)
+	app.SetInitialState(true)
+
+	deps := NewDependencies(quietLogger())
+	deps.Register("db", db, nil)
+	deps.Register("app", app, []string{"db"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Start(ctx)
+	go app.Start(ctx)
+	waitForODState(t, app, "ready")
+	waitForODState(t, db, "ready")
+
+	// db's idle timeout is 200ms; app being awake must hold it up.
+	time.Sleep(500 * time.Millisecond)
+	if s := db.State(); s != "ready" {
+		t.Errorf("db state = %q, want ready while app is awake", s)
+	}
+	if got := deps.AwakeDependents("db"); !reflect.DeepEqual(got, []string{"app"}) {
+		t.Errorf("AwakeDependents = %v, want [app]", got)
+	}
+
+	// Once the dependent sleeps, the dependency's idle timeout applies again.
+	app.Sleep(ctx)
+	waitForODState(t, app, "sleeping")
+	waitForODState(t, db, "sleeping")
+	if atomic.LoadInt32(&dbMgr.stopCalled) != 1 {
+		t.Error("expected db to be stopped after app slept")
+	}
+}
+
+func TestLRUSkipsDependencyWithAwakeDependents(t *testing.T) {
+	activity := newMockActivity()
+	activity.Touch("db.com")
+	time.Sleep(10 * time.Millisecond)
+	activity.Touch("app.com")
+
+	db := makeLRUAgent(t, "db", "db.com", activity, "http://127.0.0.1:1")
+	app := makeLRUAgent(t, "app", "app.com", activity, "http://127.0.0.1:1")
+
+	deps := NewDependencies(quietLogger())
+	deps.Register("db", db, nil)
+	deps.Register("app", app, []string{"db"})
+
+	lru := NewLRUManager(activity, events.NewEmitter(quietLogger()), quietLogger())
+	lru.Register("db", db, EvictionConfig{})
+	lru.Register("app", app, EvictionConfig{})
+
+	// db is least recently used, but app depends on it.
+	if evicted := lru.Evict(context.Background(), "max_ready_agents", ""); evicted != "app" {
+		t.Errorf("evicted = %q, want app", evicted)
+	}
+}
+
+func TestDependenciesTree(t *testing.T) {
+	activity := newMockActivity()
+	deps := NewDependencies(quietLogger())
+	deps.Register("app", newDependencyAgent("app", &mockLifecycle{}, passing(), activity), []string{"api", "cache"})
+	deps.Register("api", NewUnmanaged(), []string{"db"})
+	deps.Register("cache", newDependencyAgent("cache", &mockLifecycle{}, passing(), activity), nil)
+
+	want := DependencyNode{Name: "app", State: "sleeping", DependsOn: []DependencyNode{
+		{Name: "api", State: "ready", DependsOn: []DependencyNode{{Name: "db", State: "unknown"}}},
+		{Name: "cache", State: "sleeping"},
+	}}
+	if got := deps.Tree("app"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tree = %+v, want %+v", got, want)
+	}
+}
+
+func TestAwakeDependentsCountsOnlyLiveStates(t *testing.T) {
+	activity := newMockActivity()
+	app := newDependencyAgent("app", &mockLifecycle{}, passing(), activity)
+	deps := NewDependencies(quietLogger())
+	deps.Register("db", newDependencyAgent("db", &mockLifecycle{}, passing(), activity), nil)
+	deps.Register("app", app, []string{"db"})
+
+	for state, awake := range map[string]bool{
+		"sleeping": false,
+		"starting": true,
+		"ready":    true,
+		"unready":  true,
+		"draining": false,
+		"degraded": false,
+	} {
+		app.setState(state)
+		if got := len(deps.AwakeDependents("db")) > 0; got != awake {
+			t.Errorf("app %s: dependency kept awake = %v, want %v", state, got, awake)
+		}
+	}
+}
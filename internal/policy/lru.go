@@ -3,70 +3,132 @@ package policy
 import (
 	"context"
 	"log/slog"
+	"strconv"
 	"sync"
 	"time"
+
+	"warren/internal/events"
 )
 
+// EvictionConfig controls how an on-demand agent is picked for LRU eviction.
+type EvictionConfig struct {
+	Priority int           // lower priorities are evicted first
+	Pinned   bool          // never evicted
+	MinAwake time.Duration // not evicted until it has been awake this long
+}
+
 // LRUManager tracks on-demand agents and evicts least-recently-used ones.
 type LRUManager struct {
 	mu       sync.RWMutex
-	agents   map[string]*OnDemand
+	agents   map[string]*lruAgent
 	activity ActivitySource
+	emitter  *events.Emitter
 	logger   *slog.Logger
+
+	recheckMu sync.Mutex
+	recheck   *time.Timer // re-runs EvictIfNeeded when a min_awake grace period ends
+}
+
+type lruAgent struct {
+	pol *OnDemand
+	cfg EvictionConfig
 }
 
 // NewLRUManager creates a new LRU eviction manager.
-func NewLRUManager(activity ActivitySource, logger *slog.Logger) *LRUManager {
+func NewLRUManager(activity ActivitySource, emitter *events.Emitter, logger *slog.Logger) *LRUManager {
 	return &LRUManager{
-		agents:   make(map[string]*OnDemand),
+		agents:   make(map[string]*lruAgent),
 		activity: activity,
+		emitter:  emitter,
 		logger:   logger.With("component", "lru-manager"),
 	}
 }
 
-// Register tracks an on-demand agent for LRU eviction.
-func (l *LRUManager) Register(name string, pol *OnDemand, hostname string) {
+// Register tracks an on-demand agent for LRU eviction, replacing any earlier
+// registration under name.
+func (l *LRUManager) Register(name string, pol *OnDemand, cfg EvictionConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.agents[name] = &lruAgent{pol: pol, cfg: cfg}
+}
+
+// Unregister stops tracking an agent.
+func (l *LRUManager) Unregister(name string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.agents[name] = pol
+	delete(l.agents, name)
 }
 
-// Evict finds the least-recently-used ready on-demand agent and puts it to sleep.
-// Returns the name of the evicted agent, or empty string if none eligible.
-func (l *LRUManager) Evict(ctx context.Context) string {
+// Evict puts one awake on-demand agent to sleep and emits agent.evicted with
+// reason and the agent that triggered it. Pinned agents, agents inside their
+// min_awake grace period, agents with open WebSockets and agents that
+// something awake depends on are skipped. Among the rest the lowest priority
+// goes first, then the least recently active. Returns the name of the evicted
+// agent, or empty string if none eligible.
+func (l *LRUManager) Evict(ctx context.Context, reason, trigger string) string {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
 	var (
 		lruName string
 		lruTime time.Time
-		lruPol  *OnDemand
+		lru     *lruAgent
 	)
 
-	for name, pol := range l.agents {
-		if pol.State() != "ready" {
+	now := time.Now()
+	for name, a := range l.agents {
+		pol := a.pol
+		if !awake(pol.State()) || a.cfg.Pinned {
 			continue
 		}
-		last := l.activity.LastActivity(pol.hostname)
-		if lruPol == nil || last.Before(lruTime) {
+		if woke := pol.wokeAt(); !woke.IsZero() && now.Sub(woke) < a.cfg.MinAwake {
+			continue
+		}
+		if pol.ws.Count(pol.routeKey()) > 0 {
+			continue
+		}
+		// Dependencies stay up while anything depending on them is.
+		if len(pol.awakeDependents()) > 0 {
+			continue
+		}
+		last := l.activity.LastActivity(pol.routeKey())
+		if lru == nil || a.cfg.Priority < lru.cfg.Priority ||
+			(a.cfg.Priority == lru.cfg.Priority && last.Before(lruTime)) {
 			lruName = name
 			lruTime = last
-			lruPol = pol
+			lru = a
 		}
 	}
 
-	if lruPol == nil {
+	if lru == nil {
 		return ""
 	}
 
-	l.logger.Info("evicting least-recently-used agent", "agent", lruName, "last_activity", lruTime)
-	lruPol.Sleep(ctx)
+	l.logger.Info("evicting least-recently-used agent", "agent", lruName, "last_activity", lruTime, "priority", lru.cfg.Priority, "reason", reason, "triggered_by", trigger)
+	if !lru.pol.evict(ctx) {
+		// It left the awake states on its own in the meantime.
+		return lruName
+	}
+	fields := map[string]string{
+		"reason":   reason,
+		"priority": strconv.Itoa(lru.cfg.Priority),
+	}
+	if trigger != "" {
+		fields["triggered_by"] = trigger
+	}
+	if !lruTime.IsZero() {
+		fields["last_activity"] = lruTime.UTC().Format(time.RFC3339)
+	}
+	l.emitter.Emit(events.Event{Type: events.AgentEvicted, Agent: lruName, Fields: fields})
 	return lruName
 }
 
-// EvictIfNeeded evicts LRU agents until at most maxReady on-demand agents are awake.
-// If maxReady is 0, no eviction is performed.
-func (l *LRUManager) EvictIfNeeded(ctx context.Context, maxReady int) {
+// EvictIfNeeded evicts LRU agents until at most maxReady on-demand agents are
+// awake. trigger names the agent whose wake pushed the count over. If
+// maxReady is 0, no eviction is performed. If agents are still over the limit
+// because some are inside their min_awake grace period, it runs again when
+// the first of those periods ends.
+func (l *LRUManager) EvictIfNeeded(ctx context.Context, maxReady int, trigger string) {
 	if maxReady <= 0 {
 		return
 	}
@@ -76,22 +138,71 @@ func (l *LRUManager) EvictIfNeeded(ctx context.Context, maxReady int) {
 		if ready <= maxReady {
 			return
 		}
-		evicted := l.Evict(ctx)
+		evicted := l.Evict(ctx, "max_ready_agents", trigger)
 		if evicted == "" {
-			return // no more eligible agents
+			// No more eligible agents for now.
+			if at := l.graceEnds(); !at.IsZero() {
+				l.recheckAt(ctx, at, maxReady, trigger)
+			}
+			return
 		}
 	}
 }
 
+// graceEnds returns when the earliest min_awake grace period of an awake,
+// unpinned agent ends, or the zero time if none is in its grace period.
+func (l *LRUManager) graceEnds() time.Time {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var earliest time.Time
+	now := time.Now()
+	for _, a := range l.agents {
+		if !awake(a.pol.State()) || a.cfg.Pinned || a.cfg.MinAwake <= 0 {
+			continue
+		}
+		woke := a.pol.wokeAt()
+		if woke.IsZero() {
+			continue
+		}
+		if end := woke.Add(a.cfg.MinAwake); end.After(now) && (earliest.IsZero() || end.Before(earliest)) {
+			earliest = end
+		}
+	}
+	return earliest
+}
+
+// recheckAt schedules EvictIfNeeded to run again at t, replacing any earlier
+// schedule.
+func (l *LRUManager) recheckAt(ctx context.Context, t time.Time, maxReady int, trigger string) {
+	l.recheckMu.Lock()
+	defer l.recheckMu.Unlock()
+	if l.recheck != nil {
+		l.recheck.Stop()
+	}
+	l.logger.Info("over max_ready_agents, rechecking when min_awake ends", "at", t)
+	l.recheck = time.AfterFunc(time.Until(t), func() {
+		if ctx.Err() == nil {
+			l.EvictIfNeeded(ctx, maxReady, trigger)
+		}
+	})
+}
+
 func (l *LRUManager) countReady() int {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
 	count := 0
-	for _, pol := range l.agents {
-		if pol.State() == "ready" {
+	for _, a := range l.agents {
+		if awake(a.pol.State()) {
 			count++
 		}
 	}
 	return count
 }
+
+// awake reports whether an on-demand agent in state is up and holding
+// resources, whether or not it is currently passing readiness.
+func awake(state string) bool {
+	return state == "ready" || state == "unready"
+}
@@ -56,11 +56,11 @@ func TestLRUEvictsLeastRecentlyActive(t *testing.T) {
 	agentA := makeLRUAgent(t, "agent-a", "a.com", activity, srv.URL)
 	agentB := makeLRUAgent(t, "agent-b", "b.com", activity, srv.URL)
 
-	lru := NewLRUManager(activity, logger)
-	lru.Register("agent-a", agentA, "a.com")
-	lru.Register("agent-b", agentB, "b.com")
+	lru := NewLRUManager(activity, events.NewEmitter(logger), logger)
+	lru.Register("agent-a", agentA, EvictionConfig{})
+	lru.Register("agent-b", agentB, EvictionConfig{})
 
-	evicted := lru.Evict(context.Background())
+	evicted := lru.Evict(context.Background(), "max_ready_agents", "")
 	if evicted != "agent-a" {
 		t.Errorf("evicted = %q, want agent-a (least recently active)", evicted)
 	}
@@ -85,13 +85,13 @@ func TestLRUEvictIfNeededRespectsThreshold(t *testing.T) {
 	agentB := makeLRUAgent(t, "b", "b.com", activity, srv.URL)
 	agentC := makeLRUAgent(t, "c", "c.com", activity, srv.URL)
 
-	lru := NewLRUManager(activity, logger)
-	lru.Register("a", agentA, "a.com")
-	lru.Register("b", agentB, "b.com")
-	lru.Register("c", agentC, "c.com")
+	lru := NewLRUManager(activity, events.NewEmitter(logger), logger)
+	lru.Register("a", agentA, EvictionConfig{})
+	lru.Register("b", agentB, EvictionConfig{})
+	lru.Register("c", agentC, EvictionConfig{})
 
 	// 3 ready, max 2 → should evict 1
-	lru.EvictIfNeeded(context.Background(), 2)
+	lru.EvictIfNeeded(context.Background(), 2, "")
 
 	ready := 0
 	for _, od := range []*OnDemand{agentA, agentB, agentC} {
@@ -107,8 +107,128 @@ func TestLRUEvictIfNeededRespectsThreshold(t *testing.T) {
 func TestLRUNoEvictionUnderThreshold(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
 	activity := newMockActivity()
-	lru := NewLRUManager(activity, logger)
+	lru := NewLRUManager(activity, events.NewEmitter(logger), logger)
 
 	// No agents registered, maxReady=5 → nothing happens
-	lru.EvictIfNeeded(context.Background(), 5)
+	lru.EvictIfNeeded(context.Background(), 5, "")
+}
+
+func TestLRUEvictsLowestPriorityFirst(t *testing.T) {
+	activity := newMockActivity()
+	activity.Touch("a.com")
+	time.Sleep(10 * time.Millisecond)
+	activity.Touch("b.com")
+
+	agentA := makeLRUAgent(t, "agent-a", "a.com", activity, "http://127.0.0.1:1")
+	agentB := makeLRUAgent(t, "agent-b", "b.com", activity, "http://127.0.0.1:1")
+
+	lru := NewLRUManager(activity, events.NewEmitter(quietLogger()), quietLogger())
+	lru.Register("agent-a", agentA, EvictionConfig{Priority: 10})
+	lru.Register("agent-b", agentB, EvictionConfig{})
+
+	// agent-a is older but outranks agent-b.
+	if evicted := lru.Evict(context.Background(), "max_ready_agents", ""); evicted != "agent-b" {
+		t.Errorf("evicted = %q, want agent-b (lowest priority)", evicted)
+	}
+}
+
+func TestLRUSkipsProtectedAgents(t *testing.T) {
+	activity := newMockActivity()
+	for _, h := range []string{"pinned.com", "fresh.com", "busy.com", "idle.com"} {
+		activity.Touch(h)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	pinned := makeLRUAgent(t, "pinned", "pinned.com", activity, "http://127.0.0.1:1")
+	fresh := makeLRUAgent(t, "fresh", "fresh.com", activity, "http://127.0.0.1:1")
+	fresh.lastWakeTime = time.Now()
+	busy := makeLRUAgent(t, "busy", "busy.com", activity, "http://127.0.0.1:1")
+	busy.ws.(*mockWSSource).count = 1
+	idle := makeLRUAgent(t, "idle", "idle.com", activity, "http://127.0.0.1:1")
+
+	lru := NewLRUManager(activity, events.NewEmitter(quietLogger()), quietLogger())
+	lru.Register("pinned", pinned, EvictionConfig{Pinned: true})
+	lru.Register("fresh", fresh, EvictionConfig{MinAwake: time.Hour})
+	lru.Register("busy", busy, EvictionConfig{})
+	lru.Register("idle", idle, EvictionConfig{})
+
+	if evicted := lru.Evict(context.Background(), "max_ready_agents", ""); evicted != "idle" {
+		t.Fatalf("evicted = %q, want idle", evicted)
+	}
+	if evicted := lru.Evict(context.Background(), "max_ready_agents", ""); evicted != "" {
+		t.Errorf("evicted = %q, want nothing eligible", evicted)
+	}
+}
+
+func TestLRUEmitsEvictedEvent(t *testing.T) {
+	activity := newMockActivity()
+	activity.Touch("a.com")
+	time.Sleep(10 * time.Millisecond)
+	activity.Touch("b.com")
+
+	agentA := makeLRUAgent(t, "agent-a", "a.com", activity, "http://127.0.0.1:1")
+	agentB := makeLRUAgent(t, "agent-b", "b.com", activity, "http://127.0.0.1:1")
+
+	emitter := events.NewEmitter(quietLogger())
+	var evicted []events.Event
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentEvicted {
+			evicted = append(evicted, ev)
+		}
+	})
+
+	lru := NewLRUManager(activity, emitter, quietLogger())
+	lru.Register("agent-a", agentA, EvictionConfig{})
+	lru.Register("agent-b", agentB, EvictionConfig{})
+	lru.EvictIfNeeded(context.Background(), 1, "agent-b")
+
+	if len(evicted) != 1 {
+		t.Fatalf("got %d agent.evicted events, want 1", len(evicted))
+	}
+	ev := evicted[0]
+	if ev.Agent != "agent-a" || ev.Fields["reason"] != "max_ready_agents" || ev.Fields["triggered_by"] != "agent-b" {
+		t.Errorf("event = %+v", ev)
+	}
+	if s := agentA.State(); s != "draining" && s != "sleeping" {
+		t.Errorf("agent-a state = %q, want draining or sleeping", s)
+	}
+}
+
+func TestLRURechecksWhenMinAwakeEnds(t *testing.T) {
+	activity := newMockActivity()
+	activity.Touch("a.com")
+	time.Sleep(10 * time.Millisecond)
+	activity.Touch("b.com")
+
+	agentA := makeLRUAgent(t, "agent-a", "a.com", activity, "http://127.0.0.1:1")
+	agentB := makeLRUAgent(t, "agent-b", "b.com", activity, "http://127.0.0.1:1")
+	for _, od := range []*OnDemand{agentA, agentB} {
+		od.mu.Lock()
+		od.lastWakeTime = time.Now()
+		od.mu.Unlock()
+	}
+
+	lru := NewLRUManager(activity, events.NewEmitter(quietLogger()), quietLogger())
+	lru.Register("agent-a", agentA, EvictionConfig{MinAwake: 100 * time.Millisecond})
+	lru.Register("agent-b", agentB, EvictionConfig{MinAwake: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lru.EvictIfNeeded(ctx, 1, "agent-b")
+	if lru.countReady() != 2 {
+		t.Fatal("agents inside min_awake should not be evicted yet")
+	}
+
+	deadline := time.After(5 * time.Second)
+	for lru.countReady() != 1 {
+		select {
+		case <-deadline:
+			t.Fatal("no eviction after min_awake ended")
+		default:
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if s := agentA.State(); awake(s) {
+		t.Errorf("agent-a state = %q, want the least recently used agent evicted", s)
+	}
 }
@@ -0,0 +1,71 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrNoNotifier is returned by WaitForState when the policy cannot signal
+// state transitions.
+var ErrNoNotifier = errors.New("policy does not support state notifications")
+
+// StateNotifier is implemented by policies that can signal state transitions,
+// so callers can wait for a state instead of polling State().
+type StateNotifier interface {
+	// StateChanged returns a channel that is closed on the next state transition.
+	StateChanged() <-chan struct{}
+}
+
+// stateBroadcast wakes every waiter on a state transition by closing the
+// current channel and replacing it with a fresh one.
+type stateBroadcast struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func (b *stateBroadcast) wait() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ch == nil {
+		b.ch = make(chan struct{})
+	}
+	return b.ch
+}
+
+func (b *stateBroadcast) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ch != nil {
+		close(b.ch)
+	}
+	b.ch = make(chan struct{})
+}
+
+// WaitForState blocks until pol reports one of the wanted states or ctx is done.
+// It returns the last observed state. Policies that don't implement
+// StateNotifier return ErrNoNotifier unless they are already in a wanted state.
+func WaitForState(ctx context.Context, pol Policy, want ...string) (string, error) {
+	n, ok := pol.(StateNotifier)
+	for {
+		// Grab the channel before reading the state so a transition between
+		// the two calls is not missed.
+		var changed <-chan struct{}
+		if ok {
+			changed = n.StateChanged()
+		}
+		state := pol.State()
+		if slices.Contains(want, state) {
+			return state, nil
+		}
+		if !ok {
+			return state, ErrNoNotifier
+		}
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case <-changed:
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForStateReturnsOnTransition(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+
+	done := make(chan string, 1)
+	go func() {
+		state, err := WaitForState(context.Background(), od, "ready")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		done <- state
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	od.setState("starting")
+	od.setState("ready")
+
+	select {
+	case state := <-done:
+		if state != "ready" {
+			t.Errorf("state = %q, want ready", state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitForState did not return after transition to ready")
+	}
+}
+
+func TestWaitForStateContextTimeout(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	state, err := WaitForState(ctx, od, "ready")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if state != "sleeping" {
+		t.Errorf("state = %q, want sleeping", state)
+	}
+}
+
+func TestWaitForStateWithoutNotifier(t *testing.T) {
+	// Unmanaged is always ready, so it satisfies "ready" immediately...
+	if _, err := WaitForState(context.Background(), NewUnmanaged(), "ready"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// ...but can't be waited on for anything else.
+	if _, err := WaitForState(context.Background(), NewUnmanaged(), "sleeping"); !errors.Is(err, ErrNoNotifier) {
+		t.Errorf("err = %v, want ErrNoNotifier", err)
+	}
+}
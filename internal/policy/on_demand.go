@@ -4,13 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"warren/internal/container"
 	"warren/internal/events"
 )
 
+// drainPollInterval is how often open WebSockets are counted while draining.
+const drainPollInterval = 100 * time.Millisecond
+
 // ActivitySource provides last-activity timestamps per hostname.
 type ActivitySource interface {
 	Touch(hostname string)
@@ -26,30 +32,54 @@ type OnDemandConfig struct {
 	Agent              string
 	ContainerName      string
 	HealthURL          string
+	Probe              container.Prober // liveness; defaults to an HTTP GET of HealthURL
+	StartupProbe       container.Prober // decides when a woken agent is up; defaults to Probe
+	ReadinessProbe     container.Prober // optional; failing takes the agent out of routing
 	Hostname           string
 	CheckInterval      time.Duration
 	StartupTimeout     time.Duration
+	StartupInterval    time.Duration // how often the startup probe polls (default 2s)
 	IdleTimeout        time.Duration
 	WakeCooldown       time.Duration
+	DrainTimeout       time.Duration // max wait for WebSockets to close before stopping
 	MaxFailures        int
 	MaxRestartAttempts int
+	RecoveryBackoff    time.Duration // first delay between recovery attempts while degraded
+	RecoveryMaxBackoff time.Duration // cap for the doubling recovery delay
+	Schedule           *Schedule     // optional wake/keep-awake/quiet-hours rules
 }
 
 type OnDemand struct {
-	agent, containerName, healthURL, hostname string
+	agent, containerName                                     string
+	probe, startupProbe, readinessProbe                      container.Prober
 	startupTimeout, idleTimeout, checkInterval, wakeCooldown time.Duration
-	maxFailures, maxRestartAttempts                           int
+	startupInterval                                          time.Duration
+	drainTimeout                                             time.Duration
+	recoveryBackoff, recoveryMaxBackoff                      time.Duration
+	maxFailures, maxRestartAttempts                          int
 
 	manager  container.Lifecycle
 	activity ActivitySource
 	ws       WSSource
 	emitter  *events.Emitter
+	hostname atomic.Pointer[string] // route key activity and connections are tracked under
 
 	mu            sync.RWMutex
-	state         string        // "sleeping", "starting", "ready", "degraded"
+	state         string        // "sleeping", "starting", "ready", "unready", "draining", "degraded"
+	stateSince    time.Time     // when state last changed
 	initialState  *bool         // set by SetInitialState before Start
 	lastSleepTime time.Time     // tracks when agent last went to sleep
+	lastWakeTime  time.Time     // when the last wake signal was acted on
+	resuming      bool          // found running at startup; keep restored activity
+	recovering    bool          // starting after a recovery restart
+	recovery      *backoff      // recovery delays; kept across degraded spells until the agent stays healthy
+	waking        bool          // waitForWake is starting the container
 	wakeCh        chan struct{} // buffered(1), signals wake request
+	notify        stateBroadcast
+	schedule      *Schedule
+	scheduleNote  stateBroadcast // signalled when the schedule is replaced
+	deps          *Dependencies  // set when the agent is registered in a dependency graph
+	gate          WakeGate       // optional veto on wakes, e.g. for host memory
 
 	// OnReady is called after the agent becomes ready. Used for briefing injection.
 	OnReady func(ctx context.Context, agentID string, lastSleepTime time.Time)
@@ -58,25 +88,92 @@ type OnDemand struct {
 }
 
 func NewOnDemand(mgr container.Lifecycle, cfg OnDemandConfig, activity ActivitySource, ws WSSource, emitter *events.Emitter, logger *slog.Logger) *OnDemand {
-	return &OnDemand{
+	probe := probeFor(cfg.Probe, cfg.HealthURL)
+	startupProbe := cfg.StartupProbe
+	if startupProbe == nil {
+		startupProbe = probe
+	}
+	startupInterval := cfg.StartupInterval
+	if startupInterval <= 0 {
+		startupInterval = 2 * time.Second
+	}
+	o := &OnDemand{
 		agent:              cfg.Agent,
 		containerName:      cfg.ContainerName,
-		healthURL:          cfg.HealthURL,
-		hostname:           cfg.Hostname,
+		probe:              probe,
+		startupProbe:       startupProbe,
+		readinessProbe:     cfg.ReadinessProbe,
 		startupTimeout:     cfg.StartupTimeout,
+		startupInterval:    startupInterval,
 		idleTimeout:        cfg.IdleTimeout,
 		checkInterval:      cfg.CheckInterval,
 		wakeCooldown:       cfg.WakeCooldown,
+		drainTimeout:       cfg.DrainTimeout,
+		recoveryBackoff:    cfg.RecoveryBackoff,
+		recoveryMaxBackoff: cfg.RecoveryMaxBackoff,
 		maxFailures:        cfg.MaxFailures,
 		maxRestartAttempts: cfg.MaxRestartAttempts,
 		manager:            mgr,
 		activity:           activity,
 		ws:                 ws,
 		emitter:            emitter,
+		schedule:           cfg.Schedule,
 		state:              "sleeping", // will be resolved in Start
 		wakeCh:             make(chan struct{}, 1),
 		logger:             logger.With("agent", cfg.Agent, "policy", "on-demand"),
 	}
+	o.hostname.Store(&cfg.Hostname)
+	return o
+}
+
+// routeKey returns the route key the agent's activity and connections are
+// tracked under.
+func (o *OnDemand) routeKey() string {
+	return *o.hostname.Load()
+}
+
+// SetHostname moves the agent's activity and connection tracking to a new
+// route key when its routes change on reload. An awake agent's new route is
+// touched so the move doesn't look like idleness.
+func (o *OnDemand) SetHostname(routeKey string) {
+	if *o.hostname.Swap(&routeKey) == routeKey || o.State() == "sleeping" {
+		return
+	}
+	o.activity.Touch(routeKey)
+}
+
+// RuntimeState returns the state to checkpoint across orchestrator restarts.
+func (o *OnDemand) RuntimeState() RuntimeState {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return RuntimeState{LastSleep: o.lastSleepTime}
+}
+
+// RestoreRuntimeState restores checkpointed state. Call it before Start.
+func (o *OnDemand) RestoreRuntimeState(st RuntimeState) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lastSleepTime = st.LastSleep
+}
+
+// resume settles the initial state for a container found running at startup.
+func (o *OnDemand) resume() {
+	o.mu.Lock()
+	o.resuming = true
+	o.mu.Unlock()
+	o.setState("starting")
+}
+
+// startAsleep settles the initial state for a container found stopped at
+// startup. A restored last sleep time is kept; otherwise the agent counts as
+// having gone to sleep now.
+func (o *OnDemand) startAsleep() {
+	o.mu.Lock()
+	if o.lastSleepTime.IsZero() {
+		o.lastSleepTime = time.Now()
+	}
+	o.mu.Unlock()
+	o.setState("sleeping")
 }
 
 // SetInitialState informs the policy whether the container is already running
@@ -96,26 +193,28 @@ func (o *OnDemand) Start(ctx context.Context) {
 	if preset != nil {
 		if *preset {
 			o.logger.Info("container reported running at startup, verifying health")
-			o.setState("starting")
+			o.resume()
 		} else {
 			o.logger.Info("container not running at startup")
-			o.setState("sleeping")
+			o.startAsleep()
 		}
 	} else {
 		// Fallback: inspect container status directly.
 		status, err := o.manager.Status(ctx, o.containerName)
 		if err != nil {
 			o.logger.Warn("failed to inspect container on startup, assuming sleeping", "error", err)
-			o.setState("sleeping")
+			o.startAsleep()
 		} else if status == "running" {
 			o.logger.Info("container already running on startup, verifying health")
-			o.setState("starting")
+			o.resume()
 		} else {
 			o.logger.Info("container not running on startup", "status", status)
-			o.setState("sleeping")
+			o.startAsleep()
 		}
 	}
 
+	go o.runSchedule(ctx)
+
 	for {
 		if ctx.Err() != nil {
 			return
@@ -125,12 +224,19 @@ func (o *OnDemand) Start(ctx context.Context) {
 			o.waitForWake(ctx)
 		case "starting":
 			o.waitForReady(ctx)
-		case "ready":
+		case "ready", "unready":
 			o.waitForIdle(ctx)
+		case "draining":
+			// A manual sleep is draining in the background; wait for it.
+			changed := o.notify.wait()
+			if o.State() == "draining" {
+				select {
+				case <-ctx.Done():
+				case <-changed:
+				}
+			}
 		case "degraded":
-			// Stay degraded until context cancelled; Swarm handles recovery.
-			<-ctx.Done()
-			return
+			o.recoverDegraded(ctx)
 		}
 	}
 }
@@ -141,8 +247,18 @@ func (o *OnDemand) State() string {
 	return o.state
 }
 
+// StateChanged returns a channel that is closed on the next state transition.
+func (o *OnDemand) StateChanged() <-chan struct{} {
+	return o.notify.wait()
+}
+
 func (o *OnDemand) OnRequest() {
 	if o.State() == "sleeping" {
+		if reason, _ := o.WakeRefused(); reason != "" {
+			o.logger.Debug("wake request refused", "reason", reason)
+			return
+		}
+
 		// Enforce wake cooldown to prevent rapid wake/sleep cycling.
 		o.mu.RLock()
 		lastSleep := o.lastSleepTime
@@ -166,48 +282,410 @@ func (o *OnDemand) Wake() {
 	o.OnRequest()
 }
 
-// Sleep manually puts the agent to sleep by stopping the container.
+// WakeRefused reports why the agent won't wake right now (quiet hours, or
+// the wake gate) and when it will accept wakes again. The reason is empty if
+// wakes are allowed.
+func (o *OnDemand) WakeRefused() (string, time.Time) {
+	if quiet, until := o.Schedule().Quiet(time.Now()); quiet {
+		return "quiet hours", until
+	}
+	o.mu.RLock()
+	gate := o.gate
+	o.mu.RUnlock()
+	if gate != nil {
+		return gate.WakeRefused(o.agent)
+	}
+	return "", time.Time{}
+}
+
+// SetWakeGate installs g to be consulted before every wake.
+func (o *OnDemand) SetWakeGate(g WakeGate) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gate = g
+}
+
+// Schedule returns the agent's wake schedule, or nil if it has none.
+func (o *OnDemand) Schedule() *Schedule {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.schedule
+}
+
+// Sleep manually puts the agent to sleep. The agent enters the draining state
+// straight away and the container is stopped in the background once open
+// WebSockets close or drain_timeout passes.
 func (o *OnDemand) Sleep(ctx context.Context) {
-	if o.State() != "ready" && o.State() != "degraded" {
+	if !o.beginDrain("manual", "ready", "unready", "degraded") {
 		return
 	}
 	o.logger.Info("manual sleep requested")
-	o.stopContainer(ctx)
-	o.setState("sleeping")
+	go o.finishSleep(context.WithoutCancel(ctx), "manual")
+}
+
+// evict puts an awake agent to sleep for LRU eviction, draining in the
+// background like Sleep. It reports whether the agent was awake.
+func (o *OnDemand) evict(ctx context.Context) bool {
+	if !o.beginDrain("evicted", "ready", "unready") {
+		return false
+	}
+	go o.finishSleep(context.WithoutCancel(ctx), "evicted")
+	return true
+}
+
+// wokeAt returns when the agent was last woken, or the zero time if it has
+// been up since startup.
+func (o *OnDemand) wokeAt() time.Time {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.lastWakeTime
+}
+
+// Reset clears the degraded state by restarting the container. The agent
+// moves to starting and becomes ready once health checks pass.
+func (o *OnDemand) Reset(ctx context.Context) error {
+	if o.State() != "degraded" {
+		return ErrNotDegraded
+	}
+	o.logger.Info("manual reset requested")
+	if err := o.manager.Restart(ctx, o.containerName, 10*time.Second); err != nil {
+		return fmt.Errorf("restart %s: %w", o.containerName, err)
+	}
+	if !o.transition("starting", map[string]string{"reason": "reset"}, "degraded") {
+		return ErrNotDegraded
+	}
+	o.resetRecoveryDelay()
+	return nil
+}
+
+// ObserveRuntime reconciles the policy with a runtime event. An awake agent
+// whose container died goes back to starting at once, so requests are held
+// or rejected rather than failing until health checks notice; a container
+// started behind the policy's back while it sleeps is adopted; and one whose
+// own healthcheck fails is taken out of routing until the next passing
+// readiness check. Events can be stale by the time they arrive, so the
+// runtime's current status is checked before acting on starts and exits.
+func (o *OnDemand) ObserveRuntime(event string) {
+	switch event {
+	case RuntimeDied:
+		if !awake(o.State()) {
+			return
+		}
+		if status, err := o.status(); err != nil || status == "running" {
+			return
+		}
+		if o.transition("starting", map[string]string{"reason": "container_exited"}, "ready", "unready") {
+			o.logger.Warn("container exited, waiting for it to come back")
+		}
+	case RuntimeStarted:
+		if o.State() != "sleeping" {
+			return
+		}
+		if status, err := o.status(); err != nil || status != "running" {
+			return
+		}
+		o.mu.RLock()
+		ours := o.waking
+		o.mu.RUnlock()
+		if ours || o.State() != "sleeping" {
+			return
+		}
+		o.logger.Info("container started outside the policy, adopting it")
+		select {
+		case o.wakeCh <- struct{}{}:
+		default: // already waking
+		}
+	case RuntimeUnhealthy:
+		if o.transition("unready", map[string]string{"reason": "container_unhealthy"}, "ready") {
+			o.logger.Warn("container reported unhealthy, taking agent out of routing")
+		}
+	}
+}
+
+// CorrectDrift reconciles the policy with the runtime status sampled at
+// sampledAt. A container running while the agent sleeps is adopted, so it is
+// routed to and put back to sleep once idle; an awake agent whose container
+// is gone is marked sleeping, to be woken by the next request. Statuses
+// older than the policy's last state change, or taken while it is waking
+// the container itself, are ignored.
+func (o *OnDemand) CorrectDrift(_ context.Context, status string, sampledAt time.Time) (Drift, bool) {
+	o.mu.RLock()
+	state, since, waking := o.state, o.stateSince, o.waking
+	o.mu.RUnlock()
+	if waking || since.After(sampledAt) {
+		return Drift{}, false
+	}
+
+	up := status != "exited"
+	drift := Drift{PolicyState: state, RuntimeState: status}
+	switch {
+	case state == "sleeping" && up:
+		drift.Action = "adopt"
+		select {
+		case o.wakeCh <- struct{}{}:
+		default: // already waking
+		}
+	case awake(state) && !up:
+		drift.Action = "sleep"
+		if !o.transition("sleeping", map[string]string{"reason": "drift"}, state) {
+			return Drift{}, false
+		}
+	default:
+		return Drift{}, false
+	}
+	return drift, true
+}
+
+// status asks the runtime for the container's status.
+func (o *OnDemand) status() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return o.manager.Status(ctx, o.containerName)
 }
 
 // Reconfigure updates runtime parameters that can change safely.
-func (o *OnDemand) Reconfigure(idleTimeout, checkInterval time.Duration, maxFailures, maxRestartAttempts int) {
+func (o *OnDemand) Reconfigure(idleTimeout, checkInterval time.Duration, maxFailures, maxRestartAttempts int, schedule *Schedule) {
 	o.mu.Lock()
-	defer o.mu.Unlock()
 	o.idleTimeout = idleTimeout
 	o.checkInterval = checkInterval
 	o.maxFailures = maxFailures
 	o.maxRestartAttempts = maxRestartAttempts
-	o.logger.Info("reconfigured", "idle_timeout", idleTimeout, "check_interval", checkInterval, "max_failures", maxFailures, "max_restart_attempts", maxRestartAttempts)
+	o.schedule = schedule
+	o.mu.Unlock()
+	o.scheduleNote.notify()
+	o.logger.Info("reconfigured", "idle_timeout", idleTimeout, "check_interval", checkInterval, "max_failures", maxFailures, "max_restart_attempts", maxRestartAttempts, "schedule", schedule != nil)
+}
+
+// runSchedule sends a wake signal at each scheduled wake time until ctx is
+// cancelled, picking up schedule changes from Reconfigure.
+func (o *OnDemand) runSchedule(ctx context.Context) {
+	for {
+		changed := o.scheduleNote.wait()
+		now := time.Now()
+		var fire <-chan time.Time
+		var timer *time.Timer
+		if next := o.Schedule().NextWake(now); !next.IsZero() {
+			timer = time.NewTimer(next.Sub(now))
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-changed:
+		case <-fire:
+			o.scheduledWake()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// scheduledWake wakes the agent for a scheduled wake time. It bypasses the
+// wake cooldown but not quiet hours, and restarts the idle timer if the agent
+// is already awake.
+func (o *OnDemand) scheduledWake() {
+	if reason, _ := o.WakeRefused(); reason != "" {
+		o.logger.Warn("scheduled wake skipped", "reason", reason)
+		return
+	}
+	o.activity.Touch(o.routeKey())
+	if o.State() != "sleeping" {
+		return
+	}
+	o.logger.Info("scheduled wake")
+	select {
+	case o.wakeCh <- struct{}{}:
+	default: // already waking
+	}
+}
+
+// wakeFor wakes the agent because dependent is waking and needs it. Like a
+// scheduled wake it bypasses the wake cooldown but not quiet hours, and
+// restarts the idle timer if the agent is already awake.
+func (o *OnDemand) wakeFor(dependent string) error {
+	// An agent that is already up needs no wake, so quiet hours and the wake
+	// gate don't apply.
+	if o.State() != "sleeping" {
+		o.activity.Touch(o.routeKey())
+		return nil
+	}
+	if reason, _ := o.WakeRefused(); reason != "" {
+		return fmt.Errorf("wake refused: %s", reason)
+	}
+	o.activity.Touch(o.routeKey())
+	o.logger.Info("waking for dependent", "dependent", dependent)
+	select {
+	case o.wakeCh <- struct{}{}:
+	default: // already waking
+	}
+	return nil
+}
+
+func (o *OnDemand) setDependencies(d *Dependencies) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.deps = d
+}
+
+// awakeDependents returns the agents that depend on this one and are up.
+func (o *OnDemand) awakeDependents() []string {
+	o.mu.RLock()
+	deps := o.deps
+	o.mu.RUnlock()
+	if deps == nil {
+		return nil
+	}
+	return deps.AwakeDependents(o.agent)
+}
+
+// wakeDependencies wakes the agents this one depends on and waits up to
+// startup_timeout for them to be ready. The agent shows as starting in the
+// meantime so the proxy holds or rejects its requests as it would during a
+// cold start.
+func (o *OnDemand) wakeDependencies(ctx context.Context) error {
+	o.mu.RLock()
+	deps := o.deps
+	o.mu.RUnlock()
+	if deps == nil || len(deps.DependsOn(o.agent)) == 0 {
+		return nil
+	}
+	o.setStateWith("starting", map[string]string{"reason": "dependencies"})
+	ctx, cancel := context.WithTimeout(ctx, o.startupTimeout)
+	defer cancel()
+	return deps.WakeDependencies(ctx, o.agent)
 }
 
 func (o *OnDemand) setState(s string) {
+	o.setStateWith(s, nil)
+}
+
+// setStateWith transitions to s and attaches fields to the emitted event.
+func (o *OnDemand) setStateWith(s string, fields map[string]string) {
 	o.mu.Lock()
 	prev := o.state
 	o.state = s
-	if s == "sleeping" {
+	if prev != s {
+		o.stateSince = time.Now()
+	}
+	if s == "sleeping" && prev != "sleeping" {
 		o.lastSleepTime = time.Now()
 	}
 	o.mu.Unlock()
 
 	if prev != s {
-		o.logger.Info("state transition", "from", prev, "to", s)
-		// Emit corresponding event.
-		switch s {
-		case "sleeping":
-			o.emitter.Emit(events.Event{Type: events.AgentSleep, Agent: o.agent})
-		case "starting":
-			o.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: o.agent})
-		case "ready":
-			o.emitter.Emit(events.Event{Type: events.AgentReady, Agent: o.agent})
-		case "degraded":
-			o.emitter.Emit(events.Event{Type: events.AgentDegraded, Agent: o.agent})
+		o.emitTransition(prev, s, fields)
+	}
+}
+
+func (o *OnDemand) emitTransition(prev, s string, fields map[string]string) {
+	o.logger.Info("state transition", "from", prev, "to", s)
+	o.notify.notify()
+	// Emit corresponding event.
+	switch s {
+	case "sleeping":
+		o.emitter.Emit(events.Event{Type: events.AgentSleep, Agent: o.agent, Fields: fields})
+	case "starting":
+		o.emitter.Emit(events.Event{Type: events.AgentStarting, Agent: o.agent, Fields: fields})
+	case "ready":
+		o.emitter.Emit(events.Event{Type: events.AgentReady, Agent: o.agent, Fields: fields})
+	case "unready":
+		o.emitter.Emit(events.Event{Type: events.AgentUnready, Agent: o.agent, Fields: fields})
+	case "draining":
+		o.emitter.Emit(events.Event{Type: events.AgentDraining, Agent: o.agent, Fields: fields})
+	case "degraded":
+		o.emitter.Emit(events.Event{Type: events.AgentDegraded, Agent: o.agent, Fields: fields})
+	}
+}
+
+// transition moves the agent to state to only if it is currently in one of
+// from, and reports whether it did. This keeps the policy loop and manual
+// actions (sleep, reset) from both acting on the same state.
+func (o *OnDemand) transition(to string, fields map[string]string, from ...string) bool {
+	o.mu.Lock()
+	prev := o.state
+	if !slices.Contains(from, prev) {
+		o.mu.Unlock()
+		return false
+	}
+	o.state = to
+	if prev != to {
+		o.stateSince = time.Now()
+	}
+	if to == "sleeping" && prev != "sleeping" {
+		o.lastSleepTime = time.Now()
+	}
+	o.mu.Unlock()
+
+	if prev != to {
+		o.emitTransition(prev, to, fields)
+	}
+	return true
+}
+
+// beginDrain moves the agent into the draining state if it is currently in
+// one of from, so the proxy stops routing new connections to it. It returns
+// false if the agent was in some other state.
+func (o *OnDemand) beginDrain(reason string, from ...string) bool {
+	return o.transition("draining", map[string]string{
+		"reason":      reason,
+		"connections": strconv.FormatInt(o.ws.Count(o.routeKey()), 10),
+	}, from...)
+}
+
+// finishSleep waits for the drain to complete, stops the container and moves
+// the agent to sleeping. Connections still open when drain_timeout passes are
+// closed by the stop and reported as forced_closed.
+func (o *OnDemand) finishSleep(ctx context.Context, reason string) {
+	forced := o.waitForDrain(ctx)
+	fields := map[string]string{"reason": reason}
+	if forced > 0 {
+		o.logger.Warn("drain timeout reached, closing remaining connections", "forced_closed", forced)
+		fields["forced_closed"] = strconv.FormatInt(forced, 10)
+	}
+	o.stopContainer(ctx)
+	o.setStateWith("sleeping", fields)
+}
+
+// sleep drains and stops the agent from the policy loop.
+func (o *OnDemand) sleep(ctx context.Context, reason string) {
+	if o.beginDrain(reason, "ready", "unready", "degraded") {
+		o.finishSleep(ctx, reason)
+	}
+}
+
+// waitForDrain blocks until the agent has no open WebSockets or drain_timeout
+// passes, and returns the number of connections still open.
+func (o *OnDemand) waitForDrain(ctx context.Context) int64 {
+	o.mu.RLock()
+	timeout := o.drainTimeout
+	o.mu.RUnlock()
+
+	n := o.ws.Count(o.routeKey())
+	if n == 0 || timeout <= 0 {
+		return n
+	}
+	o.logger.Info("draining connections", "connections", n, "drain_timeout", timeout)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return o.ws.Count(o.routeKey())
+		case <-deadline.C:
+			return o.ws.Count(o.routeKey())
+		case <-ticker.C:
+			if o.ws.Count(o.routeKey()) == 0 {
+				o.logger.Info("connections drained")
+				return 0
+			}
 		}
 	}
 }
@@ -222,38 +700,105 @@ func (o *OnDemand) waitForWake(ctx context.Context) {
 		o.logger.Info("wake signal received, starting container")
 		o.emitter.Emit(events.Event{Type: events.AgentWake, Agent: o.agent})
 	}
+	o.mu.Lock()
+	o.lastWakeTime = time.Now()
+	o.waking = true
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		o.waking = false
+		o.mu.Unlock()
+	}()
+
+	if err := o.wakeDependencies(ctx); err != nil {
+		o.logger.Error("dependencies did not become ready", "error", err)
+		o.transition("sleeping", map[string]string{"reason": "dependencies", "error": err.Error()}, "starting")
+		return
+	}
+
+	// Quiet hours may have begun while the wake was queued or the
+	// dependencies were starting.
+	if quiet, _ := o.Schedule().Quiet(time.Now()); quiet {
+		o.logger.Info("quiet hours started before the container was started, not starting")
+		o.transition("sleeping", map[string]string{"reason": "quiet_hours"}, "starting")
+		return
+	}
 
 	if err := o.manager.Start(ctx, o.containerName); err != nil {
 		o.logger.Error("failed to start container", "error", err)
 		// Stay sleeping — next wake request will retry.
+		o.transition("sleeping", map[string]string{"reason": "start_failed"}, "starting")
 		return
 	}
 
 	o.setState("starting")
 }
 
-// waitForReady polls health until the container is ready or startup times out.
+// waitForReady polls health until the container is ready or startup times
+// out. A start interrupted by quiet hours is cancelled.
 func (o *OnDemand) waitForReady(ctx context.Context) {
-	o.logger.Info("polling health, waiting for ready", "timeout", o.startupTimeout)
+	o.logger.Info("polling startup probe, waiting for ready", "timeout", o.startupTimeout, "interval", o.startupInterval)
 	deadline := time.After(o.startupTimeout)
-	ticker := time.NewTicker(2 * time.Second)
+	ticker := time.NewTicker(o.startupInterval)
 	defer ticker.Stop()
 
+	o.mu.Lock()
+	recovering := o.recovering
+	o.recovering = false
+	o.mu.Unlock()
+
+	scheduleChanged := o.scheduleNote.wait()
+	quietTimer := o.quietTimer()
+	defer func() { quietTimer.Stop() }()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-scheduleChanged:
+			scheduleChanged = o.scheduleNote.wait()
+			quietTimer.Stop()
+			quietTimer = o.quietTimer()
+		case <-quietTimer.C:
+			if quiet, _ := o.Schedule().Quiet(time.Now()); !quiet {
+				quietTimer = o.quietTimer()
+				continue
+			}
+			o.logger.Info("quiet hours started during startup, stopping container")
+			o.stopContainer(ctx)
+			o.setStateWith("sleeping", map[string]string{"reason": "quiet_hours"})
+			return
 		case <-deadline:
+			// A recovery restart that doesn't come up leaves the agent
+			// degraded, so recovery carries on with the next backoff.
+			if recovering {
+				o.logger.Error("startup timeout exceeded after recovery restart, still degraded")
+				o.setStateWith("degraded", map[string]string{"reason": "recovery_timeout"})
+				return
+			}
 			o.logger.Error("startup timeout exceeded, stopping container")
 			o.stopContainer(ctx)
 			o.setState("sleeping")
 			return
 		case <-ticker.C:
-			if err := container.CheckHealth(ctx, o.healthURL); err == nil {
-				o.logger.Info("health check passed, agent ready")
-				o.setState("ready")
-				// Touch activity so idle timer starts from now.
-				o.activity.Touch(o.hostname)
+			if err := o.startupProbe.Probe(ctx); err == nil {
+				if err := o.probeReadiness(ctx); err != nil {
+					o.logger.Info("startup probe passed, agent not yet ready", "error", err)
+					o.setStateWith("unready", map[string]string{"error": err.Error()})
+				} else {
+					o.logger.Info("startup probe passed, agent ready")
+					o.setState("ready")
+				}
+				// Touch activity so idle timer starts from now, unless this
+				// is a running container picked up at startup with activity
+				// restored from before the restart.
+				o.mu.Lock()
+				resumed := o.resuming
+				o.resuming = false
+				o.mu.Unlock()
+				if !resumed || o.activity.LastActivity(o.routeKey()).IsZero() {
+					o.activity.Touch(o.routeKey())
+				}
 				// Run briefing hook if configured.
 				if o.OnReady != nil {
 					o.mu.RLock()
@@ -267,26 +812,65 @@ func (o *OnDemand) waitForReady(ctx context.Context) {
 	}
 }
 
+// idleRemaining returns how long until the agent has been idle for
+// idleTimeout, counting from its last activity.
+func (o *OnDemand) idleRemaining() time.Duration {
+	last := o.activity.LastActivity(o.routeKey())
+	if last.IsZero() {
+		return o.idleTimeout
+	}
+	return max(o.idleTimeout-time.Since(last), 0)
+}
+
 // waitForIdle monitors health and idle timeout while the agent is ready.
 func (o *OnDemand) waitForIdle(ctx context.Context) {
 	o.logger.Info("agent ready, monitoring for idle", "idle_timeout", o.idleTimeout)
 
-	idleTimer := time.NewTimer(o.idleTimeout)
+	idleTimer := time.NewTimer(o.idleRemaining())
 	defer idleTimer.Stop()
 
 	healthTicker := time.NewTicker(o.checkInterval)
 	defer healthTicker.Stop()
 
+	// Quiet hours force the agent to sleep regardless of activity.
+	scheduleChanged := o.scheduleNote.wait()
+	quietTimer := o.quietTimer()
+	defer func() { quietTimer.Stop() }()
+
 	failures := 0
+	healthySince := time.Now()
 
 	for {
+		// Leave the loop if a manual sleep started draining the agent.
+		stateChanged := o.notify.wait()
+		if s := o.State(); s != "ready" && s != "unready" {
+			return
+		}
+
 		select {
 		case <-ctx.Done():
 			return
 
+		case <-stateChanged:
+
+		case <-scheduleChanged:
+			scheduleChanged = o.scheduleNote.wait()
+			quietTimer.Stop()
+			quietTimer = o.quietTimer()
+
+		case <-quietTimer.C:
+			if quiet, _ := o.Schedule().Quiet(time.Now()); !quiet {
+				quietTimer = o.quietTimer()
+				continue
+			}
+			o.logger.Info("quiet hours started, stopping container")
+			o.sleep(ctx, "quiet_hours")
+			return
+
 		case <-healthTicker.C:
-			if err := container.CheckHealth(ctx, o.healthURL); err != nil {
+			if err := o.probe.Probe(ctx); err != nil {
 				failures++
+				healthySince = time.Time{}
 				o.logger.Warn("health check failed while ready", "error", err, "consecutive_failures", failures)
 				o.emitter.Emit(events.Event{
 					Type:  events.AgentHealthFailed,
@@ -313,18 +897,39 @@ func (o *OnDemand) waitForIdle(ctx context.Context) {
 					o.logger.Info("health recovered", "previous_failures", failures)
 				}
 				failures = 0
+				// Healthy long enough that an earlier degraded spell is over.
+				if healthySince.IsZero() {
+					healthySince = time.Now()
+				} else if time.Since(healthySince) >= o.recoveryMaxBackoff {
+					o.resetRecoveryDelay()
+				}
+				o.checkReadiness(ctx)
 			}
 
 		case <-idleTimer.C:
+			// Keep-awake windows suppress idle sleep entirely.
+			if o.Schedule().KeepAwake(time.Now()) {
+				o.logger.Info("idle timer fired inside keep-awake window, resetting")
+				idleTimer.Reset(o.idleTimeout)
+				continue
+			}
+
+			// Agents that depend on this one keep it awake.
+			if dependents := o.awakeDependents(); len(dependents) > 0 {
+				o.logger.Info("idle timer fired but dependents awake, resetting", "dependents", dependents)
+				idleTimer.Reset(o.idleTimeout)
+				continue
+			}
+
 			// Check if there are active WebSocket connections.
-			if o.ws.Count(o.hostname) > 0 {
+			if o.ws.Count(o.routeKey()) > 0 {
 				o.logger.Info("idle timer fired but WebSocket connections active, resetting")
 				idleTimer.Reset(o.idleTimeout)
 				continue
 			}
 
 			// Check if there was recent activity.
-			lastActivity := o.activity.LastActivity(o.hostname)
+			lastActivity := o.activity.LastActivity(o.routeKey())
 			if !lastActivity.IsZero() {
 				elapsed := time.Since(lastActivity)
 				if elapsed < o.idleTimeout {
@@ -336,10 +941,122 @@ func (o *OnDemand) waitForIdle(ctx context.Context) {
 			}
 
 			o.logger.Info("idle timeout reached, stopping container")
-			o.stopContainer(ctx)
-			o.setState("sleeping")
+			o.sleep(ctx, "idle")
+			return
+		}
+	}
+}
+
+// quietTimer returns a timer that fires when the next quiet-hours window opens,
+// or immediately if one is already open. Without quiet hours it never fires.
+func (o *OnDemand) quietTimer() *time.Timer {
+	now := time.Now()
+	sched := o.Schedule()
+	if quiet, _ := sched.Quiet(now); quiet {
+		return time.NewTimer(0)
+	}
+	next := sched.NextQuiet(now)
+	if next.IsZero() {
+		t := time.NewTimer(time.Hour)
+		t.Stop()
+		return t
+	}
+	return time.NewTimer(next.Sub(now))
+}
+
+// recoverDegraded runs while the agent is degraded. With exponential backoff
+// between attempts it probes health, going straight back to ready if the
+// backend has recovered on its own, and otherwise restarts the container and
+// moves to starting. It returns as soon as the agent leaves the degraded
+// state, whether through recovery, a manual reset or a manual sleep. The
+// backoff carries over to the next degraded spell unless the agent stays
+// healthy for recovery_max_backoff in between.
+func (o *OnDemand) recoverDegraded(ctx context.Context) {
+	for attempt := 1; ; attempt++ {
+		delay := o.nextRecoveryDelay()
+		o.logger.Info("agent degraded, scheduling recovery attempt", "attempt", attempt, "in", delay)
+		if !o.waitWhileDegraded(ctx, delay) {
+			return
+		}
+
+		if err := o.probe.Probe(ctx); err == nil {
+			if o.transition("ready", map[string]string{"reason": "recovered"}, "degraded") {
+				o.logger.Info("health recovered while degraded", "attempt", attempt)
+				o.activity.Touch(o.routeKey())
+			}
 			return
 		}
+
+		o.logger.Info("recovery restart", "attempt", attempt)
+		if err := o.manager.Restart(ctx, o.containerName, 10*time.Second); err != nil {
+			o.logger.Error("recovery restart failed", "attempt", attempt, "error", err)
+			continue
+		}
+		if o.transition("starting", map[string]string{"reason": "recovery"}, "degraded") {
+			o.mu.Lock()
+			o.recovering = true
+			o.mu.Unlock()
+		}
+		return
+	}
+}
+
+// nextRecoveryDelay returns the wait before the next recovery attempt.
+func (o *OnDemand) nextRecoveryDelay() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.recovery == nil {
+		o.recovery = newBackoff(o.recoveryBackoff, o.recoveryMaxBackoff)
+	}
+	return o.recovery.next()
+}
+
+// resetRecoveryDelay starts the recovery backoff over from recovery_backoff.
+func (o *OnDemand) resetRecoveryDelay() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.recovery = nil
+}
+
+// waitWhileDegraded sleeps for d, returning false early if ctx is cancelled or
+// the agent leaves the degraded state.
+func (o *OnDemand) waitWhileDegraded(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		changed := o.notify.wait()
+		if o.State() != "degraded" {
+			return false
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		case <-timer.C:
+			return o.State() == "degraded"
+		}
+	}
+}
+
+// probeReadiness runs the readiness probe, if any.
+func (o *OnDemand) probeReadiness(ctx context.Context) error {
+	if o.readinessProbe == nil {
+		return nil
+	}
+	return o.readinessProbe.Probe(ctx)
+}
+
+// checkReadiness moves a live agent between ready and unready. Unready agents
+// stay up but the proxy holds or rejects their requests.
+func (o *OnDemand) checkReadiness(ctx context.Context) {
+	if err := o.probeReadiness(ctx); err != nil {
+		if o.transition("unready", map[string]string{"error": err.Error()}, "ready") {
+			o.logger.Warn("readiness check failed, taking agent out of routing", "error", err)
+		}
+		return
+	}
+	if o.transition("ready", nil, "unready") {
+		o.logger.Info("readiness check passed, agent back in routing")
 	}
 }
 
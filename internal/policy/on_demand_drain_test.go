@@ -0,0 +1,120 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+func newDrainTestOnDemand(t *testing.T, drainTimeout time.Duration) (*OnDemand, *mockLifecycle, *mockWSSource, *[]events.Event, *sync.Mutex) {
+	t.Helper()
+	emitter := events.NewEmitter(quietLogger())
+	var mu sync.Mutex
+	var got []events.Event
+	emitter.OnEvent(func(ev events.Event) {
+		mu.Lock()
+		got = append(got, ev)
+		mu.Unlock()
+	})
+
+	mgr := &mockLifecycle{status: "running"}
+	ws := &mockWSSource{}
+	od := NewOnDemand(mgr, OnDemandConfig{
+		Agent:              "test",
+		ContainerName:      "test-svc",
+		HealthURL:          "http://127.0.0.1:1",
+		Hostname:           "test.com",
+		CheckInterval:      time.Hour,
+		StartupTimeout:     time.Hour,
+		IdleTimeout:        time.Hour,
+		DrainTimeout:       drainTimeout,
+		MaxFailures:        3,
+		MaxRestartAttempts: 2,
+	}, newMockActivity(), ws, emitter, quietLogger())
+	od.mu.Lock()
+	od.state = "ready"
+	od.mu.Unlock()
+	return od, mgr, ws, &got, &mu
+}
+
+func findEvent(mu *sync.Mutex, got *[]events.Event, typ string) *events.Event {
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range *got {
+		if (*got)[i].Type == typ {
+			ev := (*got)[i]
+			return &ev
+		}
+	}
+	return nil
+}
+
+func TestOnDemandSleepDrainsWebSockets(t *testing.T) {
+	od, mgr, ws, got, mu := newDrainTestOnDemand(t, 5*time.Second)
+	atomic.StoreInt64(&ws.count, 2)
+
+	od.Sleep(context.Background())
+	if s := od.State(); s != "draining" {
+		t.Fatalf("state = %q, want draining", s)
+	}
+	ev := findEvent(mu, got, events.AgentDraining)
+	if ev == nil {
+		t.Fatal("expected agent.draining event")
+	}
+	if ev.Fields["reason"] != "manual" || ev.Fields["connections"] != "2" {
+		t.Errorf("draining fields = %v", ev.Fields)
+	}
+
+	// Still draining while connections are open.
+	time.Sleep(250 * time.Millisecond)
+	if atomic.LoadInt32(&mgr.stopCalled) != 0 {
+		t.Fatal("container stopped before connections drained")
+	}
+
+	atomic.StoreInt64(&ws.count, 0)
+	waitForODState(t, od, "sleeping")
+	if atomic.LoadInt32(&mgr.stopCalled) != 1 {
+		t.Errorf("stop called %d times, want 1", atomic.LoadInt32(&mgr.stopCalled))
+	}
+	if ev := findEvent(mu, got, events.AgentSleep); ev == nil || ev.Fields["forced_closed"] != "" {
+		t.Errorf("sleep event = %+v, want no forced_closed", ev)
+	}
+}
+
+func TestOnDemandDrainTimeoutReportsForcedClose(t *testing.T) {
+	od, mgr, ws, got, mu := newDrainTestOnDemand(t, 200*time.Millisecond)
+	atomic.StoreInt64(&ws.count, 3)
+
+	od.Sleep(context.Background())
+	waitForODState(t, od, "sleeping")
+
+	if atomic.LoadInt32(&mgr.stopCalled) != 1 {
+		t.Errorf("stop called %d times, want 1", atomic.LoadInt32(&mgr.stopCalled))
+	}
+	ev := findEvent(mu, got, events.AgentSleep)
+	if ev == nil {
+		t.Fatal("expected agent.sleep event")
+	}
+	if ev.Fields["forced_closed"] != "3" || ev.Fields["reason"] != "manual" {
+		t.Errorf("sleep fields = %v, want forced_closed=3 reason=manual", ev.Fields)
+	}
+}
+
+func TestOnDemandSleepIgnoredWhileDraining(t *testing.T) {
+	od, mgr, ws, _, _ := newDrainTestOnDemand(t, 5*time.Second)
+	atomic.StoreInt64(&ws.count, 1)
+
+	od.Sleep(context.Background())
+	od.Sleep(context.Background())
+
+	atomic.StoreInt64(&ws.count, 0)
+	waitForODState(t, od, "sleeping")
+	time.Sleep(2 * drainPollInterval)
+	if n := atomic.LoadInt32(&mgr.stopCalled); n != 1 {
+		t.Errorf("stop called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,379 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	b := newBackoff(time.Second, 5*time.Second)
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := b.next(); got != w {
+			t.Errorf("next() #%d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestOnDemandRecoverHealthyGoesReady(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "running"}
+	od, _ := newTestOnDemand(srv.URL, mgr)
+	od.recoveryBackoff = 20 * time.Millisecond
+	od.setState("degraded")
+
+	od.recoverDegraded(context.Background())
+
+	if s := od.State(); s != "ready" {
+		t.Errorf("state = %q, want ready", s)
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 0 {
+		t.Error("healthy agent should not be restarted")
+	}
+}
+
+func TestOnDemandRecoverRetriesRestart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "running", restartErr: errors.New("swarm unavailable")}
+	od, _ := newTestOnDemand(srv.URL, mgr)
+	od.recoveryBackoff = 20 * time.Millisecond
+	od.recoveryMaxBackoff = 20 * time.Millisecond
+	od.setState("degraded")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		od.recoverDegraded(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(2 * time.Second)
+	for atomic.LoadInt32(&mgr.restartCalled) < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for repeated restarts")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if s := od.State(); s != "degraded" {
+		t.Errorf("state = %q, want degraded while restarts fail", s)
+	}
+	cancel()
+	<-done
+}
+
+func TestOnDemandRecoverRestartGoesStarting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "running"}
+	od, emitter := newTestOnDemand(srv.URL, mgr)
+	var reason atomic.Value
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentStarting {
+			reason.Store(ev.Fields["reason"])
+		}
+	})
+	od.recoveryBackoff = 20 * time.Millisecond
+	od.setState("degraded")
+
+	od.recoverDegraded(context.Background())
+
+	if s := od.State(); s != "starting" {
+		t.Errorf("state = %q, want starting", s)
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 1 {
+		t.Errorf("restartCalled = %d, want 1", atomic.LoadInt32(&mgr.restartCalled))
+	}
+	if r, _ := reason.Load().(string); r != "recovery" {
+		t.Errorf("starting reason = %q, want recovery", r)
+	}
+}
+
+func TestOnDemandRecoverStopsWhenStateChanges(t *testing.T) {
+	mgr := &mockLifecycle{status: "running"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.recoveryBackoff = time.Hour
+	od.setState("degraded")
+
+	done := make(chan struct{})
+	go func() {
+		od.recoverDegraded(context.Background())
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	od.setState("sleeping")
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("recover did not return after leaving degraded")
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 0 {
+		t.Error("Restart should not be called after leaving degraded")
+	}
+}
+
+func TestOnDemandReset(t *testing.T) {
+	mgr := &mockLifecycle{status: "running"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.setState("ready")
+
+	if err := od.Reset(context.Background()); !errors.Is(err, ErrNotDegraded) {
+		t.Fatalf("Reset on ready agent = %v, want ErrNotDegraded", err)
+	}
+
+	od.setState("degraded")
+	if err := od.Reset(context.Background()); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if s := od.State(); s != "starting" {
+		t.Errorf("state = %q, want starting", s)
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 1 {
+		t.Errorf("restartCalled = %d, want 1", atomic.LoadInt32(&mgr.restartCalled))
+	}
+}
+
+func TestOnDemandResetRestartError(t *testing.T) {
+	mgr := &mockLifecycle{status: "running", restartErr: errors.New("boom")}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.setState("degraded")
+
+	if err := od.Reset(context.Background()); err == nil || errors.Is(err, ErrNotDegraded) {
+		t.Fatalf("Reset = %v, want restart error", err)
+	}
+	if s := od.State(); s != "degraded" {
+		t.Errorf("state = %q, want degraded after failed reset", s)
+	}
+}
+
+func TestAlwaysOnReset(t *testing.T) {
+	ao := NewAlwaysOn(nil, AlwaysOnConfig{
+		Agent:         "test",
+		HealthURL:     "http://127.0.0.1:1",
+		CheckInterval: 50 * time.Millisecond,
+		MaxFailures:   3,
+	}, events.NewEmitter(quietLogger()), quietLogger())
+
+	if err := ao.Reset(context.Background()); !errors.Is(err, ErrNotDegraded) {
+		t.Fatalf("Reset on starting agent = %v, want ErrNotDegraded", err)
+	}
+
+	ao.mu.Lock()
+	ao.state = "degraded"
+	ao.failures = 5
+	ao.mu.Unlock()
+
+	if err := ao.Reset(context.Background()); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if s := ao.State(); s != "starting" {
+		t.Errorf("state = %q, want starting", s)
+	}
+}
+
+func TestOnDemandRecoveryBackoffCarriesOver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "running"}
+	od, _ := newTestOnDemand(srv.URL, mgr)
+	od.recoveryBackoff = 10 * time.Millisecond
+	od.recoveryMaxBackoff = time.Hour
+
+	// Two degraded spells with a failed recovery start in between.
+	for range 2 {
+		od.setState("degraded")
+		od.recoverDegraded(context.Background())
+	}
+	if got := od.nextRecoveryDelay(); got != 40*time.Millisecond {
+		t.Errorf("third delay = %v, want 40ms (backoff kept across degraded spells)", got)
+	}
+
+	od.resetRecoveryDelay()
+	if got := od.nextRecoveryDelay(); got != 10*time.Millisecond {
+		t.Errorf("delay after reset = %v, want 10ms", got)
+	}
+}
+
+func TestOnDemandRecoveryStartTimeoutStaysDegraded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "running"}
+	od, _ := newTestOnDemand(srv.URL, mgr)
+	od.recoveryBackoff = 10 * time.Millisecond
+	od.startupTimeout = 100 * time.Millisecond
+	od.setState("degraded")
+
+	od.recoverDegraded(context.Background())
+	if s := od.State(); s != "starting" {
+		t.Fatalf("state = %q, want starting", s)
+	}
+	od.waitForReady(context.Background())
+
+	if s := od.State(); s != "degraded" {
+		t.Errorf("state = %q, want degraded after a recovery restart times out", s)
+	}
+	if atomic.LoadInt32(&mgr.stopCalled) != 0 {
+		t.Error("Stop should not be called when a recovery restart times out")
+	}
+}
+
+func TestAlwaysOnResetRestarts(t *testing.T) {
+	mgr := &mockLifecycle{status: "running"}
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:         "test",
+		ContainerName: "test-svc",
+		HealthURL:     "http://127.0.0.1:1",
+		CheckInterval: 50 * time.Millisecond,
+		MaxFailures:   3,
+	}, events.NewEmitter(quietLogger()), quietLogger())
+	ao.mu.Lock()
+	ao.state = "degraded"
+	ao.mu.Unlock()
+
+	if err := ao.Reset(context.Background()); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if s := ao.State(); s != "starting" {
+		t.Errorf("state = %q, want starting", s)
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 1 {
+		t.Errorf("restartCalled = %d, want 1", atomic.LoadInt32(&mgr.restartCalled))
+	}
+}
+
+func TestAlwaysOnResetRestartError(t *testing.T) {
+	mgr := &mockLifecycle{status: "running", restartErr: errors.New("boom")}
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:         "test",
+		ContainerName: "test-svc",
+		HealthURL:     "http://127.0.0.1:1",
+		CheckInterval: 50 * time.Millisecond,
+		MaxFailures:   3,
+	}, events.NewEmitter(quietLogger()), quietLogger())
+	ao.mu.Lock()
+	ao.state = "degraded"
+	ao.mu.Unlock()
+
+	if err := ao.Reset(context.Background()); err == nil || errors.Is(err, ErrNotDegraded) {
+		t.Fatalf("Reset = %v, want restart error", err)
+	}
+	if s := ao.State(); s != "degraded" {
+		t.Errorf("state = %q, want degraded after failed reset", s)
+	}
+}
+
+func newTestAlwaysOn(probe probeFunc, mgr *mockLifecycle) (*AlwaysOn, *events.Emitter) {
+	emitter := events.NewEmitter(quietLogger())
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:              "test",
+		ContainerName:      "test-svc",
+		Probe:              probe,
+		CheckInterval:      10 * time.Millisecond,
+		StartupTimeout:     50 * time.Millisecond,
+		MaxFailures:        1,
+		MaxRestartAttempts: 1,
+		RecoveryBackoff:    20 * time.Millisecond,
+		RecoveryMaxBackoff: time.Hour,
+	}, emitter, quietLogger())
+	return ao, emitter
+}
+
+func TestAlwaysOnRecoverHealthyGoesReady(t *testing.T) {
+	mgr := &mockLifecycle{status: "running"}
+	ao, _ := newTestAlwaysOn(func(context.Context) error { return nil }, mgr)
+	ao.mu.Lock()
+	ao.state = "degraded"
+	ao.mu.Unlock()
+
+	ao.recoverDegraded(context.Background())
+
+	if s := ao.State(); s != "ready" {
+		t.Errorf("state = %q, want ready", s)
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 0 {
+		t.Error("healthy agent should not be restarted")
+	}
+}
+
+func TestAlwaysOnRecoversAfterRestartsExhausted(t *testing.T) {
+	var healthy atomic.Bool
+	mgr := &mockLifecycle{status: "running"}
+	ao, emitter := newTestAlwaysOn(func(context.Context) error {
+		if healthy.Load() {
+			return nil
+		}
+		return errors.New("unhealthy")
+	}, mgr)
+	var recoveries, degradedAt, timedOut atomic.Int32
+	emitter.OnEvent(func(ev events.Event) {
+		switch {
+		case ev.Type == events.AgentStarting && ev.Fields["reason"] == "recovery":
+			recoveries.Add(1)
+		case ev.Type == events.AgentDegraded && ev.Fields["reason"] == "recovery_timeout":
+			timedOut.Add(1)
+		case ev.Type == events.AgentDegraded:
+			degradedAt.Store(atomic.LoadInt32(&mgr.restartCalled))
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ao.Start(ctx)
+
+	// The one restart in the budget fails to help, and recovery restarts
+	// that don't come up within the startup timeout go back to degraded.
+	deadline := time.After(5 * time.Second)
+	for timedOut.Load() < 1 {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for a recovery restart to time out, state = %q", ao.State())
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	if n := degradedAt.Load(); n != 1 {
+		t.Errorf("restarts before degrading = %d, want 1", n)
+	}
+	if recoveries.Load() < 1 {
+		t.Error("expected a starting event with reason recovery")
+	}
+
+	healthy.Store(true)
+	for ao.State() != "ready" {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for ready, state = %q", ao.State())
+		default:
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	if st := ao.Restarts(); st.Count < 2 {
+		t.Errorf("Restarts() = %+v, want the recovery restart counted", st)
+	}
+}
@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+// alwaysWindow is open at every moment.
+var alwaysWindow = []ScheduleWindow{{Start: "* * * * *", Duration: time.Hour}}
+
+func waitForODState(t *testing.T, od *OnDemand, want string) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for od.State() != want {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for %s, state = %q", want, od.State())
+		default:
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+}
+
+func TestOnDemandQuietHoursRefuseWake(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.schedule = mustSchedule(t, ScheduleConfig{QuietHours: alwaysWindow})
+
+	reason, until := od.WakeRefused()
+	if reason != "quiet hours" {
+		t.Fatalf("reason = %q, want quiet hours", reason)
+	}
+	if !until.After(time.Now()) {
+		t.Errorf("until = %v, want in the future", until)
+	}
+
+	od.OnRequest()
+	od.scheduledWake()
+	select {
+	case <-od.wakeCh:
+		t.Error("wake signal sent during quiet hours")
+	default:
+	}
+}
+
+func TestOnDemandScheduledWakeBypassesCooldown(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.wakeCooldown = time.Hour
+	od.lastSleepTime = time.Now()
+
+	od.OnRequest()
+	select {
+	case <-od.wakeCh:
+		t.Fatal("request wake should respect cooldown")
+	default:
+	}
+
+	od.scheduledWake()
+	select {
+	case <-od.wakeCh:
+	default:
+		t.Error("expected scheduled wake signal")
+	}
+}
+
+func TestOnDemandKeepAwakeSuppressesIdleSleep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand(srv.URL, mgr)
+	od.schedule = mustSchedule(t, ScheduleConfig{KeepAwake: alwaysWindow})
+	od.SetInitialState(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "ready")
+
+	// Idle timeout is 200ms; the keep-awake window must hold the agent up.
+	time.Sleep(500 * time.Millisecond)
+	if s := od.State(); s != "ready" {
+		t.Errorf("state = %q, want ready inside keep-awake window", s)
+	}
+	if atomic.LoadInt32(&mgr.stopCalled) != 0 {
+		t.Error("Stop should not be called inside keep-awake window")
+	}
+}
+
+func TestOnDemandReconfigureIntoQuietHoursSleeps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand(srv.URL, mgr)
+	od.schedule = mustSchedule(t, ScheduleConfig{KeepAwake: alwaysWindow})
+	od.SetInitialState(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "ready")
+
+	// Quiet hours win over keep-awake and ignore activity.
+	od.Reconfigure(time.Hour, 50*time.Millisecond, 2, 2, mustSchedule(t, ScheduleConfig{
+		KeepAwake:  alwaysWindow,
+		QuietHours: alwaysWindow,
+	}))
+	waitForODState(t, od, "sleeping")
+
+	if atomic.LoadInt32(&mgr.stopCalled) < 1 {
+		t.Error("expected Stop to be called when quiet hours start")
+	}
+}
+
+func TestOnDemandQuietHoursCancelStartup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	mgr := &mockLifecycle{status: "exited"}
+	od, emitter := newTestOnDemand(srv.URL, mgr)
+	od.SetInitialState(true)
+	sleeps := make(chan events.Event, 1)
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentSleep {
+			sleeps <- ev
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "starting")
+
+	// The agent never becomes healthy; quiet hours must not wait for it.
+	od.Reconfigure(time.Hour, 50*time.Millisecond, 2, 2, mustSchedule(t, ScheduleConfig{QuietHours: alwaysWindow}))
+	waitForODState(t, od, "sleeping")
+
+	if atomic.LoadInt32(&mgr.stopCalled) < 1 {
+		t.Error("expected Stop to be called when quiet hours start during startup")
+	}
+	select {
+	case ev := <-sleeps:
+		if ev.Fields["reason"] != "quiet_hours" {
+			t.Errorf("sleep reason = %q, want quiet_hours", ev.Fields["reason"])
+		}
+	case <-time.After(time.Second):
+		t.Error("no agent.sleep event")
+	}
+}
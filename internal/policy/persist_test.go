@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+func TestOnDemandRestoredLastSleepSurvivesStartup(t *testing.T) {
+	od := newDependencyAgent("a", &mockLifecycle{status: "exited"}, passing(), newMockActivity())
+	slept := time.Now().Add(-3 * time.Hour)
+	od.RestoreRuntimeState(RuntimeState{LastSleep: slept})
+	od.SetInitialState(false)
+
+	var mu sync.Mutex
+	var briefedSince time.Time
+	od.OnReady = func(_ context.Context, _ string, lastSleep time.Time) {
+		mu.Lock()
+		briefedSince = lastSleep
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "sleeping")
+	if got := od.RuntimeState().LastSleep; !got.Equal(slept) {
+		t.Errorf("LastSleep = %v, want restored %v", got, slept)
+	}
+
+	od.Wake()
+	waitForODState(t, od, "ready")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		got := briefedSince
+		mu.Unlock()
+		if got.Equal(slept) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("OnReady last sleep = %v, want restored %v", got, slept)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOnDemandResumedAgentKeepsRestoredActivity(t *testing.T) {
+	activity := newMockActivity()
+	// Restored from before the restart: idle for longer than the timeout.
+	activity.activity["a.com"] = time.Now().Add(-2 * time.Hour)
+	mgr := &mockLifecycle{status: "running"}
+	od := newDependencyAgent("a", mgr, passing(), activity)
+	od.idleTimeout = time.Hour
+	od.SetInitialState(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+
+	// Picking up the running container must not restart its idle clock.
+	waitForODState(t, od, "sleeping")
+	if time.Since(activity.LastActivity("a.com")) < time.Hour {
+		t.Error("resuming touched activity")
+	}
+}
+
+func TestAlwaysOnRestoreRuntimeState(t *testing.T) {
+	logger := quietLogger()
+	ao := NewAlwaysOn(&mockLifecycle{}, AlwaysOnConfig{
+		Agent:              "a",
+		ContainerName:      "a-svc",
+		HealthURL:          "http://127.0.0.1:1",
+		CheckInterval:      time.Hour,
+		MaxFailures:        1,
+		MaxRestartAttempts: 3,
+		RecoveryBackoff:    time.Minute,
+		RecoveryMaxBackoff: time.Hour,
+	}, events.NewEmitter(logger), logger)
+
+	last := time.Now().Add(-30 * time.Second)
+	ao.RestoreRuntimeState(RuntimeState{RestartAttempts: 2, Restarts: 7, LastRestart: last})
+
+	st := ao.Restarts()
+	if st.Count != 7 || st.Attempts != 2 || st.LastRestart == nil || !st.LastRestart.Equal(last) {
+		t.Errorf("Restarts = %+v", st)
+	}
+	if got := ao.RuntimeState(); got.RestartAttempts != 2 || got.Restarts != 7 {
+		t.Errorf("RuntimeState = %+v", got)
+	}
+	// Two attempts in, the backoff is at 2m: no restart until last+2m.
+	ao.mu.RLock()
+	next := ao.nextRestart
+	ao.mu.RUnlock()
+	if want := last.Add(2 * time.Minute); !next.Equal(want) {
+		t.Errorf("nextRestart = %v, want %v", next, want)
+	}
+}
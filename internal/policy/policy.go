@@ -1,15 +1,79 @@
 package policy
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"warren/internal/container"
+)
 
 type Policy interface {
 	// Start runs the policy's long-lived goroutine (health checks, restarts, etc).
 	// It blocks until ctx is cancelled.
 	Start(ctx context.Context)
 
-	// State returns the current agent state: "sleeping", "starting", "ready", "degraded".
+	// State returns the current agent state: "sleeping", "starting", "ready",
+	// "unready", "draining", "degraded".
 	State() string
 
 	// OnRequest is called by the proxy before forwarding a request.
 	OnRequest()
 }
+
+// WakeRefuser is implemented by policies that can decline to wake a sleeping
+// agent, e.g. during scheduled quiet hours.
+type WakeRefuser interface {
+	// WakeRefused returns why wakes are currently refused and when they will
+	// be accepted again. The reason is empty if wakes are allowed.
+	WakeRefused() (reason string, until time.Time)
+}
+
+// ErrNotDegraded is returned by Reset when the agent isn't degraded.
+var ErrNotDegraded = errors.New("agent is not degraded")
+
+// Resetter is implemented by policies whose degraded state can be cleared
+// manually.
+type Resetter interface {
+	// Reset clears the degraded state and starts the agent again. It returns
+	// ErrNotDegraded if there is nothing to reset.
+	Reset(ctx context.Context) error
+}
+
+// RuntimeState is the part of a policy's state that is checkpointed so it
+// survives orchestrator restarts.
+type RuntimeState struct {
+	LastSleep       time.Time `json:"last_sleep,omitzero"`
+	RestartAttempts int       `json:"restart_attempts,omitempty"`
+	Restarts        int       `json:"restarts,omitempty"`
+	LastRestart     time.Time `json:"last_restart,omitzero"`
+}
+
+// Persister is implemented by policies with runtime state worth keeping
+// across orchestrator restarts.
+type Persister interface {
+	RuntimeState() RuntimeState
+	RestoreRuntimeState(st RuntimeState)
+}
+
+// Runtime events passed to RuntimeObserver.
+const (
+	RuntimeStarted   = "started"   // the container started
+	RuntimeDied      = "died"      // the container exited
+	RuntimeUnhealthy = "unhealthy" // the container's own healthcheck failed
+)
+
+// RuntimeObserver is implemented by policies that react to what the
+// container runtime reports, ahead of their own health checks.
+// ObserveRuntime may query the runtime, so it can block briefly.
+type RuntimeObserver interface {
+	ObserveRuntime(event string)
+}
+
+// probeFor returns p, or a plain HTTP probe of url if p is nil.
+func probeFor(p container.Prober, url string) container.Prober {
+	if p != nil {
+		return p
+	}
+	return &container.HTTPProbe{URL: url}
+}
@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+// switchProbe passes while ok is set.
+type switchProbe struct {
+	ok    atomic.Bool
+	calls int32
+}
+
+func (p *switchProbe) Probe(context.Context) error {
+	atomic.AddInt32(&p.calls, 1)
+	if p.ok.Load() {
+		return nil
+	}
+	return errors.New("probe failed")
+}
+
+func passing() *switchProbe {
+	p := &switchProbe{}
+	p.ok.Store(true)
+	return p
+}
+
+func TestOnDemandStartupIntervalConfigurable(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.probe = passing()
+	od.startupProbe = passing()
+	od.startupInterval = 20 * time.Millisecond
+	od.SetInitialState(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	start := time.Now()
+	go od.Start(ctx)
+	waitForODState(t, od, "ready")
+
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("ready after %v, want well under the old 2s poll", waited)
+	}
+}
+
+func TestOnDemandReadinessTogglesRoutingWithoutRestart(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	readiness := passing()
+	od.probe = passing()
+	od.startupProbe = passing()
+	od.readinessProbe = readiness
+	od.startupInterval = 10 * time.Millisecond
+	od.idleTimeout = time.Hour
+	od.SetInitialState(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "ready")
+
+	readiness.ok.Store(false)
+	waitForODState(t, od, "unready")
+
+	// Stay unready for longer than MaxFailures health checks.
+	time.Sleep(200 * time.Millisecond)
+	if s := od.State(); s != "unready" {
+		t.Errorf("state = %q, want unready", s)
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 0 {
+		t.Error("readiness failures must not restart the agent")
+	}
+
+	readiness.ok.Store(true)
+	waitForODState(t, od, "ready")
+}
+
+func TestOnDemandStartsUnreadyWhenReadinessFails(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.probe = passing()
+	od.startupProbe = passing()
+	od.readinessProbe = &switchProbe{}
+	od.startupInterval = 10 * time.Millisecond
+	od.idleTimeout = time.Hour
+	od.SetInitialState(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "unready")
+}
+
+func TestAlwaysOnStartupTimeoutSuppressesRestarts(t *testing.T) {
+	startup := &switchProbe{}
+	liveness := passing()
+	mgr := &mockLifecycle{status: "running"}
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:              "test",
+		ContainerName:      "test-svc",
+		Probe:              liveness,
+		StartupProbe:       startup,
+		CheckInterval:      time.Hour,
+		StartupInterval:    10 * time.Millisecond,
+		StartupTimeout:     time.Hour,
+		MaxFailures:        1,
+		MaxRestartAttempts: 3,
+	}, events.NewEmitter(quietLogger()), quietLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ao.Start(ctx)
+	defer cancel()
+
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&startup.calls) < 3 {
+		t.Errorf("startup probe calls = %d, want polling at StartupInterval", atomic.LoadInt32(&startup.calls))
+	}
+	if atomic.LoadInt32(&mgr.restartCalled) != 0 {
+		t.Error("startup failures inside StartupTimeout must not restart")
+	}
+	if atomic.LoadInt32(&liveness.calls) != 0 {
+		t.Error("liveness probe should not run while starting")
+	}
+
+	startup.ok.Store(true)
+	deadline := time.After(2 * time.Second)
+	for ao.State() != "ready" {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for ready")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestAlwaysOnReadinessFailureIsUnready(t *testing.T) {
+	readiness := &switchProbe{}
+	mgr := &mockLifecycle{status: "running"}
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:              "test",
+		ContainerName:      "test-svc",
+		Probe:              passing(),
+		ReadinessProbe:     readiness,
+		CheckInterval:      10 * time.Millisecond,
+		MaxFailures:        1,
+		MaxRestartAttempts: 3,
+	}, events.NewEmitter(quietLogger()), quietLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go ao.Start(ctx)
+	defer cancel()
+
+	deadline := time.After(2 * time.Second)
+	for ao.State() != "unready" {
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for unready, state = %q", ao.State())
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&mgr.restartCalled) != 0 {
+		t.Error("readiness failures must not restart the agent")
+	}
+
+	readiness.ok.Store(true)
+	for ao.State() != "ready" {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for ready")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestLRUCountsUnreadyAsAwake(t *testing.T) {
+	if !awake("unready") || !awake("ready") || awake("draining") {
+		t.Error("awake should cover ready and unready only")
+	}
+}
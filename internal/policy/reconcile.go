@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"warren/internal/container"
+	"warren/internal/events"
+)
+
+// Drift describes a disagreement between a policy and its runtime, and what
+// was done about it.
+type Drift struct {
+	PolicyState  string // the policy's state when the drift was found
+	RuntimeState string // what Lifecycle.Status reported
+	Action       string // how it was corrected, e.g. "adopt", "sleep", "start"
+}
+
+// DriftCorrector is implemented by policies that can bring their state and
+// the runtime back in line.
+type DriftCorrector interface {
+	// CorrectDrift compares the policy's state with status, which the
+	// runtime reported at sampledAt, and corrects any disagreement. It
+	// reports whether there was one.
+	CorrectDrift(ctx context.Context, status string, sampledAt time.Time) (Drift, bool)
+}
+
+// Reconciler periodically compares each registered agent's policy state with
+// what its runtime reports, catching changes made behind Warren's back such
+// as a `docker service scale` by hand. Each correction emits agent.drift.
+type Reconciler struct {
+	interval time.Duration
+	emitter  *events.Emitter
+	logger   *slog.Logger
+
+	mu     sync.Mutex
+	agents map[string]reconciledAgent
+}
+
+type reconciledAgent struct {
+	policy    DriftCorrector
+	lifecycle container.Lifecycle
+	managed   string // name the lifecycle knows the agent by
+}
+
+// NewReconciler creates a reconciler that checks every interval.
+func NewReconciler(interval time.Duration, emitter *events.Emitter, logger *slog.Logger) *Reconciler {
+	return &Reconciler{
+		interval: interval,
+		emitter:  emitter,
+		logger:   logger.With("component", "reconciler"),
+		agents:   make(map[string]reconciledAgent),
+	}
+}
+
+// Register adds an agent whose lifecycle manages managedName, replacing any
+// earlier registration. Policies that can't correct drift are ignored.
+func (r *Reconciler) Register(agent string, pol Policy, lifecycle container.Lifecycle, managedName string) {
+	dc, ok := pol.(DriftCorrector)
+	if !ok || lifecycle == nil || managedName == "" {
+		r.Unregister(agent)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.agents[agent] = reconciledAgent{policy: dc, lifecycle: lifecycle, managed: managedName}
+}
+
+// Unregister stops reconciling an agent.
+func (r *Reconciler) Unregister(agent string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.agents, agent)
+}
+
+// Start reconciles every interval until ctx is cancelled. The first pass
+// waits one interval, since policies settle their initial state themselves.
+func (r *Reconciler) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reconcile(ctx)
+		}
+	}
+}
+
+func (r *Reconciler) reconcile(ctx context.Context) {
+	r.mu.Lock()
+	agents := make(map[string]reconciledAgent, len(r.agents))
+	for name, a := range r.agents {
+		agents[name] = a
+	}
+	r.mu.Unlock()
+
+	for name, a := range agents {
+		sampledAt := time.Now()
+		status, err := a.lifecycle.Status(ctx, a.managed)
+		if err != nil {
+			r.logger.Warn("failed to read runtime status", "agent", name, "error", err)
+			continue
+		}
+		drift, ok := a.policy.CorrectDrift(ctx, status, sampledAt)
+		if !ok {
+			continue
+		}
+		r.logger.Warn("agent drifted from its runtime",
+			"agent", name,
+			"policy_state", drift.PolicyState,
+			"runtime_state", drift.RuntimeState,
+			"action", drift.Action,
+		)
+		r.emitter.Emit(events.Event{Type: events.AgentDrift, Agent: name, Fields: map[string]string{
+			"policy_state":  drift.PolicyState,
+			"runtime_state": drift.RuntimeState,
+			"action":        drift.Action,
+		}})
+	}
+}
@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+// recordDrift collects the fields of every agent.drift event.
+func recordDrift(emitter *events.Emitter) *[]map[string]string {
+	var drifts []map[string]string
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentDrift {
+			drifts = append(drifts, ev.Fields)
+		}
+	})
+	return &drifts
+}
+
+func TestReconcilerOnDemand(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, emitter := newTestOnDemand("http://127.0.0.1:1", mgr)
+	drifts := recordDrift(emitter)
+	r := NewReconciler(time.Minute, emitter, quietLogger())
+	r.Register("test", od, mgr, "test-svc")
+	ctx := context.Background()
+
+	// Sleeping with nothing running is consistent.
+	r.reconcile(ctx)
+	if len(*drifts) != 0 {
+		t.Fatalf("drift while consistent: %v", *drifts)
+	}
+
+	// Scaled up by hand: the running container is adopted.
+	mgr.status = "running"
+	r.reconcile(ctx)
+	if len(*drifts) != 1 || (*drifts)[0]["action"] != "adopt" || (*drifts)[0]["policy_state"] != "sleeping" {
+		t.Fatalf("drifts = %v, want an adopt from sleeping", *drifts)
+	}
+	if len(od.wakeCh) != 1 {
+		t.Error("adopting did not signal a wake")
+	}
+
+	// Scaled down by hand while ready: the agent is put to sleep.
+	od.setState("ready")
+	mgr.status = "exited"
+	r.reconcile(ctx)
+	if len(*drifts) != 2 || (*drifts)[1]["action"] != "sleep" || (*drifts)[1]["runtime_state"] != "exited" {
+		t.Fatalf("drifts = %v, want a sleep from exited", *drifts)
+	}
+	if s := od.State(); s != "sleeping" {
+		t.Errorf("state = %q, want sleeping", s)
+	}
+}
+
+func TestOnDemandCorrectDriftIgnoresStaleStatus(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	sampledAt := time.Now()
+	od.setState("ready")
+
+	if _, ok := od.CorrectDrift(context.Background(), "exited", sampledAt); ok {
+		t.Error("status sampled before the state change was treated as drift")
+	}
+	if s := od.State(); s != "ready" {
+		t.Errorf("state = %q, want ready", s)
+	}
+}
+
+func TestReconcilerAlwaysOn(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	emitter := events.NewEmitter(quietLogger())
+	drifts := recordDrift(emitter)
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:          "test",
+		ContainerName:  "test-svc",
+		Probe:          passing(),
+		CheckInterval:  time.Minute,
+		StartupTimeout: time.Minute,
+		MaxFailures:    3,
+	}, emitter, quietLogger())
+	r := NewReconciler(time.Minute, emitter, quietLogger())
+	r.Register("test", ao, mgr, "test-svc")
+
+	ao.mu.Lock()
+	ao.state = "ready"
+	ao.mu.Unlock()
+	r.reconcile(context.Background())
+
+	if len(*drifts) != 1 || (*drifts)[0]["action"] != "start" {
+		t.Fatalf("drifts = %v, want a start", *drifts)
+	}
+	if n := atomic.LoadInt32(&mgr.startCalled); n != 1 {
+		t.Errorf("Start called %d times, want 1", n)
+	}
+	if s := ao.State(); s != "starting" {
+		t.Errorf("state = %q, want starting", s)
+	}
+
+	// Unregistered agents are no longer checked.
+	r.Unregister("test")
+	r.reconcile(context.Background())
+	if len(*drifts) != 1 {
+		t.Errorf("drifts after unregister = %v", *drifts)
+	}
+}
@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"warren/internal/container"
+)
+
+// StatsSampler takes one sample of host and per-service resource usage.
+type StatsSampler interface {
+	Sample(ctx context.Context) (container.HostStats, error)
+}
+
+// WakeGate can refuse wakes for reasons outside an agent's own policy, such as
+// host resources.
+type WakeGate interface {
+	// WakeRefused returns why agent may not wake right now and when to try
+	// again. The reason is empty if the wake is allowed.
+	WakeRefused(agent string) (reason string, until time.Time)
+}
+
+// ResourceConfig controls resource sampling and memory-based eviction.
+type ResourceConfig struct {
+	Interval        time.Duration // how often to sample
+	MemoryThreshold float64       // fraction of host memory in use, containers or not; 0 disables eviction and wake refusal
+}
+
+// ResourceMonitor samples container CPU and memory. When container memory on
+// the host crosses the threshold it evicts on-demand agents through the LRU
+// manager, one per sample, and refuses wakes that would not fit. Only memory
+// used by containers counts towards the threshold; other processes on the
+// host are not seen.
+type ResourceMonitor struct {
+	sampler StatsSampler
+	lru     *LRUManager
+	cfg     ResourceConfig
+	logger  *slog.Logger
+
+	mu       sync.RWMutex
+	services map[string]string // agent → Swarm service
+	latest   container.HostStats
+	lastSeen map[string]uint64 // agent → memory at its last sample while running
+
+	// OnSample is called after each successful sample with usage by agent.
+	OnSample func(host container.HostStats, agents map[string]container.ServiceStats)
+}
+
+// NewResourceMonitor creates a resource monitor. lru may be nil if eviction
+// isn't wanted.
+func NewResourceMonitor(sampler StatsSampler, lru *LRUManager, cfg ResourceConfig, logger *slog.Logger) *ResourceMonitor {
+	return &ResourceMonitor{
+		sampler:  sampler,
+		lru:      lru,
+		cfg:      cfg,
+		services: make(map[string]string),
+		lastSeen: make(map[string]uint64),
+		logger:   logger.With("component", "resources"),
+	}
+}
+
+// Register maps an agent to the Swarm service its stats are read from,
+// replacing any earlier registration under agent.
+func (r *ResourceMonitor) Register(agent, service string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[agent] = service
+}
+
+// Unregister stops reporting stats for an agent.
+func (r *ResourceMonitor) Unregister(agent string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.services, agent)
+	delete(r.lastSeen, agent)
+}
+
+// Start samples every Interval until ctx is cancelled.
+func (r *ResourceMonitor) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		r.sample(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *ResourceMonitor) sample(ctx context.Context) {
+	host, err := r.sampler.Sample(ctx)
+	if err != nil {
+		r.logger.Warn("resource sample failed", "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	r.latest = host
+	agents := make(map[string]container.ServiceStats, len(r.services))
+	for agent, service := range r.services {
+		st, ok := host.Services[service]
+		if !ok {
+			continue
+		}
+		agents[agent] = st
+		r.lastSeen[agent] = st.MemoryBytes
+	}
+	r.mu.Unlock()
+
+	if r.OnSample != nil {
+		r.OnSample(host, agents)
+	}
+
+	if r.cfg.MemoryThreshold <= 0 || r.lru == nil {
+		return
+	}
+	if used := host.MemoryFraction(); used > r.cfg.MemoryThreshold {
+		if evicted := r.lru.Evict(ctx, "memory_pressure", ""); evicted != "" {
+			r.logger.Warn("host memory above threshold, evicted agent", "used", used, "threshold", r.cfg.MemoryThreshold, "agent", evicted)
+		} else {
+			r.logger.Warn("host memory above threshold, no agent eligible for eviction", "used", used, "threshold", r.cfg.MemoryThreshold)
+		}
+	}
+}
+
+// Stats returns the latest sampled usage of agent's service.
+func (r *ResourceMonitor) Stats(agent string) (container.ServiceStats, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	service, ok := r.services[agent]
+	if !ok {
+		return container.ServiceStats{}, false
+	}
+	st, ok := r.latest.Services[service]
+	return st, ok
+}
+
+// WakeRefused refuses a wake if host memory is already over the threshold,
+// or would be once agent is back at the memory it last used. An agent whose
+// service is still running is already counted in the sample. Wakes are
+// reconsidered at the next sample.
+func (r *ResourceMonitor) WakeRefused(agent string) (string, time.Time) {
+	if r.cfg.MemoryThreshold <= 0 {
+		return "", time.Time{}
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	host := r.latest
+	if host.MemoryTotal == 0 {
+		return "", time.Time{}
+	}
+	limit := r.cfg.MemoryThreshold * float64(host.MemoryTotal)
+	want := host.MemoryUsed
+	if _, running := host.Services[r.services[agent]]; !running {
+		want += r.lastSeen[agent]
+	}
+	if float64(want) <= limit {
+		return "", time.Time{}
+	}
+	reason := fmt.Sprintf("insufficient memory: %.0f%% of host memory in use, limit %.0f%%", host.MemoryFraction()*100, r.cfg.MemoryThreshold*100)
+	return reason, host.SampledAt.Add(r.cfg.Interval)
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"warren/internal/container"
+	"warren/internal/events"
+)
+
+type fakeSampler struct{ host container.HostStats }
+
+func (f *fakeSampler) Sample(context.Context) (container.HostStats, error) {
+	f.host.SampledAt = time.Now()
+	return f.host, nil
+}
+
+func TestResourceMonitorEvictsAboveThreshold(t *testing.T) {
+	activity := newMockActivity()
+	agent := makeLRUAgent(t, "agent-a", "a.com", activity, "http://127.0.0.1:1")
+
+	emitter := events.NewEmitter(quietLogger())
+	var evicted []events.Event
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == events.AgentEvicted {
+			evicted = append(evicted, ev)
+		}
+	})
+	lru := NewLRUManager(activity, emitter, quietLogger())
+	lru.Register("agent-a", agent, EvictionConfig{})
+
+	sampler := &fakeSampler{host: container.HostStats{
+		MemoryTotal: 1000,
+		MemoryUsed:  950,
+		Services:    map[string]container.ServiceStats{"agent-a-svc": {MemoryBytes: 400, Tasks: 1}},
+	}}
+	r := NewResourceMonitor(sampler, lru, ResourceConfig{Interval: time.Minute, MemoryThreshold: 0.9}, quietLogger())
+	r.Register("agent-a", "agent-a-svc")
+
+	var sampled map[string]container.ServiceStats
+	r.OnSample = func(_ container.HostStats, agents map[string]container.ServiceStats) { sampled = agents }
+	r.sample(context.Background())
+
+	if sampled["agent-a"].MemoryBytes != 400 {
+		t.Errorf("OnSample agents = %v, want agent-a at 400 bytes", sampled)
+	}
+	if st, ok := r.Stats("agent-a"); !ok || st.MemoryBytes != 400 {
+		t.Errorf("Stats = %+v, %v", st, ok)
+	}
+	if len(evicted) != 1 || evicted[0].Agent != "agent-a" || evicted[0].Fields["reason"] != "memory_pressure" {
+		t.Fatalf("agent.evicted events = %+v, want agent-a for memory_pressure", evicted)
+	}
+}
+
+func TestResourceMonitorRefusesWakeWithoutHeadroom(t *testing.T) {
+	sampler := &fakeSampler{host: container.HostStats{
+		MemoryTotal: 1000,
+		MemoryUsed:  700,
+		Services:    map[string]container.ServiceStats{"big-svc": {MemoryBytes: 300, Tasks: 1}},
+	}}
+	r := NewResourceMonitor(sampler, nil, ResourceConfig{Interval: time.Minute, MemoryThreshold: 0.9}, quietLogger())
+	r.Register("big", "big-svc")
+	r.Register("small", "small-svc")
+	r.sample(context.Background())
+
+	// big has stopped, but last used 300 bytes: 650 + 300 > 900.
+	sampler.host.MemoryUsed = 650
+	sampler.host.Services = map[string]container.ServiceStats{}
+	r.sample(context.Background())
+
+	reason, until := r.WakeRefused("big")
+	if !strings.Contains(reason, "insufficient memory") {
+		t.Fatalf("reason = %q, want insufficient memory", reason)
+	}
+	if !until.After(time.Now()) {
+		t.Errorf("until = %v, want the next sample", until)
+	}
+	if reason, _ := r.WakeRefused("small"); reason != "" {
+		t.Errorf("small agent refused: %q", reason)
+	}
+
+	// The gate applies to request wakes through the on-demand policy.
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.agent = "big"
+	od.SetWakeGate(r)
+	od.OnRequest()
+	select {
+	case <-od.wakeCh:
+		t.Error("wake signal sent without memory headroom")
+	default:
+	}
+}
+
+func TestResourceMonitorCountsRunningAgentOnce(t *testing.T) {
+	// big is still running: its 300 bytes are already in the 650 used.
+	sampler := &fakeSampler{host: container.HostStats{
+		MemoryTotal: 1000,
+		MemoryUsed:  650,
+		Services:    map[string]container.ServiceStats{"big-svc": {MemoryBytes: 300, Tasks: 1}},
+	}}
+	r := NewResourceMonitor(sampler, nil, ResourceConfig{Interval: time.Minute, MemoryThreshold: 0.9}, quietLogger())
+	r.Register("big", "big-svc")
+	r.sample(context.Background())
+
+	if reason, _ := r.WakeRefused("big"); reason != "" {
+		t.Errorf("running agent refused: %q", reason)
+	}
+}
+
+func TestWakeForAwakeDependencyIgnoresGate(t *testing.T) {
+	sampler := &fakeSampler{host: container.HostStats{MemoryTotal: 1000, MemoryUsed: 950}}
+	r := NewResourceMonitor(sampler, nil, ResourceConfig{Interval: time.Minute, MemoryThreshold: 0.9}, quietLogger())
+	r.sample(context.Background())
+
+	od, _ := newTestOnDemand("http://127.0.0.1:1", &mockLifecycle{status: "running"})
+	od.SetWakeGate(r)
+	od.setState("ready")
+	if err := od.wakeFor("app"); err != nil {
+		t.Errorf("wakeFor on a ready dependency = %v, want nil", err)
+	}
+
+	od.setState("sleeping")
+	if err := od.wakeFor("app"); err == nil || !strings.Contains(err.Error(), "insufficient memory") {
+		t.Errorf("wakeFor on a sleeping dependency = %v, want insufficient memory", err)
+	}
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"warren/internal/events"
+)
+
+// recordReasons collects the reason field of every event of type typ.
+func recordReasons(emitter *events.Emitter, typ string) func() []string {
+	var mu sync.Mutex
+	var reasons []string
+	emitter.OnEvent(func(ev events.Event) {
+		if ev.Type == typ {
+			mu.Lock()
+			reasons = append(reasons, ev.Fields["reason"])
+			mu.Unlock()
+		}
+	})
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), reasons...)
+	}
+}
+
+func TestOnDemandContainerDied(t *testing.T) {
+	mgr := &mockLifecycle{status: "running"}
+	od, emitter := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.probe = passing()
+	od.startupProbe = passing()
+	od.startupInterval = 20 * time.Millisecond
+	od.idleTimeout = time.Minute
+	od.SetInitialState(true)
+	starting := recordReasons(emitter, events.AgentStarting)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "ready")
+
+	// A die event for a container that is running again is stale.
+	od.ObserveRuntime(RuntimeDied)
+	if s := od.State(); s != "ready" {
+		t.Errorf("state after stale die = %q, want ready", s)
+	}
+
+	mgr.status = "exited"
+	od.ObserveRuntime(RuntimeDied)
+	// The first start is the policy resuming the running container.
+	if got := starting(); len(got) != 2 || got[1] != "container_exited" {
+		t.Errorf("starting reasons = %q, want a container_exited start", got)
+	}
+	// The startup probe passes, as if Swarm replaced the task.
+	waitForODState(t, od, "ready")
+}
+
+func TestOnDemandAdoptsExternalStart(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	od, _ := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.probe = passing()
+	od.startupProbe = passing()
+	od.startupInterval = 20 * time.Millisecond
+	od.idleTimeout = time.Minute
+	od.SetInitialState(false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "sleeping")
+
+	// A start event while the container is not running is stale.
+	od.ObserveRuntime(RuntimeStarted)
+	time.Sleep(50 * time.Millisecond)
+	if s := od.State(); s != "sleeping" {
+		t.Fatalf("state after stale start = %q, want sleeping", s)
+	}
+
+	mgr.status = "running"
+	od.ObserveRuntime(RuntimeStarted)
+	waitForODState(t, od, "ready")
+}
+
+func TestOnDemandContainerUnhealthy(t *testing.T) {
+	mgr := &mockLifecycle{status: "running"}
+	od, emitter := newTestOnDemand("http://127.0.0.1:1", mgr)
+	od.probe = passing()
+	od.startupProbe = passing()
+	od.startupInterval = 20 * time.Millisecond
+	od.idleTimeout = time.Minute
+	od.SetInitialState(true)
+	unready := recordReasons(emitter, events.AgentUnready)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go od.Start(ctx)
+	waitForODState(t, od, "ready")
+
+	od.ObserveRuntime(RuntimeUnhealthy)
+	if got := unready(); len(got) != 1 || got[0] != "container_unhealthy" {
+		t.Errorf("unready reasons = %q, want [container_unhealthy]", got)
+	}
+	// The policy's own checks still pass, so it goes back into routing.
+	waitForODState(t, od, "ready")
+}
+
+func TestAlwaysOnContainerDied(t *testing.T) {
+	mgr := &mockLifecycle{status: "exited"}
+	emitter := events.NewEmitter(quietLogger())
+	starting := recordReasons(emitter, events.AgentStarting)
+	ao := NewAlwaysOn(mgr, AlwaysOnConfig{
+		Agent:          "test",
+		ContainerName:  "test-svc",
+		Probe:          passing(),
+		CheckInterval:  20 * time.Millisecond,
+		StartupTimeout: time.Minute,
+		MaxFailures:    3,
+	}, emitter, quietLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ao.Start(ctx)
+	deadline := time.After(2 * time.Second)
+	for ao.State() != "ready" {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for ready")
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ao.ObserveRuntime(RuntimeDied)
+	if got := starting(); len(got) != 2 || got[1] != "container_exited" {
+		t.Errorf("starting reasons = %q, want a container_exited start", got)
+	}
+}
@@ -0,0 +1,199 @@
+package policy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduleConfig describes time-based wake/sleep rules for an on-demand agent.
+// Expressions use standard five-field cron syntax and are evaluated in
+// Timezone (an IANA name, default UTC).
+type ScheduleConfig struct {
+	Timezone   string
+	Wake       []string         // wake the agent at these times
+	KeepAwake  []ScheduleWindow // suppress idle sleep inside these windows
+	QuietHours []ScheduleWindow // refuse wakes and force sleep inside these windows
+}
+
+// ScheduleWindow is a period that opens at each activation of Start and lasts
+// for Duration.
+type ScheduleWindow struct {
+	Start    string
+	Duration time.Duration
+}
+
+// Schedule is a parsed ScheduleConfig. A nil *Schedule has no rules.
+type Schedule struct {
+	cfg        ScheduleConfig
+	loc        *time.Location
+	wake       []cron.Schedule
+	keepAwake  []window
+	quietHours []window
+}
+
+type window struct {
+	start    cron.Schedule
+	duration time.Duration
+}
+
+// ScheduleStatus describes a schedule and its effect at a point in time, for
+// inspection by admin.
+type ScheduleStatus struct {
+	Timezone     string         `json:"timezone"`
+	Wake         []string       `json:"wake,omitempty"`
+	KeepAwake    []windowStatus `json:"keep_awake,omitempty"`
+	QuietHours   []windowStatus `json:"quiet_hours,omitempty"`
+	NextWake     *time.Time     `json:"next_wake,omitempty"`
+	KeepAwakeNow bool           `json:"keep_awake_now"`
+	QuietNow     bool           `json:"quiet_now"`
+	QuietUntil   *time.Time     `json:"quiet_until,omitempty"`
+}
+
+type windowStatus struct {
+	Start    string `json:"start"`
+	Duration string `json:"duration"`
+}
+
+// NewSchedule parses cfg. It returns nil if cfg has no rules.
+func NewSchedule(cfg ScheduleConfig) (*Schedule, error) {
+	if len(cfg.Wake) == 0 && len(cfg.KeepAwake) == 0 && len(cfg.QuietHours) == 0 {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("schedule timezone: %w", err)
+	}
+	s := &Schedule{cfg: cfg, loc: loc}
+	for _, expr := range cfg.Wake {
+		sched, err := cron.ParseStandard(expr)
+		if err != nil {
+			return nil, fmt.Errorf("schedule wake %q: %w", expr, err)
+		}
+		s.wake = append(s.wake, sched)
+	}
+	if s.keepAwake, err = parseWindows("keep_awake", cfg.KeepAwake); err != nil {
+		return nil, err
+	}
+	if s.quietHours, err = parseWindows("quiet_hours", cfg.QuietHours); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parseWindows(kind string, windows []ScheduleWindow) ([]window, error) {
+	result := make([]window, 0, len(windows))
+	for _, w := range windows {
+		sched, err := cron.ParseStandard(w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s %q: %w", kind, w.Start, err)
+		}
+		if w.Duration <= 0 {
+			return nil, fmt.Errorf("schedule %s %q: duration must be positive", kind, w.Start)
+		}
+		result = append(result, window{start: sched, duration: w.Duration})
+	}
+	return result, nil
+}
+
+// NextWake returns the first scheduled wake after now, or the zero time.
+func (s *Schedule) NextWake(now time.Time) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	var next time.Time
+	for _, sched := range s.wake {
+		if t := sched.Next(now.In(s.loc)); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// KeepAwake reports whether now falls inside a keep-awake window.
+func (s *Schedule) KeepAwake(now time.Time) bool {
+	if s == nil {
+		return false
+	}
+	for _, w := range s.keepAwake {
+		if ok, _ := w.active(now.In(s.loc)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Quiet reports whether now falls inside quiet hours, and if so when they end.
+func (s *Schedule) Quiet(now time.Time) (bool, time.Time) {
+	if s == nil {
+		return false, time.Time{}
+	}
+	var until time.Time
+	for _, w := range s.quietHours {
+		if ok, end := w.active(now.In(s.loc)); ok && end.After(until) {
+			until = end
+		}
+	}
+	return !until.IsZero(), until
+}
+
+// NextQuiet returns when the next quiet-hours window opens after now, or the
+// zero time.
+func (s *Schedule) NextQuiet(now time.Time) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	var next time.Time
+	for _, w := range s.quietHours {
+		if t := w.start.Next(now.In(s.loc)); next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Status describes the schedule as of now.
+func (s *Schedule) Status(now time.Time) ScheduleStatus {
+	st := ScheduleStatus{
+		Timezone:   s.loc.String(),
+		Wake:       s.cfg.Wake,
+		KeepAwake:  windowStatuses(s.cfg.KeepAwake),
+		QuietHours: windowStatuses(s.cfg.QuietHours),
+	}
+	if next := s.NextWake(now); !next.IsZero() {
+		st.NextWake = &next
+	}
+	st.KeepAwakeNow = s.KeepAwake(now)
+	if quiet, until := s.Quiet(now); quiet {
+		st.QuietNow = true
+		st.QuietUntil = &until
+	}
+	return st
+}
+
+func windowStatuses(windows []ScheduleWindow) []windowStatus {
+	result := make([]windowStatus, 0, len(windows))
+	for _, w := range windows {
+		result = append(result, windowStatus{Start: w.Start, Duration: w.Duration.String()})
+	}
+	return result
+}
+
+// active reports whether now is inside the window and when it closes. Back-to-
+// back or overlapping activations are merged into one window.
+func (w window) active(now time.Time) (bool, time.Time) {
+	start := w.start.Next(now.Add(-w.duration))
+	if start.IsZero() || start.After(now) {
+		return false, time.Time{}
+	}
+	end := start.Add(w.duration)
+	for i := 0; i < 1000; i++ {
+		next := w.start.Next(start)
+		if next.IsZero() || next.After(end) {
+			break
+		}
+		start, end = next, next.Add(w.duration)
+	}
+	return true, end
+}
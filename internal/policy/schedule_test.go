@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustSchedule(t *testing.T, cfg ScheduleConfig) *Schedule {
+	t.Helper()
+	s, err := NewSchedule(cfg)
+	if err != nil {
+		t.Fatalf("NewSchedule: %v", err)
+	}
+	return s
+}
+
+func TestScheduleNextWakeInTimezone(t *testing.T) {
+	s := mustSchedule(t, ScheduleConfig{
+		Timezone: "Europe/London",
+		Wake:     []string{"45 8 * * MON-FRI"},
+	})
+	london, _ := time.LoadLocation("Europe/London")
+
+	// Friday evening → next wake is Monday 08:45 London time.
+	now := time.Date(2026, 10, 16, 18, 0, 0, 0, london)
+	want := time.Date(2026, 10, 19, 8, 45, 0, 0, london)
+	if got := s.NextWake(now); !got.Equal(want) {
+		t.Errorf("NextWake = %v, want %v", got, want)
+	}
+
+	// The same instant expressed in UTC gives the same answer.
+	if got := s.NextWake(now.UTC()); !got.Equal(want) {
+		t.Errorf("NextWake(UTC) = %v, want %v", got, want)
+	}
+}
+
+func TestScheduleQuietHoursAcrossMidnight(t *testing.T) {
+	s := mustSchedule(t, ScheduleConfig{
+		QuietHours: []ScheduleWindow{{Start: "0 22 * * *", Duration: 9 * time.Hour}},
+	})
+
+	tests := []struct {
+		now   time.Time
+		quiet bool
+		until time.Time
+	}{
+		{time.Date(2026, 10, 16, 21, 59, 0, 0, time.UTC), false, time.Time{}},
+		{time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC), true, time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 17, 3, 30, 0, 0, time.UTC), true, time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC), false, time.Time{}},
+	}
+	for _, tt := range tests {
+		quiet, until := s.Quiet(tt.now)
+		if quiet != tt.quiet || !until.Equal(tt.until) {
+			t.Errorf("Quiet(%v) = %v, %v; want %v, %v", tt.now, quiet, until, tt.quiet, tt.until)
+		}
+	}
+
+	if got, want := s.NextQuiet(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)), time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("NextQuiet = %v, want %v", got, want)
+	}
+}
+
+func TestScheduleKeepAwake(t *testing.T) {
+	s := mustSchedule(t, ScheduleConfig{
+		KeepAwake: []ScheduleWindow{{Start: "0 9 * * MON-FRI", Duration: 8 * time.Hour}},
+	})
+
+	if !s.KeepAwake(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)) { // Friday
+		t.Error("expected keep-awake on Friday midday")
+	}
+	if s.KeepAwake(time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC)) {
+		t.Error("window should have closed at 17:00")
+	}
+	if s.KeepAwake(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)) { // Saturday
+		t.Error("expected no keep-awake on Saturday")
+	}
+}
+
+func TestScheduleMergesContiguousWindows(t *testing.T) {
+	// Hourly activations lasting an hour form one continuous window.
+	s := mustSchedule(t, ScheduleConfig{
+		QuietHours: []ScheduleWindow{{Start: "0 0-5 * * *", Duration: time.Hour}},
+	})
+	quiet, until := s.Quiet(time.Date(2026, 10, 16, 1, 30, 0, 0, time.UTC))
+	if !quiet || !until.Equal(time.Date(2026, 10, 16, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Quiet = %v, %v; want true until 06:00", quiet, until)
+	}
+}
+
+func TestScheduleNilHasNoRules(t *testing.T) {
+	s := mustSchedule(t, ScheduleConfig{Timezone: "Europe/London"})
+	if s != nil {
+		t.Fatal("expected nil schedule without rules")
+	}
+	now := time.Now()
+	if !s.NextWake(now).IsZero() || s.KeepAwake(now) {
+		t.Error("nil schedule should never wake or keep awake")
+	}
+	if quiet, _ := s.Quiet(now); quiet {
+		t.Error("nil schedule should never be quiet")
+	}
+}
+
+func TestNewScheduleErrors(t *testing.T) {
+	tests := []struct {
+		cfg     ScheduleConfig
+		wantErr string
+	}{
+		{ScheduleConfig{Timezone: "Mars/Olympus", Wake: []string{"0 9 * * *"}}, "timezone"},
+		{ScheduleConfig{Wake: []string{"every morning"}}, "wake"},
+		{ScheduleConfig{QuietHours: []ScheduleWindow{{Start: "0 22 * * *"}}}, "duration must be positive"},
+	}
+	for _, tt := range tests {
+		_, err := NewSchedule(tt.cfg)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("NewSchedule(%+v) error = %v, want %q", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
@@ -27,3 +27,26 @@ func (a *ActivityTracker) LastActivity(hostname string) time.Time {
 	defer a.mu.RUnlock()
 	return a.activity[hostname]
 }
+
+// Snapshot returns a copy of every hostname's last activity.
+func (a *ActivityTracker) Snapshot() map[string]time.Time {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	out := make(map[string]time.Time, len(a.activity))
+	for hostname, t := range a.activity {
+		out[hostname] = t
+	}
+	return out
+}
+
+// Restore loads activity saved by Snapshot. Activity already recorded since
+// startup is newer and wins.
+func (a *ActivityTracker) Restore(activity map[string]time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for hostname, t := range activity {
+		if t.After(a.activity[hostname]) {
+			a.activity[hostname] = t
+		}
+	}
+}
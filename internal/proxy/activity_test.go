@@ -21,3 +21,20 @@ func TestLastActivityZeroForUnknown(t *testing.T) {
 		t.Errorf("expected zero time, got %v", last)
 	}
 }
+
+func TestActivityRestoreKeepsNewer(t *testing.T) {
+	a := NewActivityTracker()
+	a.Touch("live.com")
+	old := time.Now().Add(-time.Hour)
+	a.Restore(map[string]time.Time{"live.com": old, "idle.com": old})
+
+	if got := a.LastActivity("idle.com"); !got.Equal(old) {
+		t.Errorf("idle.com = %v, want restored %v", got, old)
+	}
+	if got := a.LastActivity("live.com"); !got.After(old) {
+		t.Errorf("live.com = %v, restored time overwrote newer activity", got)
+	}
+	if snap := a.Snapshot(); len(snap) != 2 {
+		t.Errorf("snapshot has %d hostnames, want 2", len(snap))
+	}
+}
@@ -0,0 +1,407 @@
+package proxy
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"warren/internal/container"
+	"warren/internal/metrics"
+)
+
+// Load-balancing strategies.
+const (
+	RoundRobin       = "round-robin"
+	LeastConnections = "least-connections"
+	ConsistentHash   = "consistent-hash"
+)
+
+// ringReplicas is the number of points each target gets on the consistent-hash
+// ring. More points spread keys more evenly.
+const ringReplicas = 100
+
+// BalancerConfig controls how a backend with several targets spreads load.
+type BalancerConfig struct {
+	Strategy   string // RoundRobin (default), LeastConnections or ConsistentHash
+	HashHeader string // ConsistentHash key from this request header...
+	HashCookie string // ...or this cookie
+
+	ResolveDNS      bool          // expand target hostnames into all resolved addresses
+	ResolveInterval time.Duration // how often to re-resolve, default 30s
+
+	EjectAfter    int           // consecutive proxy errors before ejection, 0 = never eject
+	ProbePath     string        // path probed on ejected targets, "" = TCP connect
+	ProbeInterval time.Duration // how often ejected targets are probed, default 10s
+}
+
+// lookupHost resolves target hostnames. Replaced in tests.
+var lookupHost = net.DefaultResolver.LookupHost
+
+// Target is a single backend address within a Pool.
+type Target struct {
+	URL   *url.URL
+	Proxy *httputil.ReverseProxy
+
+	seed     string       // host of the configured target this was resolved from
+	failures atomic.Int64 // consecutive proxy errors
+	ejected  atomic.Bool
+	probing  atomic.Bool // a probe is in flight
+}
+
+// TargetStatus describes a target for inspection by admin.
+type TargetStatus struct {
+	URL         string `json:"url"`
+	Ejected     bool   `json:"ejected"`
+	Connections int64  `json:"connections"`
+}
+
+// poolTargets is an immutable snapshot of a pool's targets, replaced
+// wholesale when DNS resolution changes the set.
+type poolTargets struct {
+	list []*Target
+	ring []ringPoint // sorted by hash; only built for ConsistentHash
+}
+
+type ringPoint struct {
+	hash   uint32
+	target *Target
+}
+
+// Pool balances requests across one or more targets and passively ejects
+// targets whose requests keep failing until a probe finds them healthy again.
+type Pool struct {
+	agent  string
+	cfg    BalancerConfig
+	seeds  []*url.URL // configured targets, before DNS expansion
+	conns  *WSCounter // in-flight requests + open WebSockets per target
+	rr     atomic.Uint64
+	logger *slog.Logger
+
+	targets atomic.Pointer[poolTargets]
+	probes  sync.WaitGroup // probes in flight
+
+	// Only touched by the maintenance goroutine.
+	nextResolve time.Time
+	nextProbe   time.Time
+}
+
+// withDefaults fills in unset intervals and the strategy.
+func (cfg BalancerConfig) withDefaults() BalancerConfig {
+	if cfg.Strategy == "" {
+		cfg.Strategy = RoundRobin
+	}
+	if cfg.ResolveInterval == 0 {
+		cfg.ResolveInterval = 30 * time.Second
+	}
+	if cfg.ProbeInterval == 0 {
+		cfg.ProbeInterval = 10 * time.Second
+	}
+	return cfg
+}
+
+func newPool(agent string, seeds []*url.URL, cfg BalancerConfig, logger *slog.Logger) *Pool {
+	cfg = cfg.withDefaults()
+	p := &Pool{
+		agent:  agent,
+		cfg:    cfg,
+		seeds:  seeds,
+		conns:  NewWSCounter(),
+		logger: logger,
+	}
+	list := make([]*Target, 0, len(seeds))
+	for _, u := range seeds {
+		list = append(list, p.newTarget(u, u.Host))
+	}
+	p.setTargets(list)
+	return p
+}
+
+func (p *Pool) newTarget(u *url.URL, seed string) *Target {
+	t := &Target{URL: u, seed: seed}
+	rp := httputil.NewSingleHostReverseProxy(u)
+	rp.FlushInterval = -1 // streaming/SSE support
+	rp.ModifyResponse = func(resp *http.Response) error {
+		switch {
+		case resp.StatusCode == http.StatusBadGateway, resp.StatusCode == http.StatusServiceUnavailable,
+			resp.StatusCode == http.StatusGatewayTimeout:
+			p.reportFailure(t)
+		case resp.StatusCode < 500:
+			t.failures.Store(0)
+		}
+		return nil
+	}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		p.logger.Error("proxy error", "agent", p.agent, "target", u.Host, "error", err)
+		p.reportFailure(t)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}
+	t.Proxy = rp
+	return t
+}
+
+// matches reports whether the pool was built for the same targets and
+// balancer settings, so another route of the agent can share it.
+func (p *Pool) matches(agent string, seeds []*url.URL, cfg BalancerConfig) bool {
+	return p.agent == agent && p.cfg == cfg.withDefaults() &&
+		slices.EqualFunc(p.seeds, seeds, func(a, b *url.URL) bool { return a.String() == b.String() })
+}
+
+func (p *Pool) setTargets(list []*Target) {
+	pt := &poolTargets{list: list}
+	if p.cfg.Strategy == ConsistentHash {
+		for _, t := range list {
+			for i := 0; i < ringReplicas; i++ {
+				pt.ring = append(pt.ring, ringPoint{hash: hashKey(t.URL.Host + "#" + strconv.Itoa(i)), target: t})
+			}
+		}
+		sort.Slice(pt.ring, func(i, j int) bool { return pt.ring[i].hash < pt.ring[j].hash })
+	}
+	p.targets.Store(pt)
+}
+
+// Pick chooses the target for r. Ejected targets are skipped unless every
+// target is ejected, in which case all of them are tried again rather than
+// failing outright.
+func (p *Pool) Pick(r *http.Request) *Target {
+	pt := p.targets.Load()
+	if len(pt.list) == 1 {
+		return pt.list[0]
+	}
+
+	healthy := make([]*Target, 0, len(pt.list))
+	for _, t := range pt.list {
+		if !t.ejected.Load() {
+			healthy = append(healthy, t)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = pt.list
+	}
+
+	switch p.cfg.Strategy {
+	case LeastConnections:
+		// Start at a rotating offset so ties are spread evenly.
+		start := int(p.rr.Add(1) % uint64(len(healthy)))
+		var best *Target
+		var bestConns int64
+		for i := range healthy {
+			t := healthy[(start+i)%len(healthy)]
+			if c := p.conns.Count(t.URL.Host); best == nil || c < bestConns {
+				best, bestConns = t, c
+			}
+		}
+		return best
+	case ConsistentHash:
+		if key := p.hashKeyFor(r); key != "" {
+			if t := pt.lookupRing(hashKey(key), len(healthy) != len(pt.list)); t != nil {
+				return t
+			}
+		}
+	}
+	return healthy[(p.rr.Add(1)-1)%uint64(len(healthy))]
+}
+
+// lookupRing walks the ring clockwise from h to the first usable target.
+func (pt *poolTargets) lookupRing(h uint32, skipEjected bool) *Target {
+	if len(pt.ring) == 0 {
+		return nil
+	}
+	i := sort.Search(len(pt.ring), func(i int) bool { return pt.ring[i].hash >= h })
+	for n := 0; n < len(pt.ring); n++ {
+		t := pt.ring[(i+n)%len(pt.ring)].target
+		if !skipEjected || !t.ejected.Load() {
+			return t
+		}
+	}
+	return nil
+}
+
+func (p *Pool) hashKeyFor(r *http.Request) string {
+	if p.cfg.HashHeader != "" {
+		if v := r.Header.Get(p.cfg.HashHeader); v != "" {
+			return v
+		}
+	}
+	if p.cfg.HashCookie != "" {
+		if c, err := r.Cookie(p.cfg.HashCookie); err == nil {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// hashKey hashes s onto the ring. FNV alone leaves keys that differ only in
+// their last characters, like session-1 and session-2, close together, so
+// its result is mixed with MurmurHash3's finalizer.
+func hashKey(s string) uint32 {
+	f := fnv.New32a()
+	f.Write([]byte(s))
+	h := f.Sum32()
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// acquire counts a request or WebSocket against t for least-connections.
+// The returned func releases it.
+func (p *Pool) acquire(t *Target) func() {
+	p.conns.Inc(t.URL.Host)
+	return func() { p.conns.Dec(t.URL.Host) }
+}
+
+func (p *Pool) reportFailure(t *Target) {
+	if p.cfg.EjectAfter <= 0 {
+		return
+	}
+	if t.failures.Add(1) >= int64(p.cfg.EjectAfter) && t.ejected.CompareAndSwap(false, true) {
+		metrics.ProxyTargetEjectionsTotal.WithLabelValues(p.agent).Inc()
+		p.logger.Warn("target ejected", "agent", p.agent, "target", t.URL.Host, "failures", t.failures.Load())
+	}
+}
+
+// Targets returns the current targets and their state.
+func (p *Pool) Targets() []TargetStatus {
+	pt := p.targets.Load()
+	result := make([]TargetStatus, 0, len(pt.list))
+	for _, t := range pt.list {
+		result = append(result, TargetStatus{
+			URL:         t.URL.String(),
+			Ejected:     t.ejected.Load(),
+			Connections: p.conns.Count(t.URL.Host),
+		})
+	}
+	return result
+}
+
+// maintain re-resolves DNS targets and starts probes of ejected targets when
+// due. Probes run concurrently in the background, so a slow target doesn't
+// hold up the others or the maintenance loop.
+func (p *Pool) maintain(ctx context.Context, now time.Time) {
+	if p.cfg.ResolveDNS && !now.Before(p.nextResolve) {
+		p.nextResolve = now.Add(p.cfg.ResolveInterval)
+		p.resolve(ctx)
+	}
+	if !now.Before(p.nextProbe) {
+		p.nextProbe = now.Add(p.cfg.ProbeInterval)
+		for _, t := range p.targets.Load().list {
+			if !t.ejected.Load() || !t.probing.CompareAndSwap(false, true) {
+				continue
+			}
+			p.probes.Add(1)
+			go func() {
+				defer p.probes.Done()
+				defer t.probing.Store(false)
+				if p.probe(ctx, t) == nil {
+					t.failures.Store(0)
+					t.ejected.Store(false)
+					p.logger.Info("target restored", "agent", p.agent, "target", t.URL.Host)
+				}
+			}()
+		}
+	}
+}
+
+// resolve expands seed hostnames into one target per address. Existing
+// targets are kept (with their failure and ejection state) when their address
+// is still present. A seed that fails to resolve keeps its previous targets.
+func (p *Pool) resolve(ctx context.Context) {
+	current := p.targets.Load().list
+	byHost := make(map[string]*Target, len(current))
+	for _, t := range current {
+		byHost[t.URL.Host] = t
+	}
+
+	var next []*Target
+	seen := make(map[string]bool)
+	keep := func(u *url.URL, seed string) {
+		if seen[u.Host] {
+			return
+		}
+		seen[u.Host] = true
+		if t, ok := byHost[u.Host]; ok {
+			next = append(next, t)
+		} else {
+			next = append(next, p.newTarget(u, seed))
+		}
+	}
+
+	for _, seed := range p.seeds {
+		host := seed.Hostname()
+		if net.ParseIP(host) != nil {
+			keep(seed, seed.Host)
+			continue
+		}
+		addrs, err := lookupHost(ctx, host)
+		if err != nil || len(addrs) == 0 {
+			p.logger.Warn("backend DNS resolution failed", "agent", p.agent, "host", host, "error", err)
+			for _, t := range current {
+				if t.seed == seed.Host {
+					keep(t.URL, t.seed)
+				}
+			}
+			continue
+		}
+		port := seed.Port()
+		if port == "" {
+			port = "80"
+			if seed.Scheme == "https" {
+				port = "443"
+			}
+		}
+		for _, addr := range addrs {
+			u := *seed
+			u.Host = net.JoinHostPort(addr, port)
+			keep(&u, seed.Host)
+		}
+	}
+
+	if len(next) == 0 {
+		return
+	}
+	if !sameTargets(current, next) {
+		p.logger.Info("backend targets updated", "agent", p.agent, "targets", len(next))
+		p.setTargets(next)
+	}
+}
+
+func sameTargets(a, b []*Target) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Pool) probe(ctx context.Context, t *Target) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if p.cfg.ProbePath != "" {
+		u := *t.URL
+		u.Path = p.cfg.ProbePath
+		u.RawQuery = ""
+		return container.CheckHealth(ctx, u.String())
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.URL.Host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
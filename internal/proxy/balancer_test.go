@@ -0,0 +1,348 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"warren/internal/policy"
+	"warren/internal/services"
+)
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// replicaProxy registers one agent on lb.com backed by the given servers.
+func replicaProxy(t *testing.T, cfg BalancerConfig, servers ...*httptest.Server) *Proxy {
+	t.Helper()
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	var extra []*url.URL
+	for _, s := range servers[1:] {
+		extra = append(extra, mustURL(t, s.URL))
+	}
+	p.RegisterWithOptions("lb.com", "agent", mustURL(t, servers[0].URL), policy.NewUnmanaged(), BackendOptions{
+		Targets:  extra,
+		Balancer: cfg,
+	})
+	return p
+}
+
+func bodyOf(p *Proxy, header http.Header) string {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "lb.com"
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	b, _ := io.ReadAll(w.Result().Body)
+	return string(b)
+}
+
+func named(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	}))
+}
+
+func TestRoundRobin(t *testing.T) {
+	a, b, c := named("a"), named("b"), named("c")
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+	p := replicaProxy(t, BalancerConfig{}, a, b, c)
+
+	counts := make(map[string]int)
+	for i := 0; i < 9; i++ {
+		counts[bodyOf(p, nil)]++
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if counts[name] != 3 {
+			t.Errorf("counts = %v, want 3 each", counts)
+			break
+		}
+	}
+}
+
+func TestLeastConnections(t *testing.T) {
+	a, b := named("a"), named("b")
+	defer a.Close()
+	defer b.Close()
+	p := replicaProxy(t, BalancerConfig{Strategy: LeastConnections}, a, b)
+
+	// Hold a connection open against a.
+	pool := p.Backends()["lb.com"].Pool
+	release := pool.acquire(pool.targets.Load().list[0])
+	defer release()
+
+	for i := 0; i < 4; i++ {
+		if got := bodyOf(p, nil); got != "b" {
+			t.Fatalf("request %d went to %q, want b (fewest connections)", i, got)
+		}
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	a, b, c := named("a"), named("b"), named("c")
+	defer a.Close()
+	defer b.Close()
+	defer c.Close()
+	p := replicaProxy(t, BalancerConfig{Strategy: ConsistentHash, HashHeader: "X-Session"}, a, b, c)
+
+	sessions := make(map[string]string)
+	seen := make(map[string]bool)
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		got := bodyOf(p, http.Header{"X-Session": {key}})
+		sessions[key] = got
+		seen[got] = true
+		if again := bodyOf(p, http.Header{"X-Session": {key}}); again != got {
+			t.Fatalf("session %s moved from %s to %s", key, got, again)
+		}
+	}
+	if len(seen) < 2 {
+		t.Errorf("30 sessions all hashed to %v", seen)
+	}
+
+	// Ejecting one target only moves that target's sessions.
+	pool := p.Backends()["lb.com"].Pool
+	var ejected *Target
+	for _, tg := range pool.targets.Load().list {
+		if tg.URL.String() == a.URL {
+			ejected = tg
+		}
+	}
+	ejected.ejected.Store(true)
+	for key, before := range sessions {
+		after := bodyOf(p, http.Header{"X-Session": {key}})
+		if before != "a" && after != before {
+			t.Errorf("session %s moved from %s to %s after ejecting a", key, before, after)
+		}
+		if after == "a" {
+			t.Errorf("session %s still routed to ejected target", key)
+		}
+	}
+}
+
+func TestConsistentHashCookie(t *testing.T) {
+	a, b := named("a"), named("b")
+	defer a.Close()
+	defer b.Close()
+	p := replicaProxy(t, BalancerConfig{Strategy: ConsistentHash, HashCookie: "sid"}, a, b)
+
+	first := bodyOf(p, http.Header{"Cookie": {"sid=abc"}})
+	for i := 0; i < 5; i++ {
+		if got := bodyOf(p, http.Header{"Cookie": {"sid=abc"}}); got != first {
+			t.Fatalf("cookie session moved from %s to %s", first, got)
+		}
+	}
+}
+
+func TestPassiveEjectionAndProbe(t *testing.T) {
+	good := named("good")
+	defer good.Close()
+	bad := named("bad")
+	badURL := bad.URL
+	bad.Close() // connection refused from now on
+
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	p.RegisterWithOptions("lb.com", "agent", mustURL(t, good.URL), policy.NewUnmanaged(), BackendOptions{
+		Targets:  []*url.URL{mustURL(t, badURL)},
+		Balancer: BalancerConfig{EjectAfter: 2},
+	})
+
+	// Round-robin alternates until the bad target has failed twice.
+	for i := 0; i < 4; i++ {
+		bodyOf(p, nil)
+	}
+	pool := p.Backends()["lb.com"].Pool
+	if st := pool.Targets(); !st[1].Ejected {
+		t.Fatalf("bad target not ejected: %+v", st)
+	}
+	for i := 0; i < 4; i++ {
+		if got := bodyOf(p, nil); got != "good" {
+			t.Fatalf("request routed to ejected target, got %q", got)
+		}
+	}
+
+	// Probes keep failing while the target is down.
+	pool.maintain(context.Background(), time.Now())
+	pool.probes.Wait()
+	if !pool.Targets()[1].Ejected {
+		t.Fatal("target restored while still down")
+	}
+
+	// Point the ejected target at a live server and let the probe restore it.
+	pool.targets.Load().list[1].URL.Host = mustURL(t, good.URL).Host
+	pool.maintain(context.Background(), time.Now().Add(time.Minute))
+	pool.probes.Wait()
+	if pool.Targets()[1].Ejected {
+		t.Error("target not restored after successful probe")
+	}
+}
+
+func TestGatewayErrorsEject(t *testing.T) {
+	good := named("good")
+	defer good.Close()
+	var status int
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer flaky.Close()
+	p := replicaProxy(t, BalancerConfig{EjectAfter: 2}, good, flaky)
+	pool := p.Backends()["lb.com"].Pool
+
+	// A 500 neither counts towards ejection nor resets the count.
+	for _, status = range []int{http.StatusServiceUnavailable, http.StatusInternalServerError} {
+		bodyOf(p, nil)
+		bodyOf(p, nil)
+	}
+	if pool.Targets()[1].Ejected {
+		t.Fatal("target ejected after one gateway error")
+	}
+	status = http.StatusBadGateway
+	bodyOf(p, nil)
+	bodyOf(p, nil)
+	if !pool.Targets()[1].Ejected {
+		t.Errorf("target not ejected after two gateway errors: %+v", pool.Targets())
+	}
+}
+
+func TestPoolSharedAcrossHostnames(t *testing.T) {
+	a := named("a")
+	defer a.Close()
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	opts := BackendOptions{Balancer: BalancerConfig{EjectAfter: 1}}
+	p.RegisterWithOptions("lb.com", "agent", mustURL(t, a.URL), policy.NewUnmanaged(), opts)
+	p.RegisterWithOptions("alias.lb.com", "agent", mustURL(t, a.URL), policy.NewUnmanaged(), opts)
+	p.RegisterWithOptions("other.com", "other", mustURL(t, a.URL), policy.NewUnmanaged(), opts)
+
+	b := p.Backends()
+	if b["lb.com"].Pool != b["alias.lb.com"].Pool {
+		t.Error("an agent's hostnames should share one pool")
+	}
+	if b["lb.com"].Pool == b["other.com"].Pool {
+		t.Error("different agents should not share a pool")
+	}
+}
+
+func TestWebSocketDialFailureEjects(t *testing.T) {
+	good := named("good")
+	defer good.Close()
+	bad := named("bad")
+	badURL := bad.URL
+	bad.Close()
+
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	p.RegisterWithOptions("lb.com", "agent", mustURL(t, badURL), policy.NewUnmanaged(), BackendOptions{
+		Targets:  []*url.URL{mustURL(t, good.URL)},
+		Balancer: BalancerConfig{EjectAfter: 1},
+	})
+	pool := p.Backends()["lb.com"].Pool
+
+	// Round-robin sends the first upgrade to the dead target.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "lb.com"
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", w.Code)
+	}
+	if !pool.Targets()[0].Ejected {
+		t.Errorf("target not ejected after a WebSocket dial failure: %+v", pool.Targets())
+	}
+}
+
+func TestProbesRunConcurrently(t *testing.T) {
+	// Two targets that accept connections but never answer the probe.
+	hang := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer slow.Close()
+	defer close(hang)
+
+	pool := newPool("agent", []*url.URL{mustURL(t, slow.URL), mustURL(t, slow.URL+"/")}, BalancerConfig{ProbePath: "/health"}, testLogger())
+	for _, tg := range pool.targets.Load().list {
+		tg.ejected.Store(true)
+	}
+
+	start := time.Now()
+	pool.maintain(context.Background(), start)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("maintain blocked for %v waiting on probes", elapsed)
+	}
+	pool.maintain(context.Background(), start.Add(time.Minute))
+	for _, tg := range pool.targets.Load().list {
+		if !tg.probing.Load() {
+			t.Error("expected a probe in flight for every ejected target")
+		}
+	}
+}
+
+func TestAllTargetsEjectedFailsOpen(t *testing.T) {
+	a := named("a")
+	defer a.Close()
+	b := named("b")
+	defer b.Close()
+	p := replicaProxy(t, BalancerConfig{EjectAfter: 1}, a, b)
+
+	for _, tg := range p.Backends()["lb.com"].Pool.targets.Load().list {
+		tg.ejected.Store(true)
+	}
+	if got := bodyOf(p, nil); got != "a" && got != "b" {
+		t.Errorf("got %q, want a request to some target", got)
+	}
+}
+
+func TestResolveDNS(t *testing.T) {
+	orig := lookupHost
+	defer func() { lookupHost = orig }()
+	addrs := []string{"10.0.0.1", "10.0.0.2"}
+	var lookupErr error
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host != "tasks.app" {
+			t.Errorf("lookup host = %q", host)
+		}
+		return addrs, lookupErr
+	}
+
+	pool := newPool("agent", []*url.URL{mustURL(t, "http://tasks.app:8080")}, BalancerConfig{ResolveDNS: true}, testLogger())
+	pool.maintain(context.Background(), time.Now())
+
+	got := pool.Targets()
+	if len(got) != 2 || got[0].URL != "http://10.0.0.1:8080" || got[1].URL != "http://10.0.0.2:8080" {
+		t.Fatalf("targets = %+v", got)
+	}
+	first := pool.targets.Load().list[0]
+
+	// A new task appears; existing targets keep their identity.
+	addrs = []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	pool.resolve(context.Background())
+	if list := pool.targets.Load().list; len(list) != 3 || list[0] != first {
+		t.Fatalf("after scale-up targets = %+v", pool.Targets())
+	}
+
+	// Resolution failures keep the previous set.
+	lookupErr = errors.New("no such host")
+	addrs = nil
+	pool.resolve(context.Background())
+	if n := len(pool.Targets()); n != 3 {
+		t.Errorf("after DNS failure targets = %d, want 3", n)
+	}
+}
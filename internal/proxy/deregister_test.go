@@ -43,3 +43,30 @@ func TestDeregister(t *testing.T) {
 		t.Fatalf("expected 404 after deregister, got %d", w.Code)
 	}
 }
+
+func TestDeregisterReleasesReservations(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	registry := services.NewRegistry(logger)
+	p := New(registry, "", logger)
+
+	target, _ := url.Parse("http://localhost:9999")
+	p.Register("Test.Example.com", "test", target, policy.NewUnmanaged())
+	p.Register("*.dev.example.com", "dev", target, policy.NewUnmanaged())
+
+	if err := registry.Register("test.example.com", "http://10.0.0.1:80", "other"); err == nil {
+		t.Fatal("expected the configured hostname to be reserved, case-insensitively")
+	}
+	if err := registry.Register("x.dev.example.com", "http://10.0.0.1:80", "other"); err == nil {
+		t.Fatal("expected the wildcard to be reserved")
+	}
+
+	p.Deregister("test.example.com")
+	p.DeregisterAgent("dev")
+
+	if err := registry.Register("test.example.com", "http://10.0.0.1:80", "other"); err != nil {
+		t.Errorf("hostname still reserved after deregister: %v", err)
+	}
+	if err := registry.Register("x.dev.example.com", "http://10.0.0.1:80", "other"); err != nil {
+		t.Errorf("wildcard still reserved after deregister: %v", err)
+	}
+}
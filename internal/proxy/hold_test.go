@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"warren/internal/services"
+)
+
+// notifyPolicy is a mock policy that signals state transitions.
+type notifyPolicy struct {
+	mu      sync.Mutex
+	state   string
+	changed chan struct{}
+}
+
+func newNotifyPolicy(state string) *notifyPolicy {
+	return &notifyPolicy{state: state, changed: make(chan struct{})}
+}
+
+func (n *notifyPolicy) Start(_ context.Context) {}
+func (n *notifyPolicy) OnRequest()              {}
+
+func (n *notifyPolicy) State() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.state
+}
+
+func (n *notifyPolicy) StateChanged() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.changed
+}
+
+func (n *notifyPolicy) set(state string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.state = state
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+func setupHoldProxy(t *testing.T, pol *notifyPolicy, hold *HoldConfig) *Proxy {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("woken"))
+	}))
+	t.Cleanup(backend.Close)
+
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	u, _ := url.Parse(backend.URL)
+	p.RegisterWithOptions("hold.com", "agent-h", u, pol, BackendOptions{Hold: hold})
+	return p
+}
+
+func TestHoldForwardsOnceReady(t *testing.T) {
+	pol := newNotifyPolicy("sleeping")
+	p := setupHoldProxy(t, pol, &HoldConfig{MaxQueue: 10, Timeout: 2 * time.Second})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pol.set("starting")
+		time.Sleep(50 * time.Millisecond)
+		pol.set("ready")
+	}()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "hold.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body, _ := io.ReadAll(w.Result().Body)
+	if string(body) != "woken" {
+		t.Errorf("body = %q, want woken", body)
+	}
+}
+
+func TestHoldExpiresWith503(t *testing.T) {
+	pol := newNotifyPolicy("starting")
+	p := setupHoldProxy(t, pol, &HoldConfig{MaxQueue: 10, Timeout: 50 * time.Millisecond})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "hold.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "3" {
+		t.Error("missing Retry-After header")
+	}
+}
+
+func TestHoldQueueFullRejects(t *testing.T) {
+	pol := newNotifyPolicy("sleeping")
+	p := setupHoldProxy(t, pol, &HoldConfig{MaxQueue: 1, Timeout: 2 * time.Second})
+
+	// Occupy the single hold slot.
+	first := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "hold.com"
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		first <- w.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "hold.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("second request status = %d, want 503 (queue full)", w.Code)
+	}
+
+	pol.set("ready")
+	if code := <-first; code != 200 {
+		t.Errorf("held request status = %d, want 200", code)
+	}
+}
+
+func TestNoHoldWithoutNotifier(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	u, _ := url.Parse(s.URL)
+	p.RegisterWithOptions("a.com", "a", u, &mockPolicy{state: "sleeping"}, BackendOptions{
+		Hold: &HoldConfig{MaxQueue: 10, Timeout: time.Second},
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "a.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestHoldFailsWhenDegraded(t *testing.T) {
+	pol := newNotifyPolicy("starting")
+	p := setupHoldProxy(t, pol, &HoldConfig{MaxQueue: 10, Timeout: 2 * time.Second})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pol.set("degraded")
+	}()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "hold.com"
+	w := httptest.NewRecorder()
+	start := time.Now()
+	p.ServeHTTP(w, req)
+
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v, want release as soon as the agent degrades", waited)
+	}
+}
+
+func TestNoHoldWhileDraining(t *testing.T) {
+	pol := newNotifyPolicy("draining")
+	p := setupHoldProxy(t, pol, &HoldConfig{MaxQueue: 10, Timeout: 2 * time.Second})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "hold.com"
+	w := httptest.NewRecorder()
+	start := time.Now()
+	p.ServeHTTP(w, req)
+
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v, want an immediate 503 while draining", waited)
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"warren/internal/policy"
+)
+
+// healthStreamKeepalive is how often /api/health/stream re-sends the current
+// state when nothing changes. It also acts as the poll interval for policies
+// that can't signal transitions.
+const healthStreamKeepalive = 15 * time.Second
+
+// InterstitialData is passed to interstitial templates.
+type InterstitialData struct {
+	Agent      string
+	Hostname   string
+	BasePath   string // path prefix the agent is served under, "" for the whole host
+	State      string
+	RetryAfter int // seconds
+}
+
+const defaultInterstitialHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Agent}} is waking up</title>
+<noscript><meta http-equiv="refresh" content="{{.RetryAfter}}"></noscript>
+<style>
+  body { font-family: system-ui, sans-serif; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; background: #111; color: #eee; }
+  main { text-align: center; }
+  .state { color: #999; }
+</style>
+</head>
+<body>
+<main>
+  <h1>{{.Agent}} is waking up</h1>
+  <p class="state">Current state: <span id="state">{{.State}}</span></p>
+  <p>This page will reload automatically when the agent is ready.</p>
+</main>
+<script>
+(function () {
+  var reload = function () { window.location.reload(); };
+  if (!window.EventSource) { setTimeout(reload, {{.RetryAfter}} * 1000); return; }
+  var es = new EventSource("{{.BasePath}}/api/health/stream");
+  es.onmessage = function (e) {
+    var s = JSON.parse(e.data).status;
+    document.getElementById("state").textContent = s;
+    if (s === "ready" || s === "degraded") { es.close(); reload(); }
+  };
+  es.onerror = function () { es.close(); setTimeout(reload, {{.RetryAfter}} * 1000); };
+})();
+</script>
+</body>
+</html>
+`
+
+// DefaultInterstitial is the built-in "waking up" page.
+var DefaultInterstitial = template.Must(template.New("interstitial").Parse(defaultInterstitialHTML))
+
+// LoadInterstitial parses a custom interstitial template from path.
+func LoadInterstitial(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read interstitial template: %w", err)
+	}
+	tmpl, err := template.New("interstitial").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse interstitial template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// wantsHTML reports whether the client prefers an HTML response (i.e. is a browser).
+func wantsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func (p *Proxy) serveInterstitial(w http.ResponseWriter, hostname string, b *Backend, state string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Retry-After", "3")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := b.Interstitial.Execute(w, InterstitialData{
+		Agent:      b.AgentName,
+		Hostname:   hostname,
+		BasePath:   b.PathPrefix,
+		State:      state,
+		RetryAfter: 3,
+	}); err != nil {
+		p.logger.Error("interstitial render failed", "agent", b.AgentName, "error", err)
+	}
+}
+
+// handleHealthStream streams the agent's state as Server-Sent Events, sending
+// an update on every state transition.
+func (p *Proxy) handleHealthStream(w http.ResponseWriter, r *http.Request, b *Backend) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	notifier, canNotify := b.Policy.(policy.StateNotifier)
+	ticker := time.NewTicker(healthStreamKeepalive)
+	defer ticker.Stop()
+
+	for {
+		var changed <-chan struct{}
+		if canNotify {
+			changed = notifier.StateChanged()
+		}
+		data, _ := json.Marshal(healthResponse{Status: b.Policy.State(), Agent: b.AgentName})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
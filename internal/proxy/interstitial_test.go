@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"warren/internal/services"
+)
+
+func setupInterstitialProxy(t *testing.T, pol *notifyPolicy, opts BackendOptions) *Proxy {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(s.Close)
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	u, _ := url.Parse(s.URL)
+	p.RegisterWithOptions("wake.com", "agent-w", u, pol, opts)
+	return p
+}
+
+func TestInterstitialForBrowsers(t *testing.T) {
+	p := setupInterstitialProxy(t, newNotifyPolicy("sleeping"), BackendOptions{Interstitial: DefaultInterstitial})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "wake.com"
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("content-type = %q, want text/html", ct)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "agent-w") || !strings.Contains(body, "sleeping") {
+		t.Errorf("interstitial missing agent name or state: %s", body)
+	}
+}
+
+func TestInterstitialAPIClientsGetJSON(t *testing.T) {
+	p := setupInterstitialProxy(t, newNotifyPolicy("starting"), BackendOptions{Interstitial: DefaultInterstitial})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "wake.com"
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content-type = %q, want application/json", ct)
+	}
+}
+
+func TestInterstitialCustomTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.html")
+	os.WriteFile(path, []byte(`<h1>{{.Agent}} on {{.Hostname}} is {{.State}}</h1>`), 0644)
+	tmpl, err := LoadInterstitial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := setupInterstitialProxy(t, newNotifyPolicy("starting"), BackendOptions{Interstitial: tmpl})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "wake.com"
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if got := w.Body.String(); got != "<h1>agent-w on wake.com is starting</h1>" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestLoadInterstitialInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.html")
+	os.WriteFile(path, []byte(`{{.Agent`), 0644)
+	if _, err := LoadInterstitial(path); err == nil {
+		t.Error("expected parse error")
+	}
+	if _, err := LoadInterstitial(filepath.Join(t.TempDir(), "missing.html")); err == nil {
+		t.Error("expected read error")
+	}
+}
+
+func TestHealthStreamSendsTransitions(t *testing.T) {
+	pol := newNotifyPolicy("starting")
+	p := setupInterstitialProxy(t, pol, BackendOptions{})
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/health/stream", nil)
+	req.Host = "wake.com"
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content-type = %q, want text/event-stream", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var states []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		states = append(states, line)
+		if len(states) == 1 {
+			pol.set("ready")
+		}
+		if strings.Contains(line, `"ready"`) {
+			break
+		}
+	}
+	if len(states) < 2 || !strings.Contains(states[0], `"starting"`) {
+		t.Errorf("unexpected stream: %v", states)
+	}
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"warren/internal/services"
+)
+
+// echoPath replies with name and the path the backend saw.
+func echoPath(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + r.URL.Path))
+	}))
+}
+
+func registerPrefix(t *testing.T, p *Proxy, hostname, agent string, s *httptest.Server, pol *mockPolicy, opts BackendOptions) {
+	t.Helper()
+	u, _ := url.Parse(s.URL)
+	p.RegisterWithOptions(hostname, agent, u, pol, opts)
+}
+
+func get(p *Proxy, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.Host = host
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	return w
+}
+
+func TestPathPrefixRouting(t *testing.T) {
+	a, b, root := echoPath("a"), echoPath("b"), echoPath("root")
+	defer a.Close()
+	defer b.Close()
+	defer root.Close()
+
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	registerPrefix(t, p, "api.example.com", "agent-a", a, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-a/", StripPrefix: true})
+	registerPrefix(t, p, "api.example.com", "agent-b", b, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-b"})
+	registerPrefix(t, p, "api.example.com", "root", root, &mockPolicy{state: "ready"}, BackendOptions{})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/agent-a/chat", "a /chat"},
+		{"/agent-a", "a /"},
+		{"/agent-b/chat", "b /agent-b/chat"},
+		{"/agent-bx/chat", "root /agent-bx/chat"},
+		{"/other", "root /other"},
+	}
+	for _, tt := range tests {
+		w := get(p, "api.example.com", tt.path)
+		if body, _ := io.ReadAll(w.Result().Body); string(body) != tt.want {
+			t.Errorf("GET %s = %q, want %q", tt.path, body, tt.want)
+		}
+	}
+
+	if _, ok := p.Backends()["api.example.com/agent-a"]; !ok {
+		t.Error("expected route key api.example.com/agent-a in Backends()")
+	}
+}
+
+func TestPathPrefixNoRootRoute(t *testing.T) {
+	a := echoPath("a")
+	defer a.Close()
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	registerPrefix(t, p, "api.example.com", "agent-a", a, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-a"})
+
+	if w := get(p, "api.example.com", "/agent-b/x"); w.Code != 404 {
+		t.Errorf("unmatched prefix status = %d, want 404", w.Code)
+	}
+}
+
+func TestPathPrefixHealthAndActivity(t *testing.T) {
+	a, b := echoPath("a"), echoPath("b")
+	defer a.Close()
+	defer b.Close()
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	registerPrefix(t, p, "api.example.com", "agent-a", a, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-a"})
+	registerPrefix(t, p, "api.example.com", "agent-b", b, &mockPolicy{state: "sleeping"}, BackendOptions{PathPrefix: "/agent-b"})
+
+	// Each prefix gets its own health endpoint.
+	if w := get(p, "api.example.com", "/agent-a/api/health"); w.Code != 200 {
+		t.Errorf("agent-a health = %d, want 200", w.Code)
+	}
+	if w := get(p, "api.example.com", "/agent-b/api/health"); w.Code != 503 {
+		t.Errorf("agent-b health = %d, want 503", w.Code)
+	}
+
+	// Activity is tracked per route, not per hostname.
+	get(p, "api.example.com", "/agent-a/x")
+	if p.Activity().LastActivity("api.example.com/agent-a").IsZero() {
+		t.Error("expected activity for api.example.com/agent-a")
+	}
+	if !p.Activity().LastActivity("api.example.com/agent-b").IsZero() {
+		t.Error("unexpected activity for api.example.com/agent-b")
+	}
+}
+
+func TestDeregisterAgentKeepsSharedHostname(t *testing.T) {
+	a, b := echoPath("a"), echoPath("b")
+	defer a.Close()
+	defer b.Close()
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	registerPrefix(t, p, "api.example.com", "agent-a", a, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-a"})
+	registerPrefix(t, p, "api.example.com", "agent-b", b, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-b"})
+
+	p.DeregisterAgent("agent-a")
+
+	if w := get(p, "api.example.com", "/agent-a/x"); w.Code != 404 {
+		t.Errorf("agent-a status = %d, want 404 after DeregisterAgent", w.Code)
+	}
+	if w := get(p, "api.example.com", "/agent-b/x"); w.Code != 200 {
+		t.Errorf("agent-b status = %d, want 200", w.Code)
+	}
+}
+
+func TestDynamicServicePathPrefix(t *testing.T) {
+	configured, dynamic := echoPath("configured"), echoPath("dynamic")
+	defer configured.Close()
+	defer dynamic.Close()
+
+	registry := services.NewRegistry(testLogger())
+	p := New(registry, "", testLogger())
+	registerPrefix(t, p, "api.example.com", "agent-a", configured, &mockPolicy{state: "ready"}, BackendOptions{PathPrefix: "/agent-a"})
+
+	// The configured prefix is reserved, other prefixes are free.
+	if err := registry.RegisterWithOptions("api.example.com", dynamic.URL, "x", services.ServiceOptions{PathPrefix: "/agent-a"}); err == nil {
+		t.Error("expected reserved prefix to be rejected")
+	}
+	registry.RegisterUnsafe("api.example.com", dynamic.URL, "x")
+
+	if body, _ := io.ReadAll(get(p, "api.example.com", "/agent-a/x").Result().Body); string(body) != "configured /agent-a/x" {
+		t.Errorf("configured prefix body = %q", body)
+	}
+	if body, _ := io.ReadAll(get(p, "api.example.com", "/preview").Result().Body); string(body) != "dynamic /preview" {
+		t.Errorf("fallback body = %q", body)
+	}
+}
+
+func TestStripPathPrefixRawPath(t *testing.T) {
+	req := httptest.NewRequest("GET", "/agent-a/files/a%2Fb", nil)
+	r2 := stripPathPrefix(req, "/agent-a")
+	if r2.URL.Path != "/files/a/b" || r2.URL.RawPath != "/files/a%2Fb" {
+		t.Errorf("stripped = %q (raw %q)", r2.URL.Path, r2.URL.RawPath)
+	}
+	if req.URL.Path != "/agent-a/files/a/b" {
+		t.Errorf("original request modified: %q", req.URL.Path)
+	}
+}
@@ -1,75 +1,200 @@
 package proxy
 
 import (
+	"context"
 	"encoding/json"
+	"html/template"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"warren/internal/metrics"
 	"warren/internal/policy"
 	"warren/internal/services"
 )
 
+// HoldConfig enables parking requests while a backend cold-starts instead of
+// answering 503 straight away.
+type HoldConfig struct {
+	MaxQueue int           // max requests held at once (cold_start.max_queue defaults to 100); 0 = no limit
+	Timeout  time.Duration // how long a single request may be held
+}
+
+// BackendOptions holds optional per-backend behaviour.
+type BackendOptions struct {
+	Hold         *HoldConfig        // nil = reject with 503 while sleeping/starting
+	Interstitial *template.Template // nil = browsers get the JSON 503 too
+	PathPrefix   string             // only route requests under this path, "" = whole host
+	StripPrefix  bool               // remove PathPrefix before forwarding
+	Targets      []*url.URL         // additional replicas balanced alongside the primary target
+	Balancer     BalancerConfig
+}
+
 type Backend struct {
-	AgentName string
-	Target    *url.URL
-	Proxy     *httputil.ReverseProxy
-	Policy    policy.Policy
+	AgentName    string
+	Target       *url.URL
+	Proxy        *httputil.ReverseProxy
+	Policy       policy.Policy
+	Hold         *HoldConfig
+	Interstitial *template.Template
+	PathPrefix   string // normalized, "" = whole host
+	StripPrefix  bool
+	Pool         *Pool // all targets, including Target
+
+	held int64 // requests currently held
 }
 
+// relativePath returns path as seen below the backend's prefix, used to match
+// the proxy's own endpoints (/api/health, /api/wake) per backend.
+func (b *Backend) relativePath(path string) string {
+	if rel := strings.TrimPrefix(path, b.PathPrefix); rel != "" {
+		return rel
+	}
+	return "/"
+}
+
+// WildcardLabelHeader carries the labels matched by "*" to the backend when a
+// request is routed through a wildcard hostname.
+const WildcardLabelHeader = "X-Warren-Wildcard-Label"
+
 type Proxy struct {
-	backends  map[string]*Backend // hostname → backend
+	routes    atomic.Pointer[routeTable] // read lock-free on every request
+	routesMu  sync.Mutex                 // serializes route table writers
 	registry  *services.Registry
 	activity  *ActivityTracker
 	ws        *WSCounter
+	requests  *RequestCounter
 	authToken string
 	logger    *slog.Logger
 }
 
 func New(registry *services.Registry, authToken string, logger *slog.Logger) *Proxy {
-	return &Proxy{
-		backends:  make(map[string]*Backend),
+	p := &Proxy{
 		registry:  registry,
 		activity:  NewActivityTracker(),
 		ws:        NewWSCounter(),
+		requests:  NewRequestCounter(),
 		authToken: authToken,
 		logger:    logger,
 	}
+	p.routes.Store(newRouteTable())
+	return p
+}
+
+// updateRoutes applies fn to a copy of the route table and publishes the copy.
+// In-flight requests keep using the snapshot they loaded.
+func (p *Proxy) updateRoutes(fn func(t *routeTable)) {
+	p.routesMu.Lock()
+	defer p.routesMu.Unlock()
+	next := p.routes.Load().clone()
+	fn(next)
+	p.routes.Store(next)
 }
 
 func (p *Proxy) Register(hostname, agentName string, target *url.URL, pol policy.Policy) {
-	rp := httputil.NewSingleHostReverseProxy(target)
-	rp.FlushInterval = -1 // streaming/SSE support
+	p.RegisterWithOptions(hostname, agentName, target, pol, BackendOptions{})
+}
 
-	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		p.logger.Error("proxy error", "agent", agentName, "error", err)
-		http.Error(w, "bad gateway", http.StatusBadGateway)
+// RegisterWithOptions registers a backend with optional cold-start behaviour
+// and path-prefix routing. Registering the same hostname and prefix again
+// replaces the existing backend. Hostnames are matched case-insensitively.
+func (p *Proxy) RegisterWithOptions(hostname, agentName string, target *url.URL, pol policy.Policy, opts BackendOptions) {
+	hostname = strings.ToLower(hostname)
+	pool := p.poolFor(agentName, append([]*url.URL{target}, opts.Targets...), opts.Balancer)
+
+	prefix := services.NormalizePathPrefix(opts.PathPrefix)
+	backend := &Backend{
+		AgentName:    agentName,
+		Target:       target,
+		Proxy:        pool.targets.Load().list[0].Proxy,
+		Pool:         pool,
+		Policy:       pol,
+		Hold:         opts.Hold,
+		Interstitial: opts.Interstitial,
+		PathPrefix:   prefix,
+		StripPrefix:  opts.StripPrefix && prefix != "",
 	}
 
-	p.backends[hostname] = &Backend{
-		AgentName: agentName,
-		Target:    target,
-		Proxy:     rp,
-		Policy:    pol,
+	p.updateRoutes(func(t *routeTable) { t.add(hostname, backend) })
+
+	if services.IsWildcard(hostname) {
+		// Reserve the pattern so other agents can't register hostnames under it.
+		p.registry.ReserveWildcard(hostname, agentName)
+	} else {
+		// Reserve this route in the registry to prevent hijacking.
+		p.registry.ReserveRoute(hostname, prefix)
 	}
 
-	// Reserve this hostname in the registry to prevent hijacking.
-	p.registry.ReserveHostname(hostname)
+	p.logger.Info("registered backend", "hostname", hostname, "path_prefix", prefix, "agent", agentName, "target", target, "replicas", len(opts.Targets)+1)
+}
 
-	p.logger.Info("registered backend", "hostname", hostname, "agent", agentName, "target", target)
+// poolFor returns the pool already serving agentName's other routes if it has
+// the same targets and settings, so all of an agent's hostnames share ejection
+// state, or a new pool otherwise.
+func (p *Proxy) poolFor(agentName string, seeds []*url.URL, cfg BalancerConfig) *Pool {
+	for _, b := range p.Backends() {
+		if b.Pool.matches(agentName, seeds, cfg) {
+			return b.Pool
+		}
+	}
+	return newPool(agentName, seeds, cfg, p.logger)
 }
 
-// Deregister removes a backend by hostname.
+// Deregister removes every backend registered under a hostname or wildcard pattern.
 func (p *Proxy) Deregister(hostname string) {
-	delete(p.backends, hostname)
+	hostname = strings.ToLower(hostname)
+	var removed []*Backend
+	p.updateRoutes(func(t *routeTable) { removed = t.removeHost(hostname) })
+	p.release(hostname, removed)
 	p.logger.Info("deregistered backend", "hostname", hostname)
 }
 
-// Backends returns the backends map (for inspection by admin).
+// DeregisterAgent removes every route served by agentName, leaving other
+// agents' prefixes on shared hostnames in place.
+func (p *Proxy) DeregisterAgent(agentName string) {
+	var removed map[string][]*Backend
+	p.updateRoutes(func(t *routeTable) { removed = t.removeAgent(agentName) })
+	for hostname, backends := range removed {
+		p.release(hostname, backends)
+	}
+	p.logger.Info("deregistered agent routes", "agent", agentName)
+}
+
+// release drops the registry reservations made for backends removed from
+// hostname, so the routes can be registered dynamically again.
+func (p *Proxy) release(hostname string, removed []*Backend) {
+	for _, b := range removed {
+		if services.IsWildcard(hostname) {
+			p.registry.ReleaseWildcard(hostname, b.AgentName)
+		} else {
+			p.registry.ReleaseRoute(hostname, b.PathPrefix)
+		}
+	}
+}
+
+// Backends returns the backends keyed by hostname or wildcard pattern plus
+// path prefix (for inspection by admin).
 func (p *Proxy) Backends() map[string]*Backend {
-	return p.backends
+	t := p.routes.Load()
+	result := make(map[string]*Backend, len(t.exact)+len(t.wildcards))
+	for h, routes := range t.exact {
+		for _, b := range routes {
+			result[services.RouteKey(h, b.PathPrefix)] = b
+		}
+	}
+	for _, wr := range t.wildcards {
+		for _, b := range wr.routes {
+			result[services.RouteKey(wr.pattern, b.PathPrefix)] = b
+		}
+	}
+	return result
 }
 
 func (p *Proxy) Activity() *ActivityTracker {
@@ -80,6 +205,10 @@ func (p *Proxy) WSCounter() *WSCounter {
 	return p.ws
 }
 
+func (p *Proxy) Requests() *RequestCounter {
+	return p.requests
+}
+
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	hostname := stripPort(r.Host)
 
@@ -89,8 +218,15 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Allow health checks without auth.
-	isHealthCheck := r.URL.Path == "/api/health" && r.Method == http.MethodGet
+	backend, key, label, found := p.routes.Load().lookup(hostname, r.URL.Path)
+
+	// Allow health checks (and the interstitial's state stream) without auth.
+	// Behind a path prefix these live under the prefix, e.g. /agent-a/api/health.
+	path := r.URL.Path
+	if found {
+		path = backend.relativePath(path)
+	}
+	isHealthCheck := (path == "/api/health" || path == "/api/health/stream") && r.Method == http.MethodGet
 
 	// All other endpoints require auth.
 	if !isHealthCheck && p.authToken != "" {
@@ -100,14 +236,21 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Check configured backends first.
-	if backend, ok := p.backends[hostname]; ok {
-		p.serveBackend(w, r, hostname, backend)
+	// Never trust a client-supplied wildcard label.
+	r.Header.Del(WildcardLabelHeader)
+
+	// Check configured backends first. Activity and connections are tracked
+	// under the registered hostname (or pattern) so policies can see them.
+	if found {
+		if label != "" {
+			r.Header.Set(WildcardLabelHeader, label)
+		}
+		p.serveBackend(w, r, key, backend)
 		return
 	}
 
 	// Fallback: check the dynamic service registry.
-	if svc, ok := p.registry.Lookup(hostname); ok {
+	if svc, ok := p.registry.Match(hostname, r.URL.Path); ok {
 		p.serveDynamicService(w, r, hostname, svc)
 		return
 	}
@@ -116,14 +259,22 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (p *Proxy) serveBackend(w http.ResponseWriter, r *http.Request, hostname string, backend *Backend) {
+	path := backend.relativePath(r.URL.Path)
+
 	// Health endpoint — return agent status.
-	if r.URL.Path == "/api/health" && r.Method == http.MethodGet {
+	if path == "/api/health" && r.Method == http.MethodGet {
 		p.handleHealth(w, backend)
 		return
 	}
 
+	// State stream — used by the interstitial page to reload once ready.
+	if path == "/api/health/stream" && r.Method == http.MethodGet {
+		p.handleHealthStream(w, r, backend)
+		return
+	}
+
 	// Wake endpoint — trigger on-demand start.
-	if r.URL.Path == "/api/wake" && r.Method == http.MethodPost {
+	if path == "/api/wake" && r.Method == http.MethodPost {
 		backend.Policy.OnRequest()
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -132,28 +283,126 @@ func (p *Proxy) serveBackend(w http.ResponseWriter, r *http.Request, hostname st
 
 	backend.Policy.OnRequest()
 	p.activity.Touch(hostname)
+	p.requests.Inc(hostname)
+	metrics.AgentRequestsTotal.WithLabelValues(backend.AgentName).Inc()
 
-	// If the backend is sleeping or starting, return 503 instead of forwarding.
+	// If the backend is sleeping, starting, unready or draining, show browsers
+	// the interstitial, hold the request until it is ready (when configured),
+	// or return 503 instead of forwarding. A draining backend is on its way to
+	// sleep, so it takes no new connections and gets no "waking up" page.
 	state := backend.Policy.State()
-	if state == "sleeping" || state == "starting" {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Retry-After", "3")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		_ = json.NewEncoder(w).Encode(healthResponse{Status: state, Agent: backend.AgentName})
-		return
+	if state == "sleeping" || state == "starting" || state == "unready" || state == "draining" {
+		if state == "sleeping" && p.refuseWake(w, backend) {
+			return
+		}
+		if state != "draining" && backend.Interstitial != nil && wantsHTML(r) && !IsWebSocket(r) {
+			p.serveInterstitial(w, hostname, backend, state)
+			return
+		}
+		if !p.holdUntilReady(w, r, backend) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(healthResponse{Status: backend.Policy.State(), Agent: backend.AgentName})
+			return
+		}
+	}
+
+	if backend.StripPrefix {
+		r = stripPathPrefix(r, backend.PathPrefix)
 	}
 
-	// WebSocket passthrough.
+	target := backend.Pool.Pick(r)
+	defer backend.Pool.acquire(target)()
+
+	// WebSocket passthrough. A backend that can't be dialed counts towards
+	// ejection like a failed request.
 	if IsWebSocket(r) {
-		HandleWebSocket(w, r, backend.Target, hostname, p.ws, p.activity, p.logger)
+		if err := handleWebSocket(r.Context(), w, r, target.URL, hostname, p.ws, p.activity, p.logger); err != nil {
+			backend.Pool.reportFailure(target)
+		} else {
+			target.failures.Store(0)
+		}
 		return
 	}
 
-	backend.Proxy.ServeHTTP(w, r)
+	target.Proxy.ServeHTTP(w, r)
+}
+
+// Start runs background maintenance for backend pools — DNS re-resolution
+// and probing of ejected targets — until ctx is cancelled.
+func (p *Proxy) Start(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			seen := make(map[*Pool]bool)
+			for _, b := range p.Backends() {
+				if !seen[b.Pool] {
+					seen[b.Pool] = true
+					b.Pool.maintain(ctx, now)
+				}
+			}
+		}
+	}
+}
+
+// holdUntilReady parks the request until the backend's policy leaves the
+// sleeping/starting states, the hold timeout expires, or the client goes away.
+// Returns false if the request was not held or the backend didn't come up in time.
+// Requests for a draining backend are not held: it is on its way to sleep and
+// won't become ready without another wake.
+func (p *Proxy) holdUntilReady(w http.ResponseWriter, r *http.Request, b *Backend) bool {
+	if b.Hold == nil || b.Policy.State() == "draining" {
+		return false
+	}
+	if _, ok := b.Policy.(policy.StateNotifier); !ok {
+		return false
+	}
+
+	if n := atomic.AddInt64(&b.held, 1); b.Hold.MaxQueue > 0 && n > int64(b.Hold.MaxQueue) {
+		atomic.AddInt64(&b.held, -1)
+		metrics.ProxyHeldRequestsTotal.WithLabelValues(b.AgentName, "rejected").Inc()
+		p.logger.Warn("hold queue full, rejecting request", "agent", b.AgentName, "max_queue", b.Hold.MaxQueue)
+		return false
+	}
+	defer atomic.AddInt64(&b.held, -1)
+
+	metrics.ProxyRequestsHeld.WithLabelValues(b.AgentName).Inc()
+	defer metrics.ProxyRequestsHeld.WithLabelValues(b.AgentName).Dec()
+
+	ctx, cancel := context.WithTimeout(r.Context(), b.Hold.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	state, err := policy.WaitForState(ctx, b.Policy, "ready", "degraded")
+	if err != nil {
+		metrics.ProxyHeldRequestsTotal.WithLabelValues(b.AgentName, "expired").Inc()
+		p.logger.Warn("held request expired", "agent", b.AgentName, "state", state, "waited", time.Since(start), "error", err)
+		return false
+	}
+	if state == "degraded" {
+		// The backend failed to come up; forwarding would only hit a dead target.
+		metrics.ProxyHeldRequestsTotal.WithLabelValues(b.AgentName, "failed").Inc()
+		p.logger.Warn("held request failed, backend degraded", "agent", b.AgentName, "waited", time.Since(start))
+		return false
+	}
+
+	metrics.ProxyHeldRequestsTotal.WithLabelValues(b.AgentName, "forwarded").Inc()
+	p.logger.Info("held request released", "agent", b.AgentName, "state", state, "waited", time.Since(start))
+
+	// The server's read deadline was armed when the request arrived; clear it
+	// so the body can still be read after a long hold.
+	_ = http.NewResponseController(w).SetReadDeadline(time.Time{})
+	return true
 }
 
 func (p *Proxy) serveDynamicService(w http.ResponseWriter, r *http.Request, hostname string, svc *services.Service) {
+	hostname = services.RouteKey(svc.Hostname, svc.PathPrefix)
 	p.activity.Touch(hostname)
 
 	// Use cached TargetURL and Proxy from registration (L2).
@@ -163,15 +412,15 @@ func (p *Proxy) serveDynamicService(w http.ResponseWriter, r *http.Request, host
 		return
 	}
 
+	if svc.StripPrefix {
+		r = stripPathPrefix(r, svc.PathPrefix)
+	}
+
 	if IsWebSocket(r) {
 		HandleWebSocket(w, r, svc.TargetURL, hostname, p.ws, p.activity, p.logger)
 		return
 	}
 
-	svc.Proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		p.logger.Error("dynamic service proxy error", "hostname", hostname, "error", err)
-		http.Error(w, "bad gateway", http.StatusBadGateway)
-	}
 	svc.Proxy.ServeHTTP(w, r)
 }
 
@@ -187,9 +436,11 @@ func (p *Proxy) HandleServiceAPI(w http.ResponseWriter, r *http.Request) {
 		// Limit request body to 1MB to prevent memory exhaustion.
 		r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
 		var req struct {
-			Hostname string `json:"hostname"`
-			Target   string `json:"target"`
-			Agent    string `json:"agent"`
+			Hostname    string `json:"hostname"`
+			PathPrefix  string `json:"path_prefix"`
+			StripPrefix bool   `json:"strip_prefix"`
+			Target      string `json:"target"`
+			Agent       string `json:"agent"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, `{"error":"invalid json"}`, http.StatusBadRequest)
@@ -199,7 +450,8 @@ func (p *Proxy) HandleServiceAPI(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, `{"error":"hostname and target required"}`, http.StatusBadRequest)
 			return
 		}
-		if err := p.registry.Register(req.Hostname, req.Target, req.Agent); err != nil {
+		opts := services.ServiceOptions{PathPrefix: req.PathPrefix, StripPrefix: req.StripPrefix}
+		if err := p.registry.RegisterWithOptions(req.Hostname, req.Target, req.Agent, opts); err != nil {
 			http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
 			return
 		}
@@ -212,7 +464,12 @@ func (p *Proxy) HandleServiceAPI(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, `{"error":"hostname required"}`, http.StatusBadRequest)
 			return
 		}
-		p.registry.Deregister(hostname)
+		// ?path_prefix= removes a single prefix route; otherwise the whole hostname goes.
+		if r.URL.Query().Has("path_prefix") {
+			p.registry.DeregisterRoute(hostname, r.URL.Query().Get("path_prefix"))
+		} else {
+			p.registry.Deregister(hostname)
+		}
 		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 
 	default:
@@ -223,6 +480,30 @@ func (p *Proxy) HandleServiceAPI(w http.ResponseWriter, r *http.Request) {
 type healthResponse struct {
 	Status string `json:"status"`
 	Agent  string `json:"agent"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// refuseWake answers with 503 and the policy's reason if the sleeping backend
+// won't wake right now. Retry-After points at when wakes are accepted again.
+func (p *Proxy) refuseWake(w http.ResponseWriter, b *Backend) bool {
+	refuser, ok := b.Policy.(policy.WakeRefuser)
+	if !ok {
+		return false
+	}
+	reason, until := refuser.WakeRefused()
+	if reason == "" {
+		return false
+	}
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(healthResponse{Status: "sleeping", Agent: b.AgentName, Reason: reason})
+	return true
 }
 
 func (p *Proxy) handleHealth(w http.ResponseWriter, b *Backend) {
@@ -236,13 +517,39 @@ func (p *Proxy) handleHealth(w http.ResponseWriter, b *Backend) {
 		status = http.StatusServiceUnavailable
 	}
 
+	var reason string
+	if refuser, ok := b.Policy.(policy.WakeRefuser); ok && state == "sleeping" {
+		reason, _ = refuser.WakeRefused()
+	}
+
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(healthResponse{
 		Status: state,
 		Agent:  b.AgentName,
+		Reason: reason,
 	})
 }
 
+// stripPathPrefix returns a shallow copy of r with prefix removed from the URL
+// path, like http.StripPrefix.
+func stripPathPrefix(r *http.Request, prefix string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+	if r2.URL.Path == "" {
+		r2.URL.Path = "/"
+	}
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		if r2.URL.RawPath == "" {
+			r2.URL.RawPath = "/"
+		}
+	}
+	return r2
+}
+
 func stripPort(host string) string {
 	if i := strings.LastIndex(host, ":"); i != -1 {
 		return host[:i]
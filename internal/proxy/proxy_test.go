@@ -128,6 +128,46 @@ func TestStartingReturns503(t *testing.T) {
 	}
 }
 
+func TestUnreadyReturns503(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unready backend should not receive requests")
+	}))
+	defer s.Close()
+	p := setupProxy(t, map[string]*mockBackendInfo{
+		"a.com": {server: s, agentName: "a", policy: &mockPolicy{state: "unready"}},
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "a.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestDrainingReturns503WithoutInterstitial(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("draining backend should not receive new requests")
+	}))
+	defer s.Close()
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	u, _ := url.Parse(s.URL)
+	p.RegisterWithOptions("a.com", "a", u, &mockPolicy{state: "draining"}, BackendOptions{Interstitial: DefaultInterstitial})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "a.com"
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 503 {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("content-type = %q, want application/json", ct)
+	}
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
 	defer s.Close()
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"warren/internal/services"
+)
+
+// refusingPolicy is a sleeping policy that declines wakes, like an on-demand
+// agent inside quiet hours.
+type refusingPolicy struct {
+	mockPolicy
+	until time.Time
+}
+
+func (m *refusingPolicy) WakeRefused() (string, time.Time) { return "quiet hours", m.until }
+
+func TestSleepingRefusedWakeReturnsReason(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+
+	p := New(services.NewRegistry(testLogger()), "", testLogger())
+	u, _ := url.Parse(s.URL)
+	pol := &refusingPolicy{mockPolicy: mockPolicy{state: "sleeping"}, until: time.Now().Add(2 * time.Hour)}
+	p.RegisterWithOptions("a.com", "a", u, pol, BackendOptions{Interstitial: DefaultInterstitial})
+
+	// Browsers get the refusal too rather than a "waking up" page that never wakes.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "a.com"
+	req.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	var body healthResponse
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Reason != "quiet hours" || body.Status != "sleeping" {
+		t.Errorf("body = %+v", body)
+	}
+	retry, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+	if retry < 7000 || retry > 7200 {
+		t.Errorf("Retry-After = %q, want ~7200", w.Header().Get("Retry-After"))
+	}
+}
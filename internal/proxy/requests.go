@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RequestCounter counts proxied requests per hostname. Counts only grow;
+// callers derive rates by sampling twice.
+type RequestCounter struct {
+	counts sync.Map // hostname → *uint64
+}
+
+func NewRequestCounter() *RequestCounter {
+	return &RequestCounter{}
+}
+
+func (c *RequestCounter) Inc(hostname string) {
+	v, _ := c.counts.LoadOrStore(hostname, new(uint64))
+	atomic.AddUint64(v.(*uint64), 1)
+}
+
+// Requests returns the total number of requests seen for hostname.
+func (c *RequestCounter) Requests(hostname string) uint64 {
+	v, ok := c.counts.Load(hostname)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadUint64(v.(*uint64))
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestCounter(t *testing.T) {
+	c := NewRequestCounter()
+	if got := c.Requests("a.com"); got != 0 {
+		t.Errorf("unknown hostname = %d, want 0", got)
+	}
+	c.Inc("a.com")
+	c.Inc("a.com")
+	c.Inc("b.com")
+	if got := c.Requests("a.com"); got != 2 {
+		t.Errorf("a.com = %d, want 2", got)
+	}
+	if got := c.Requests("b.com"); got != 1 {
+		t.Errorf("b.com = %d, want 1", got)
+	}
+}
+
+func TestProxyCountsRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	p := setupProxy(t, map[string]*mockBackendInfo{
+		"a.example.com": {server: srv, agentName: "agent-a", policy: &mockPolicy{state: "ready"}},
+	})
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = "a.example.com"
+		p.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := p.Requests().Requests("a.example.com"); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"warren/internal/services"
+)
+
+// routeTable is an immutable snapshot of the proxy's routes. Once published it
+// is never modified: writers clone the current table, change the copy and swap
+// it in atomically, so request handling reads it without locks.
+type routeTable struct {
+	exact     map[string][]*Backend // hostname → routes, longest prefix first
+	wildcards []wildcardRoute       // longest suffix first
+}
+
+// wildcardRoute holds the backends registered under a wildcard hostname pattern.
+type wildcardRoute struct {
+	pattern string
+	routes  []*Backend // longest prefix first
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{exact: make(map[string][]*Backend)}
+}
+
+// clone returns a copy that can be modified without affecting t. Route slices
+// are shared but never mutated in place; the helpers below always reallocate.
+func (t *routeTable) clone() *routeTable {
+	c := &routeTable{
+		exact:     make(map[string][]*Backend, len(t.exact)),
+		wildcards: slices.Clone(t.wildcards),
+	}
+	for h, routes := range t.exact {
+		c.exact[h] = routes
+	}
+	return c
+}
+
+func (t *routeTable) add(hostname string, b *Backend) {
+	if !services.IsWildcard(hostname) {
+		t.exact[hostname] = addRoute(t.exact[hostname], b)
+		return
+	}
+	i := t.wildcardIndex(hostname)
+	if i < 0 {
+		t.wildcards = append(t.wildcards, wildcardRoute{pattern: hostname})
+		sort.SliceStable(t.wildcards, func(i, j int) bool {
+			return len(t.wildcards[i].pattern) > len(t.wildcards[j].pattern)
+		})
+		i = t.wildcardIndex(hostname)
+	}
+	t.wildcards[i].routes = addRoute(t.wildcards[i].routes, b)
+}
+
+// removeHost removes every backend under hostname and returns them.
+func (t *routeTable) removeHost(hostname string) []*Backend {
+	if !services.IsWildcard(hostname) {
+		removed := t.exact[hostname]
+		delete(t.exact, hostname)
+		return removed
+	}
+	if i := t.wildcardIndex(hostname); i >= 0 {
+		removed := t.wildcards[i].routes
+		t.wildcards = slices.Delete(t.wildcards, i, i+1)
+		return removed
+	}
+	return nil
+}
+
+// removeAgent removes agentName's backends and returns them by hostname or
+// pattern.
+func (t *routeTable) removeAgent(agentName string) map[string][]*Backend {
+	removed := make(map[string][]*Backend)
+	split := func(hostname string, routes []*Backend) []*Backend {
+		var kept []*Backend
+		for _, b := range routes {
+			if b.AgentName == agentName {
+				removed[hostname] = append(removed[hostname], b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		return kept
+	}
+	for h, routes := range t.exact {
+		if routes = split(h, routes); len(routes) == 0 {
+			delete(t.exact, h)
+		} else {
+			t.exact[h] = routes
+		}
+	}
+	kept := make([]wildcardRoute, 0, len(t.wildcards))
+	for _, wr := range t.wildcards {
+		if wr.routes = split(wr.pattern, wr.routes); len(wr.routes) > 0 {
+			kept = append(kept, wr)
+		}
+	}
+	t.wildcards = kept
+	return removed
+}
+
+func (t *routeTable) wildcardIndex(pattern string) int {
+	for i, wr := range t.wildcards {
+		if wr.pattern == pattern {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup resolves a request hostname and path to a backend. Exact hostnames
+// win over wildcards, among wildcards the longest suffix wins, and within a
+// hostname the longest path prefix wins. It returns the route key the backend
+// is registered under and, for wildcard matches, the labels captured by "*"
+// as the client sent them. Hostnames match case-insensitively.
+func (t *routeTable) lookup(hostname, path string) (backend *Backend, key, label string, ok bool) {
+	lower := strings.ToLower(hostname)
+	if b := matchRoute(t.exact[lower], path); b != nil {
+		return b, services.RouteKey(lower, b.PathPrefix), "", true
+	}
+	for _, wr := range t.wildcards {
+		label, ok := services.MatchWildcard(wr.pattern, hostname)
+		if !ok {
+			continue
+		}
+		if b := matchRoute(wr.routes, path); b != nil {
+			return b, services.RouteKey(wr.pattern, b.PathPrefix), label, true
+		}
+	}
+	return nil, "", "", false
+}
+
+// addRoute returns a new slice with b inserted, replacing any backend with the
+// same prefix and keeping the list ordered longest prefix first.
+func addRoute(routes []*Backend, b *Backend) []*Backend {
+	routes = slices.DeleteFunc(slices.Clone(routes), func(existing *Backend) bool {
+		return existing.PathPrefix == b.PathPrefix
+	})
+	routes = append(routes, b)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+	return routes
+}
+
+// matchRoute returns the backend with the longest prefix matching path.
+func matchRoute(routes []*Backend, path string) *Backend {
+	for _, b := range routes {
+		if services.MatchPathPrefix(b.PathPrefix, path) {
+			return b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"warren/internal/policy"
+	"warren/internal/services"
+)
+
+func TestRouteTableCloneIsolated(t *testing.T) {
+	a := &Backend{AgentName: "a"}
+	b := &Backend{AgentName: "b", PathPrefix: "/b"}
+
+	t1 := newRouteTable()
+	t1.add("x.com", a)
+	t1.add("*.example.com", a)
+
+	t2 := t1.clone()
+	t2.add("x.com", b)
+	t2.add("*.example.com", b)
+	t2.removeAgent("a")
+
+	if got := len(t1.exact["x.com"]); got != 1 {
+		t.Errorf("original exact routes = %d, want 1", got)
+	}
+	if got := len(t1.wildcards[0].routes); got != 1 {
+		t.Errorf("original wildcard routes = %d, want 1", got)
+	}
+	if b, _, _, ok := t2.lookup("x.com", "/b/y"); !ok || b.AgentName != "b" {
+		t.Errorf("clone lookup = %v, want agent b", b)
+	}
+}
+
+// TestConcurrentRouteUpdates hammers the proxy with requests while agents are
+// added, removed and reloaded. Run with -race.
+func TestConcurrentRouteUpdates(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	registry := services.NewRegistry(testLogger())
+	p := New(registry, "", testLogger())
+	p.Register("stable.com", "stable", u, policy.NewUnmanaged())
+
+	const (
+		writers    = 4
+		readers    = 8
+		iterations = 200
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				agent := fmt.Sprintf("agent-%d", w)
+				host := fmt.Sprintf("%s.com", agent)
+				switch i % 4 {
+				case 0:
+					p.Register(host, agent, u, policy.NewUnmanaged())
+				case 1:
+					p.RegisterWithOptions("shared.com", agent, u, policy.NewUnmanaged(), BackendOptions{PathPrefix: "/" + agent})
+					p.Register("*."+host, agent, u, policy.NewUnmanaged())
+				case 2:
+					// Simulates a reload removing the agent.
+					p.DeregisterAgent(agent)
+				case 3:
+					p.Deregister(host)
+					registry.RegisterUnsafe("dyn-"+host, s.URL, agent)
+					registry.DeregisterByAgent(agent)
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			hosts := []string{"stable.com", "agent-0.com", "x.agent-1.com", "shared.com", "dyn-agent-2.com"}
+			for i := 0; i < iterations; i++ {
+				req := httptest.NewRequest("GET", "/agent-0/x", nil)
+				req.Host = hosts[(r+i)%len(hosts)]
+				p.ServeHTTP(httptest.NewRecorder(), req)
+				_ = p.Backends()
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "stable.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Errorf("stable backend status = %d, want 200", w.Code)
+	}
+}
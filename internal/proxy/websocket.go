@@ -132,7 +132,9 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request, backend *url.URL, h
 	handleWebSocket(r.Context(), w, r, backend, hostname, ws, activity, logger)
 }
 
-func handleWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request, backend *url.URL, hostname string, ws *WSCounter, activity *ActivityTracker, logger *slog.Logger) {
+// handleWebSocket relays a WebSocket between the client and backend. It
+// returns an error only if the backend could not be dialed.
+func handleWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request, backend *url.URL, hostname string, ws *WSCounter, activity *ActivityTracker, logger *slog.Logger) error {
 	// Dial the backend.
 	backendAddr := backend.Host
 	if !strings.Contains(backendAddr, ":") {
@@ -147,7 +149,7 @@ func handleWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request
 	if err != nil {
 		logger.Error("websocket: failed to dial backend", "error", err, "backend", backendAddr)
 		http.Error(w, "bad gateway", http.StatusBadGateway)
-		return
+		return err
 	}
 
 	// Hijack the client connection.
@@ -155,13 +157,13 @@ func handleWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request
 	if !ok {
 		backConn.Close()
 		http.Error(w, "websocket hijack not supported", http.StatusInternalServerError)
-		return
+		return nil
 	}
 	clientConn, clientBuf, err := hj.Hijack()
 	if err != nil {
 		backConn.Close()
 		logger.Error("websocket: hijack failed", "error", err)
-		return
+		return nil
 	}
 
 	// Wrap connections with deadline enforcement to prevent slow-read/slow-write attacks.
@@ -173,7 +175,7 @@ func handleWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request
 		clientConn.Close()
 		backConn.Close()
 		logger.Error("websocket: failed to write request to backend", "error", err)
-		return
+		return nil
 	}
 
 	ws.Inc(hostname)
@@ -207,4 +209,5 @@ func handleWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request
 
 	wg.Wait()
 	ws.Dec(hostname)
+	return nil
 }
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWildcardRouting(t *testing.T) {
+	named := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + ":" + r.Header.Get(WildcardLabelHeader)))
+		}))
+	}
+	exact, broad, narrow := named("exact"), named("broad"), named("narrow")
+	defer exact.Close()
+	defer broad.Close()
+	defer narrow.Close()
+
+	p := setupProxy(t, map[string]*mockBackendInfo{
+		"api.dev.example.com": {server: exact, agentName: "exact", policy: &mockPolicy{state: "ready"}},
+		"*.example.com":       {server: broad, agentName: "broad", policy: &mockPolicy{state: "ready"}},
+		"*.dev.example.com":   {server: narrow, agentName: "narrow", policy: &mockPolicy{state: "ready"}},
+	})
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"api.dev.example.com", "exact:"},
+		{"API.Dev.Example.com", "exact:"},
+		{"feature-x.dev.example.com", "narrow:feature-x"},
+		{"a.b.dev.example.com", "narrow:a.b"},
+		{"www.example.com", "broad:www"},
+		{"Shop.Example.com", "broad:Shop"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, req)
+		body, _ := io.ReadAll(w.Result().Body)
+		if string(body) != tt.want {
+			t.Errorf("host %q: got %q, want %q", tt.host, body, tt.want)
+		}
+	}
+
+	// The bare suffix is not matched by its own wildcard.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "example.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("bare suffix status = %d, want 404", w.Code)
+	}
+}
+
+func TestWildcardLabelHeaderNotSpoofable(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(WildcardLabelHeader)))
+	}))
+	defer s.Close()
+	p := setupProxy(t, map[string]*mockBackendInfo{
+		"a.com": {server: s, agentName: "a", policy: &mockPolicy{state: "ready"}},
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "a.com"
+	req.Header.Set(WildcardLabelHeader, "evil")
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if body, _ := io.ReadAll(w.Result().Body); len(body) != 0 {
+		t.Errorf("client-supplied label forwarded: %q", body)
+	}
+}
+
+func TestWildcardDeregister(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s.Close()
+	p := setupProxy(t, map[string]*mockBackendInfo{
+		"*.example.com": {server: s, agentName: "w", policy: &mockPolicy{state: "ready"}},
+	})
+	if _, ok := p.Backends()["*.example.com"]; !ok {
+		t.Fatal("expected wildcard in Backends()")
+	}
+
+	p.Deregister("*.example.com")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "x.example.com"
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("status = %d, want 404 after deregister", w.Code)
+	}
+}
@@ -34,6 +34,43 @@ func ValidateHostname(hostname string) error {
 	return nil
 }
 
+// ValidateHostnamePattern validates a hostname that may be a wildcard pattern.
+// A leading "*." matches one or more labels in front of the suffix, which must
+// itself be a valid hostname with at least two labels (so "*.com" is rejected).
+func ValidateHostnamePattern(pattern string) error {
+	suffix, ok := strings.CutPrefix(pattern, "*.")
+	if !ok {
+		return ValidateHostname(pattern)
+	}
+	if err := ValidateHostname(suffix); err != nil {
+		return fmt.Errorf("wildcard suffix: %w", err)
+	}
+	if !strings.Contains(suffix, ".") {
+		return fmt.Errorf("wildcard suffix %q is too broad", suffix)
+	}
+	return nil
+}
+
+// ValidatePathPrefix validates a route path prefix. An empty prefix covers the
+// whole host; anything else must be an absolute, clean URL path.
+func ValidatePathPrefix(prefix string) error {
+	if prefix == "" || prefix == "/" {
+		return nil
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("path prefix %q must start with /", prefix)
+	}
+	if strings.ContainsAny(prefix, "?#%") {
+		return fmt.Errorf("path prefix %q contains invalid characters", prefix)
+	}
+	for _, seg := range strings.Split(strings.TrimSuffix(prefix[1:], "/"), "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("path prefix %q is not a clean path", prefix)
+		}
+	}
+	return nil
+}
+
 // ValidateWebhookURL validates a webhook URL, rejecting private/internal IPs (SSRF protection).
 func ValidateWebhookURL(rawURL string) error {
 	u, err := url.Parse(rawURL)
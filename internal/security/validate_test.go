@@ -35,6 +35,49 @@ func TestValidateHostname(t *testing.T) {
 	}
 }
 
+func TestValidateHostnamePattern(t *testing.T) {
+	valid := []string{"example.com", "*.example.com", "*.dev.example.com"}
+	for _, h := range valid {
+		if err := ValidateHostnamePattern(h); err != nil {
+			t.Errorf("ValidateHostnamePattern(%q) = %v, want nil", h, err)
+		}
+	}
+
+	invalid := []struct {
+		host    string
+		wantErr string
+	}{
+		{"*", "invalid characters"},
+		{"*.com", "too broad"},
+		{"foo.*.com", "invalid characters"},
+		{"*foo.example.com", "invalid characters"},
+		{"*.*.example.com", "wildcard suffix"},
+	}
+	for _, tt := range invalid {
+		if err := ValidateHostnamePattern(tt.host); err == nil {
+			t.Errorf("ValidateHostnamePattern(%q) = nil, want error containing %q", tt.host, tt.wantErr)
+		} else if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("ValidateHostnamePattern(%q) = %v, want error containing %q", tt.host, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePathPrefix(t *testing.T) {
+	valid := []string{"", "/", "/agent-a", "/agent-a/", "/api/v1"}
+	for _, p := range valid {
+		if err := ValidatePathPrefix(p); err != nil {
+			t.Errorf("ValidatePathPrefix(%q) = %v, want nil", p, err)
+		}
+	}
+
+	invalid := []string{"agent-a", "//agent-a", "/a//b", "/a/../b", "/./a", "/a?x=1", "/a#frag", "/a%2Fb"}
+	for _, p := range invalid {
+		if err := ValidatePathPrefix(p); err == nil {
+			t.Errorf("ValidatePathPrefix(%q) = nil, want error", p)
+		}
+	}
+}
+
 func TestValidateWebhookURL(t *testing.T) {
 	// Valid public URLs should pass.
 	if err := ValidateWebhookURL("https://hooks.slack.com/foo"); err != nil {
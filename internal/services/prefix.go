@@ -0,0 +1,33 @@
+package services
+
+import "strings"
+
+// NormalizePathPrefix canonicalizes a route path prefix: "" and "/" both mean
+// the whole host and normalize to "", anything else gets a leading slash and
+// loses its trailing one ("/agent-a/" → "/agent-a").
+func NormalizePathPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
+}
+
+// MatchPathPrefix reports whether path falls under a normalized prefix. The
+// prefix only matches on segment boundaries, so "/agent" does not match
+// "/agent-b/x".
+func MatchPathPrefix(prefix, path string) bool {
+	if prefix == "" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// RouteKey identifies a hostname + normalized path prefix route. For routes
+// covering the whole host it is just the hostname.
+func RouteKey(hostname, prefix string) string {
+	return hostname + prefix
+}
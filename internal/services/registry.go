@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,27 +17,38 @@ import (
 
 // Service represents a dynamically registered route.
 type Service struct {
-	Hostname  string               `json:"hostname"`
-	Target    string               `json:"target"`
-	Agent     string               `json:"agent"`
-	CreatedAt time.Time            `json:"created_at"`
-	TargetURL *url.URL             `json:"-"`
-	Proxy     *httputil.ReverseProxy `json:"-"`
+	Hostname    string               `json:"hostname"`
+	PathPrefix  string               `json:"path_prefix,omitempty"`
+	StripPrefix bool                 `json:"strip_prefix,omitempty"`
+	Target      string               `json:"target"`
+	Agent       string               `json:"agent"`
+	CreatedAt   time.Time            `json:"created_at"`
+	TargetURL   *url.URL             `json:"-"`
+	Proxy       *httputil.ReverseProxy `json:"-"`
 }
 
-// Registry holds ephemeral service routes registered by agents.
+// ServiceOptions holds optional routing settings for a dynamic service.
+type ServiceOptions struct {
+	PathPrefix  string // only route requests under this path, "" = whole host
+	StripPrefix bool   // remove PathPrefix before forwarding
+}
+
+// Registry holds ephemeral service routes registered by agents. Hostnames
+// are case-insensitive and stored lowercased.
 type Registry struct {
 	mu               sync.RWMutex
-	services         map[string]*Service // hostname → service
-	reservedHosts    map[string]bool     // hostnames reserved by configured backends
+	services         map[string][]*Service // hostname → routes, longest prefix first
+	reservedHosts    map[string]bool     // route keys reserved by configured backends
+	wildcards        map[string]string   // wildcard pattern → owning agent
 	logger           *slog.Logger
 }
 
 // NewRegistry creates a new service registry.
 func NewRegistry(logger *slog.Logger) *Registry {
 	return &Registry{
-		services:      make(map[string]*Service),
+		services:      make(map[string][]*Service),
 		reservedHosts: make(map[string]bool),
+		wildcards:     make(map[string]string),
 		logger:        logger.With("component", "service-registry"),
 	}
 }
@@ -43,19 +56,101 @@ func NewRegistry(logger *slog.Logger) *Registry {
 // ReserveHostname marks a hostname as reserved (used by configured backends).
 // Reserved hostnames cannot be registered dynamically.
 func (r *Registry) ReserveHostname(hostname string) {
+	r.ReserveRoute(hostname, "")
+}
+
+// ReserveRoute marks a hostname + path prefix as reserved. Other prefixes under
+// the same hostname can still be registered dynamically.
+func (r *Registry) ReserveRoute(hostname, prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reservedHosts[RouteKey(strings.ToLower(hostname), NormalizePathPrefix(prefix))] = true
+}
+
+// ReleaseRoute drops a reservation made by ReserveRoute.
+func (r *Registry) ReleaseRoute(hostname, prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.reservedHosts, RouteKey(strings.ToLower(hostname), NormalizePathPrefix(prefix)))
+}
+
+// ReserveWildcard marks a wildcard pattern as owned by agent. Hostnames matching
+// the pattern can then only be registered dynamically by that agent.
+func (r *Registry) ReserveWildcard(pattern, agent string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wildcards[strings.ToLower(pattern)] = agent
+}
+
+// ReleaseWildcard drops agent's reservation of a wildcard pattern. A pattern
+// since reserved by another agent is left alone.
+func (r *Registry) ReleaseWildcard(pattern, agent string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.reservedHosts[hostname] = true
+	pattern = strings.ToLower(pattern)
+	if r.wildcards[pattern] == agent {
+		delete(r.wildcards, pattern)
+	}
+}
+
+// hasReservedPrefix reports whether a configured backend owns a path prefix
+// under hostname. Caller must hold r.mu.
+func (r *Registry) hasReservedPrefix(hostname string) bool {
+	for key := range r.reservedHosts {
+		if strings.HasPrefix(key, hostname+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardOwner returns the most specific reserved wildcard matching hostname
+// and its owning agent. Caller must hold r.mu.
+func (r *Registry) wildcardOwner(hostname string) (pattern, agent string) {
+	for p, owner := range r.wildcards {
+		if _, ok := MatchWildcard(p, hostname); ok && len(p) > len(pattern) {
+			pattern, agent = p, owner
+		}
+	}
+	return pattern, agent
 }
 
 // Register adds an ephemeral route. Returns an error if the hostname is reserved
 // or the target URL is not allowed.
 func (r *Registry) Register(hostname, target, agent string) error {
+	return r.RegisterWithOptions(hostname, target, agent, ServiceOptions{})
+}
+
+// RegisterWithOptions adds an ephemeral route, optionally limited to a path
+// prefix under the hostname. Registering the same hostname and prefix again
+// replaces the existing route.
+func (r *Registry) RegisterWithOptions(hostname, target, agent string, opts ServiceOptions) error {
+	return r.register(hostname, target, agent, opts, time.Now())
+}
+
+// Restore re-registers a service saved from an earlier run, keeping its
+// creation time. It is validated like a new registration.
+func (r *Registry) Restore(svc Service) error {
+	return r.register(svc.Hostname, svc.Target, svc.Agent, ServiceOptions{
+		PathPrefix:  svc.PathPrefix,
+		StripPrefix: svc.StripPrefix,
+	}, svc.CreatedAt)
+}
+
+func (r *Registry) register(hostname, target, agent string, opts ServiceOptions, createdAt time.Time) error {
 	// Validate hostname format (L3).
 	if err := security.ValidateHostname(hostname); err != nil {
 		r.logger.Warn("service registration rejected: invalid hostname", "hostname", hostname, "error", err)
 		return fmt.Errorf("invalid hostname: %w", err)
 	}
+	hostname = strings.ToLower(hostname)
+
+	if err := security.ValidatePathPrefix(opts.PathPrefix); err != nil {
+		r.logger.Warn("service registration rejected: invalid path prefix", "hostname", hostname, "path_prefix", opts.PathPrefix, "error", err)
+		return fmt.Errorf("invalid path prefix: %w", err)
+	}
+	prefix := NormalizePathPrefix(opts.PathPrefix)
+	key := RouteKey(hostname, prefix)
 
 	// Validate target URL to prevent SSRF.
 	if err := validateTarget(target); err != nil {
@@ -68,30 +163,73 @@ func (r *Registry) Register(hostname, target, agent string) error {
 	if err != nil {
 		return fmt.Errorf("invalid target URL: %w", err)
 	}
-	rp := httputil.NewSingleHostReverseProxy(targetURL)
-	rp.FlushInterval = -1
+	rp := r.newProxy(hostname, targetURL)
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Prevent overwriting configured backend hostnames.
-	if r.reservedHosts[hostname] {
-		r.logger.Warn("service registration rejected: hostname reserved", "hostname", hostname)
-		return fmt.Errorf("hostname %q is reserved", hostname)
+	// Prevent overwriting configured backend routes.
+	if r.reservedHosts[key] {
+		r.logger.Warn("service registration rejected: hostname reserved", "hostname", hostname, "path_prefix", prefix)
+		return fmt.Errorf("hostname %q is reserved", key)
+	}
+	// A whole-host route would take every path the configured prefixes on
+	// the hostname don't claim.
+	if prefix == "" && r.hasReservedPrefix(hostname) {
+		r.logger.Warn("service registration rejected: hostname has reserved path prefixes", "hostname", hostname)
+		return fmt.Errorf("hostname %q has reserved path prefixes; register under a path prefix", hostname)
 	}
 
-	r.services[hostname] = &Service{
-		Hostname:  hostname,
-		Target:    target,
-		Agent:     agent,
-		CreatedAt: time.Now(),
-		TargetURL: targetURL,
-		Proxy:     rp,
+	// Prevent claiming hostnames covered by another agent's wildcard.
+	if pattern, owner := r.wildcardOwner(hostname); pattern != "" && owner != agent {
+		r.logger.Warn("service registration rejected: hostname collides with wildcard", "hostname", hostname, "wildcard", pattern, "owner", owner)
+		return fmt.Errorf("hostname %q collides with wildcard %q owned by agent %q", hostname, pattern, owner)
 	}
-	r.logger.Info("service registered", "hostname", hostname, "target", target, "agent", agent)
+
+	r.put(&Service{
+		Hostname:    hostname,
+		PathPrefix:  prefix,
+		StripPrefix: opts.StripPrefix && prefix != "",
+		Target:      target,
+		Agent:       agent,
+		CreatedAt:   createdAt,
+		TargetURL:   targetURL,
+		Proxy:       rp,
+	})
+	r.logger.Info("service registered", "hostname", hostname, "path_prefix", prefix, "target", target, "agent", agent)
 	return nil
 }
 
+// put inserts svc into its hostname's route list, replacing any route with
+// the same prefix and keeping the list ordered longest prefix first. Caller
+// must hold r.mu.
+func (r *Registry) put(svc *Service) {
+	routes := r.services[svc.Hostname]
+	for i, existing := range routes {
+		if existing.PathPrefix == svc.PathPrefix {
+			routes = append(routes[:i:i], routes[i+1:]...)
+			break
+		}
+	}
+	routes = append(routes, svc)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].PathPrefix) > len(routes[j].PathPrefix)
+	})
+	r.services[svc.Hostname] = routes
+}
+
+// newProxy builds the cached reverse proxy for a service. It is configured
+// once here because it is shared by concurrent requests.
+func (r *Registry) newProxy(hostname string, target *url.URL) *httputil.ReverseProxy {
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.FlushInterval = -1
+	rp.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		r.logger.Error("dynamic service proxy error", "hostname", hostname, "error", err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}
+	return rp
+}
+
 // validateTarget checks that a service target URL is safe to proxy to.
 func validateTarget(target string) error {
 	u, err := url.Parse(target)
@@ -139,10 +277,11 @@ func validateTarget(target string) error {
 	return nil
 }
 
-// Deregister removes a route by hostname.
+// Deregister removes every route registered under hostname.
 func (r *Registry) Deregister(hostname string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	hostname = strings.ToLower(hostname)
 
 	if _, ok := r.services[hostname]; ok {
 		delete(r.services, hostname)
@@ -150,16 +289,46 @@ func (r *Registry) Deregister(hostname string) {
 	}
 }
 
+// DeregisterRoute removes the route for a single hostname + path prefix.
+func (r *Registry) DeregisterRoute(hostname, prefix string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hostname, prefix = strings.ToLower(hostname), NormalizePathPrefix(prefix)
+	routes := r.services[hostname]
+	for i, svc := range routes {
+		if svc.PathPrefix == prefix {
+			routes = append(routes[:i:i], routes[i+1:]...)
+			if len(routes) == 0 {
+				delete(r.services, hostname)
+			} else {
+				r.services[hostname] = routes
+			}
+			r.logger.Info("service deregistered", "hostname", hostname, "path_prefix", prefix)
+			return
+		}
+	}
+}
+
 // DeregisterByAgent purges all routes for an agent.
 func (r *Registry) DeregisterByAgent(agent string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	var removed []string
-	for hostname, svc := range r.services {
-		if svc.Agent == agent {
+	for hostname, routes := range r.services {
+		kept := routes[:0:0]
+		for _, svc := range routes {
+			if svc.Agent == agent {
+				removed = append(removed, RouteKey(hostname, svc.PathPrefix))
+			} else {
+				kept = append(kept, svc)
+			}
+		}
+		if len(kept) == 0 {
 			delete(r.services, hostname)
-			removed = append(removed, hostname)
+		} else {
+			r.services[hostname] = kept
 		}
 	}
 	if len(removed) > 0 {
@@ -167,12 +336,22 @@ func (r *Registry) DeregisterByAgent(agent string) {
 	}
 }
 
-// Lookup checks if a service is registered for the given hostname.
+// Lookup checks if a service is registered for the whole hostname.
 func (r *Registry) Lookup(hostname string) (*Service, bool) {
+	return r.Match(hostname, "/")
+}
+
+// Match returns the service with the longest path prefix matching hostname
+// and path.
+func (r *Registry) Match(hostname, path string) (*Service, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	svc, ok := r.services[hostname]
-	return svc, ok
+	for _, svc := range r.services[strings.ToLower(hostname)] {
+		if MatchPathPrefix(svc.PathPrefix, path) {
+			return svc, true
+		}
+	}
+	return nil, false
 }
 
 // List returns all registered services.
@@ -181,8 +360,10 @@ func (r *Registry) List() []Service {
 	defer r.mu.RUnlock()
 
 	result := make([]Service, 0, len(r.services))
-	for _, svc := range r.services {
-		result = append(result, *svc)
+	for _, routes := range r.services {
+		for _, svc := range routes {
+			result = append(result, *svc)
+		}
 	}
 	return result
 }
@@ -192,18 +373,18 @@ func (r *Registry) List() []Service {
 func (r *Registry) RegisterUnsafe(hostname, target, agent string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	hostname = strings.ToLower(hostname)
 	targetURL, _ := url.Parse(target)
 	var rp *httputil.ReverseProxy
 	if targetURL != nil {
-		rp = httputil.NewSingleHostReverseProxy(targetURL)
-		rp.FlushInterval = -1
+		rp = r.newProxy(hostname, targetURL)
 	}
-	r.services[hostname] = &Service{
+	r.put(&Service{
 		Hostname:  hostname,
 		Target:    target,
 		Agent:     agent,
 		CreatedAt: time.Now(),
 		TargetURL: targetURL,
 		Proxy:     rp,
-	}
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"os"
 	"testing"
+	"time"
 )
 
 func testRegistry() *Registry {
@@ -25,6 +26,17 @@ func TestRegisterAndLookup(t *testing.T) {
 	}
 }
 
+func TestLookupIgnoresCase(t *testing.T) {
+	r := testRegistry()
+	r.Register("Shop.A.com", "http://localhost:3000", "agent-a")
+	if _, ok := r.Lookup("shop.a.COM"); !ok {
+		t.Error("expected lookup to match regardless of case")
+	}
+	if list := r.List(); len(list) != 1 || list[0].Hostname != "shop.a.com" {
+		t.Errorf("list = %+v, want the hostname lowercased", list)
+	}
+}
+
 func TestLookupMissing(t *testing.T) {
 	r := testRegistry()
 	_, ok := r.Lookup("nope.com")
@@ -83,3 +95,94 @@ func TestDuplicateHostnameOverwrites(t *testing.T) {
 		t.Errorf("agent = %q, want b", svc.Agent)
 	}
 }
+
+func TestRegisterPathPrefixes(t *testing.T) {
+	r := testRegistry()
+	if err := r.RegisterWithOptions("api.example.com", "http://10.0.0.1:3000", "a", ServiceOptions{PathPrefix: "/a/", StripPrefix: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegisterWithOptions("api.example.com", "http://10.0.0.2:3000", "b", ServiceOptions{PathPrefix: "/a/b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		agent string
+	}{
+		{"/a/x", "a"},
+		{"/a", "a"},
+		{"/a/b/c", "b"},
+		{"/ab", ""},
+	}
+	for _, tt := range tests {
+		svc, ok := r.Match("api.example.com", tt.path)
+		if tt.agent == "" {
+			if ok {
+				t.Errorf("Match(%q) = %q, want no match", tt.path, svc.Agent)
+			}
+			continue
+		}
+		if !ok || svc.Agent != tt.agent {
+			t.Errorf("Match(%q) = %v, want agent %q", tt.path, svc, tt.agent)
+		}
+	}
+
+	svc, _ := r.Match("api.example.com", "/a/x")
+	if svc.PathPrefix != "/a" || !svc.StripPrefix {
+		t.Errorf("prefix = %q strip = %v, want normalized /a with strip", svc.PathPrefix, svc.StripPrefix)
+	}
+	if _, ok := r.Lookup("api.example.com"); ok {
+		t.Error("Lookup should only match a whole-host route")
+	}
+	if len(r.List()) != 2 {
+		t.Errorf("List() len = %d, want 2", len(r.List()))
+	}
+
+	r.DeregisterRoute("api.example.com", "/a/b/")
+	if svc, _ := r.Match("api.example.com", "/a/b/c"); svc.Agent != "a" {
+		t.Errorf("after DeregisterRoute, agent = %q, want a", svc.Agent)
+	}
+}
+
+func TestRegisterWholeHostRejectedUnderReservedPrefix(t *testing.T) {
+	r := testRegistry()
+	r.ReserveRoute("api.example.com", "/agent-a")
+
+	if err := r.Register("api.example.com", "http://10.0.0.1:3000", "b"); err == nil {
+		t.Error("expected a whole-host route on a hostname with reserved prefixes to be rejected")
+	}
+	if err := r.RegisterWithOptions("api.example.com", "http://10.0.0.1:3000", "b", ServiceOptions{PathPrefix: "/agent-b"}); err != nil {
+		t.Errorf("another prefix on the hostname should be allowed: %v", err)
+	}
+	if err := r.Register("other.example.com", "http://10.0.0.1:3000", "b"); err != nil {
+		t.Errorf("unrelated hostname should be allowed: %v", err)
+	}
+}
+
+func TestRegisterInvalidPathPrefix(t *testing.T) {
+	r := testRegistry()
+	if err := r.RegisterWithOptions("api.example.com", "http://10.0.0.1:3000", "a", ServiceOptions{PathPrefix: "/../x"}); err == nil {
+		t.Error("expected invalid path prefix to be rejected")
+	}
+}
+
+func TestRestoreKeepsCreatedAtAndValidates(t *testing.T) {
+	r := testRegistry()
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err := r.Restore(Service{Hostname: "api.example.com", PathPrefix: "/v1", StripPrefix: true, Target: "http://10.0.0.1:3000", Agent: "a", CreatedAt: created})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	svc, ok := r.Match("api.example.com", "/v1/x")
+	if !ok {
+		t.Fatal("expected restored route to match")
+	}
+	if !svc.CreatedAt.Equal(created) || !svc.StripPrefix || svc.Proxy == nil {
+		t.Errorf("restored service = %+v", svc)
+	}
+
+	r.ReserveHostname("reserved.example.com")
+	if err := r.Restore(Service{Hostname: "reserved.example.com", Target: "http://10.0.0.1:3000", Agent: "a"}); err == nil {
+		t.Error("expected restore onto a reserved hostname to be rejected")
+	}
+}
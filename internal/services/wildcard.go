@@ -0,0 +1,23 @@
+package services
+
+import "strings"
+
+// IsWildcard reports whether hostname is a wildcard pattern like "*.example.com".
+func IsWildcard(hostname string) bool {
+	return strings.HasPrefix(hostname, "*.")
+}
+
+// MatchWildcard reports whether host matches pattern. The "*" matches one or
+// more leading labels, which are returned as the captured label (e.g. "foo"
+// for foo.dev.example.com against *.dev.example.com).
+func MatchWildcard(pattern, host string) (string, bool) {
+	if !IsWildcard(pattern) {
+		return "", false
+	}
+	suffix := strings.ToLower(pattern[1:]) // ".dev.example.com"
+	lower := strings.ToLower(host)
+	if len(lower) <= len(suffix) || !strings.HasSuffix(lower, suffix) {
+		return "", false
+	}
+	return host[:len(host)-len(suffix)], true
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchWildcard(t *testing.T) {
+	tests := []struct {
+		pattern, host, label string
+		ok                   bool
+	}{
+		{"*.example.com", "www.example.com", "www", true},
+		{"*.example.com", "a.b.example.com", "a.b", true},
+		{"*.example.com", "WWW.Example.COM", "WWW", true},
+		{"*.example.com", "example.com", "", false},
+		{"*.example.com", "badexample.com", "", false},
+		{"example.com", "example.com", "", false},
+	}
+	for _, tt := range tests {
+		label, ok := MatchWildcard(tt.pattern, tt.host)
+		if ok != tt.ok || label != tt.label {
+			t.Errorf("MatchWildcard(%q, %q) = (%q, %v), want (%q, %v)", tt.pattern, tt.host, label, ok, tt.label, tt.ok)
+		}
+	}
+}
+
+func TestRegistry_RejectsHostnameUnderForeignWildcard(t *testing.T) {
+	r := testRegistry()
+	r.ReserveWildcard("*.dev.example.com", "agent-a")
+
+	err := r.Register("preview.dev.example.com", "http://10.0.0.5:3000", "agent-b")
+	if err == nil {
+		t.Fatal("expected collision error")
+	}
+	if !strings.Contains(err.Error(), "agent-a") {
+		t.Errorf("error = %v, want owner in message", err)
+	}
+
+	// The owning agent may register under its own wildcard.
+	if err := r.Register("preview.dev.example.com", "http://10.0.0.5:3000", "agent-a"); err != nil {
+		t.Errorf("owner registration failed: %v", err)
+	}
+}
+
+func TestRegistry_MostSpecificWildcardOwns(t *testing.T) {
+	r := testRegistry()
+	r.ReserveWildcard("*.example.com", "agent-a")
+	r.ReserveWildcard("*.dev.example.com", "agent-b")
+
+	if err := r.Register("x.dev.example.com", "http://10.0.0.5:3000", "agent-b"); err != nil {
+		t.Errorf("agent-b should own x.dev.example.com: %v", err)
+	}
+	if err := r.Register("y.dev.example.com", "http://10.0.0.5:3000", "agent-a"); err == nil {
+		t.Error("agent-a should not register under agent-b's narrower wildcard")
+	}
+}